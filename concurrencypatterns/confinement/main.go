@@ -1,33 +1,49 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"lifecycle"
 	"sync"
 	"time"
 )
 
 var mu sync.Mutex
 
-func process(num int) int {
-	time.Sleep(time.Second * 2)
-	return num * 2
+// process simulates two seconds of work, giving up when ctx is done
+func process(ctx context.Context, num int) (int, error) {
+	if err := lifecycle.Sleep(ctx, time.Second*2); err != nil {
+		return 0, err
+	}
+	return num * 2, nil
 }
 
-func processData(wg *sync.WaitGroup, num int, result *int) {
+func processData(ctx context.Context, wg *sync.WaitGroup, num int, result *int, errp *error) {
 	defer wg.Done()
-	*result =  process(num)
+	*result, *errp = process(ctx, num)
 }
 
 func main() {
+	// Ctrl+C or SIGTERM abandons the work in progress
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
+
 	start := time.Now()
 	a := []int{1, 2, 3, 4, 5}
 	result := make([]int, len(a))
+	errs := make([]error, len(a))
 	var wg sync.WaitGroup
 	for i, num := range a {
 		wg.Add(1)
-		go processData(&wg, num, &result[i]) //no shared resource between goroutines because of confinement. Confinement is a principle that restricts the scope of a variable to a specific part of the program.
+		go processData(ctx, &wg, num, &result[i], &errs[i]) //no shared resource between goroutines because of confinement. Confinement is a principle that restricts the scope of a variable to a specific part of the program.
 	}
 	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			fmt.Println("Stopped:", err)
+			return
+		}
+	}
 	fmt.Println(result)
 	fmt.Println(time.Since(start))
 }
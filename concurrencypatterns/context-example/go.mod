@@ -2,3 +2,6 @@ module context-example
 
 go 1.23.2
 
+require lifecycle v0.0.0
+
+replace lifecycle => ../lifecycle
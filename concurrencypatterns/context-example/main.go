@@ -3,12 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"lifecycle"
 	"sync"
 	"time"
 )
 
-func appleReader(ctx context.Context, parentWg *sync.WaitGroup, appleStream chan any) {
-	defer parentWg.Done()
+// appleReader reads the apple stream with three workers for ten seconds
+func appleReader(ctx context.Context, appleStream chan any) error {
 	var wg sync.WaitGroup
 	newCtx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
@@ -23,7 +24,9 @@ func appleReader(ctx context.Context, parentWg *sync.WaitGroup, appleStream chan
 					fmt.Println("Apple stream closed")
 					return
 				}
-				time.Sleep(time.Second)
+				if lifecycle.Sleep(newCtx, time.Second) != nil {
+					return
+				}
 				fmt.Println(v, id)
 			}
 		}
@@ -34,21 +37,22 @@ func appleReader(ctx context.Context, parentWg *sync.WaitGroup, appleStream chan
 		go doWork(i)
 	}
 	wg.Wait()
-
+	return context.Cause(newCtx)
 }
 
-func genericReader(ctx context.Context, wg *sync.WaitGroup, stream chan any){
-	defer wg.Done()
+func genericReader(ctx context.Context, stream chan any) error {
 	for{
 		select{
 		case <- ctx.Done():
-			return
+			return context.Cause(ctx)
 		case v, ok := <- stream:
 			if(!ok){
 				fmt.Println("Stream closed")
-				return
+				return nil
+			}
+			if err := lifecycle.Sleep(ctx, time.Second); err != nil {
+				return err
 			}
-			time.Sleep(time.Second)
 			fmt.Println(v)
 		}
 	}
@@ -56,32 +60,41 @@ func genericReader(ctx context.Context, wg *sync.WaitGroup, stream chan any){
 }
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Ctrl+C or SIGTERM cancels ctx, and every reader and generator stops
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
 	appleStream := make(chan any)
 	orangeStream := make(chan any)
 	peachStream := make(chan any)
-	generator := func(data string, c chan any) {
-		for {
-			select {
-			case <- ctx.Done():
-				return
-			case c <- data:
+	generator := func(data string, c chan any) lifecycle.Func {
+		return func(ctx context.Context) error {
+			for {
+				select {
+				case <- ctx.Done():
+					return context.Cause(ctx)
+				case c <- data:
+				}
 			}
 		}
 	}
 
-	go generator("apple", appleStream)
-	go generator("orange", orangeStream)
-	go generator("peach", peachStream)
-	var wg sync.WaitGroup 
-	func ()  {
-		wg.Add(1)
-		go appleReader(ctx, &wg, appleStream)
-		wg.Add(1)
-		go genericReader(ctx, &wg, orangeStream)
-		wg.Add(1)
-		go genericReader(ctx, &wg, peachStream)
-	}()
-	wg.Wait()
+	// The apple reader gives up after ten seconds, which stops the rest
+	group := &lifecycle.Group{
+		OnStop: func(name string, err error) {
+			fmt.Printf("%s stopped: %v\n", name, err)
+		},
+	}
+	group.Add("apple generator", generator("apple", appleStream))
+	group.Add("orange generator", generator("orange", orangeStream))
+	group.Add("peach generator", generator("peach", peachStream))
+	group.Add("apple reader", lifecycle.Func(func(ctx context.Context) error {
+		return appleReader(ctx, appleStream)
+	}))
+	group.Add("orange reader", lifecycle.Func(func(ctx context.Context) error {
+		return genericReader(ctx, orangeStream)
+	}))
+	group.Add("peach reader", lifecycle.Func(func(ctx context.Context) error {
+		return genericReader(ctx, peachStream)
+	}))
+	fmt.Println("Main: shutting down:", group.Run(ctx))
 }
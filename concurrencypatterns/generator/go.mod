@@ -2,3 +2,6 @@ module generator
 
 go 1.23.2
 
+require lifecycle v0.0.0
+
+replace lifecycle => ../lifecycle
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"lifecycle"
 	"math/rand"
 	"time"
 )
@@ -24,22 +26,41 @@ func repeaterFunc[T any, K any](done <-chan T, fn func() K) <-chan K {
 	return stream
 }
 
-func main() {
-	done := make(chan bool)
+// printNumbers prints count random numbers, one every 250ms, and stops early
+// when ctx is done
+func printNumbers(ctx context.Context, count int) error {
+	if count <= 0 {
+		return nil
+	}
+	// Cancelling done stops the generator once we have read enough
+	done, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Define a function that generates random numbers between 0 and 9
 	rondomNumber := func() int {
 		return rand.Intn(10)
 	}
 
-	count := 0
 	// Use repeaterFunc to generate a stream of random numbers
-	for num := range repeaterFunc(done, rondomNumber) {
-		time.Sleep(time.Second / 4) // Pause for 250ms between numbers
+	for num := range repeaterFunc(done.Done(), rondomNumber) {
+		if err := lifecycle.Sleep(ctx, time.Second/4); err != nil { // Pause for 250ms between numbers
+			return err
+		}
 		fmt.Println(num)
-		count++
-		if count == 20 {
-			close(done) // Stop the stream after 20 numbers
+		count--
+		if count == 0 {
+			return nil // Stop the stream after enough numbers
 		}
 	}
+	return context.Cause(ctx)
+}
+
+func main() {
+	// Ctrl+C or SIGTERM stops the stream early
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
+
+	if err := printNumbers(ctx, 20); err != nil {
+		fmt.Println("Stopped:", err)
+	}
 }
@@ -0,0 +1,135 @@
+// Package lifecycle runs long-running demo components until they fail or the
+// process is asked to stop, then shuts them all down and reports why. The
+// demos share it instead of sleeping in main.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Runner is a long-running component. Run blocks until ctx is done or the
+// component stops on its own, and returns why it stopped.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// Func adapts a function to the Runner interface
+type Func func(ctx context.Context) error
+
+// Run calls f(ctx)
+func (f Func) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Sleep pauses for d, the way a demo simulates work or paces its output, but
+// returns the cause of ctx as soon as ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SignalError is the cancellation cause of a context created by WithSignals
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "received signal " + e.Signal.String()
+}
+
+// WithSignals returns a context that is cancelled with a *SignalError cause
+// when the process receives SIGINT or SIGTERM. Call stop to release the signal handler.
+func WithSignals(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			cancel(&SignalError{Signal: sig})
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel(context.Canceled)
+		})
+	}
+}
+
+// Group runs a set of named Runners together. As soon as ctx is done or any
+// runner returns, every other runner is cancelled and Run waits for all of them.
+type Group struct {
+	// OnStop, if set, is called with each runner's name and result as it stops
+	OnStop func(name string, err error)
+
+	runners []namedRunner
+}
+
+type namedRunner struct {
+	name string
+	Runner
+}
+
+// Add registers a runner under name
+func (g *Group) Add(name string, r Runner) {
+	g.runners = append(g.runners, namedRunner{name: name, Runner: r})
+}
+
+// Run starts every runner and blocks until all have stopped. It returns why
+// the group stopped: the cause of ctx (such as a *SignalError) if it ended
+// first, otherwise the result of the first runner to return, prefixed with its
+// name. A nil error means the first runner to stop finished cleanly.
+func (g *Group) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(g.runners))
+	for _, r := range g.runners {
+		go func() {
+			results <- result{name: r.name, err: r.Run(ctx)}
+		}()
+	}
+
+	var reason error
+	for i := range g.runners {
+		res := <-results
+		if g.OnStop != nil {
+			g.OnStop(res.name, res.err)
+		}
+		if i > 0 {
+			continue
+		}
+
+		// The first runner to stop decides why the group stops
+		if ctx.Err() != nil {
+			reason = context.Cause(ctx)
+		} else if res.err != nil {
+			reason = fmt.Errorf("%s: %w", res.name, res.err)
+		}
+		cancel(reason)
+	}
+	return reason
+}
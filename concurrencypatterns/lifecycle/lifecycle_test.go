@@ -0,0 +1,101 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waiter blocks until ctx is done and returns its cause
+func waiter(ctx context.Context) error {
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
+
+func TestGroupStopsWhenRunnerFails(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	var mu sync.Mutex
+	stopped := map[string]error{}
+	g := &Group{OnStop: func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped[name] = err
+	}}
+	g.Add("waiter", Func(waiter))
+	g.Add("failer", Func(func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return errBoom
+	}))
+
+	err := g.Run(context.Background())
+	if !errors.Is(err, errBoom) || err.Error() != "failer: boom" {
+		t.Errorf("Run() = %v, want failer: boom", err)
+	}
+	if len(stopped) != 2 {
+		t.Fatalf("OnStop called for %v, want both runners", stopped)
+	}
+	if !errors.Is(stopped["waiter"], errBoom) {
+		t.Errorf("waiter stopped with %v, want the failer's error as cause", stopped["waiter"])
+	}
+}
+
+func TestGroupReportsContextCause(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	g := &Group{}
+	g.Add("a", Func(waiter))
+	g.Add("b", Func(waiter))
+	if err := g.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() = %v, want deadline exceeded", err)
+	}
+}
+
+func TestGroupCleanFinish(t *testing.T) {
+	g := &Group{}
+	g.Add("done", Func(func(ctx context.Context) error { return nil }))
+	g.Add("waiter", Func(waiter))
+	if err := g.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, want nil", err)
+	}
+}
+
+func TestWithSignals(t *testing.T) {
+	ctx, stop := WithSignals(context.Background())
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Skipf("cannot signal self: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not cancelled by SIGTERM")
+	}
+
+	var sigErr *SignalError
+	if !errors.As(context.Cause(ctx), &sigErr) || sigErr.Signal != syscall.SIGTERM {
+		t.Errorf("cause = %v, want SIGTERM", context.Cause(ctx))
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep() = %v, want nil", err)
+	}
+
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errStop)
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, errStop) {
+		t.Errorf("Sleep() = %v, want the cancellation cause", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Sleep ignored the cancelled context")
+	}
+}
@@ -2,3 +2,12 @@ module prime-fan-in-fan-out
 
 go 1.23.2
 
+require (
+	primes v0.0.0
+	worker-pool v0.0.0
+)
+
+replace (
+	primes => ../primes
+	worker-pool => ../worker-pool
+)
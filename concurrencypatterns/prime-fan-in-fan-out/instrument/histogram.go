@@ -0,0 +1,96 @@
+package instrument
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// bucketBounds are the upper bounds of the latency histogram buckets.
+// They double from 1µs up to ~16s; anything slower lands in the overflow bucket.
+var bucketBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 25)
+	d := time.Microsecond
+	for i := range bounds {
+		bounds[i] = d
+		d *= 2
+	}
+	return bounds
+}()
+
+// histogram is a lock-free latency histogram with fixed exponential buckets
+type histogram struct {
+	counts [26]atomic.Int64 // len(bucketBounds) + overflow
+	count  atomic.Int64
+	sum    atomic.Int64 // nanoseconds
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(bucketBounds) && d > bucketBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Bucket is one histogram bucket. UpperBound is zero for the overflow bucket.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// Histogram is a point-in-time copy of a latency histogram
+type Histogram struct {
+	Count   int64
+	Sum     time.Duration
+	Buckets []Bucket
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Count:   h.count.Load(),
+		Sum:     time.Duration(h.sum.Load()),
+		Buckets: make([]Bucket, len(h.counts)),
+	}
+	for i := range h.counts {
+		if i < len(bucketBounds) {
+			s.Buckets[i].UpperBound = bucketBounds[i]
+		}
+		s.Buckets[i].Count = h.counts[i].Load()
+	}
+	return s
+}
+
+// Mean returns the average observed latency
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding the q-th quantile (0 < q <= 1).
+// Observations in the overflow bucket report the largest finite bound.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	// The q-th quantile is the ceil(q*Count)-th smallest observation
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, b := range h.Buckets {
+		seen += b.Count
+		if seen >= rank {
+			if b.UpperBound == 0 {
+				break
+			}
+			return b.UpperBound
+		}
+	}
+	return bucketBounds[len(bucketBounds)-1]
+}
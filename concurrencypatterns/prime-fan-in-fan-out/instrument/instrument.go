@@ -0,0 +1,160 @@
+// Package instrument records per-stage statistics for channel pipelines:
+// items in and out, processing latency, time blocked on receive and send,
+// and how full the input buffer was.
+package instrument
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Recorder owns the stages of one pipeline
+type Recorder struct {
+	mu     sync.Mutex
+	stages []*Stage
+	byName map[string]*Stage
+}
+
+// NewRecorder creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{byName: make(map[string]*Stage)}
+}
+
+// Stage returns the stage with the given name, creating it on first use.
+// Goroutines that share a stage name (e.g. fanned-out workers) share its counters.
+func (r *Recorder) Stage(name string) *Stage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byName[name]; ok {
+		return s
+	}
+	s := &Stage{name: name}
+	r.byName[name] = s
+	r.stages = append(r.stages, s)
+	return s
+}
+
+// Snapshot returns the current statistics of every stage in creation order
+func (r *Recorder) Snapshot() []StageSnapshot {
+	r.mu.Lock()
+	stages := append([]*Stage(nil), r.stages...)
+	r.mu.Unlock()
+
+	snaps := make([]StageSnapshot, len(stages))
+	for i, s := range stages {
+		snaps[i] = s.Snapshot()
+	}
+	return snaps
+}
+
+// Report writes a one-line summary per stage to w
+func (r *Recorder) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "stage\tin\tout\tp50\tp99\tmean\trecv blocked\tsend blocked\tqueue avg/max/cap")
+	for _, s := range r.Snapshot() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%v\t%v\t%v\t%.1f/%d/%d\n",
+			s.Name, s.In, s.Out,
+			s.Latency.Quantile(0.5), s.Latency.Quantile(0.99), s.Latency.Mean(),
+			s.RecvBlocked, s.SendBlocked,
+			s.Queue.Mean(), s.Queue.Max, s.Queue.Cap,
+		)
+	}
+	return tw.Flush()
+}
+
+// Stage collects statistics for one pipeline stage. All methods are safe for concurrent use.
+type Stage struct {
+	name string
+
+	in, out     atomic.Int64
+	recvBlocked atomic.Int64 // nanoseconds
+	sendBlocked atomic.Int64 // nanoseconds
+	latency     histogram
+
+	queueSamples atomic.Int64
+	queueSum     atomic.Int64
+	queueMax     atomic.Int64
+	queueCap     atomic.Int64
+}
+
+// Name returns the stage name
+func (s *Stage) Name() string { return s.name }
+
+// Received records one item taken from the input after waiting for blocked
+func (s *Stage) Received(blocked time.Duration) {
+	s.in.Add(1)
+	s.recvBlocked.Add(int64(blocked))
+}
+
+// Sent records one item handed downstream after waiting for blocked
+func (s *Stage) Sent(blocked time.Duration) {
+	s.out.Add(1)
+	s.sendBlocked.Add(int64(blocked))
+}
+
+// Processed records how long the stage spent working on one item
+func (s *Stage) Processed(d time.Duration) {
+	s.latency.observe(d)
+}
+
+// SampleQueue records the occupancy of a buffered input channel
+func (s *Stage) SampleQueue(length, capacity int) {
+	s.queueSamples.Add(1)
+	s.queueSum.Add(int64(length))
+	s.queueCap.Store(int64(capacity))
+	for {
+		max := s.queueMax.Load()
+		if int64(length) <= max || s.queueMax.CompareAndSwap(max, int64(length)) {
+			return
+		}
+	}
+}
+
+// Queue summarises sampled buffer occupancy
+type Queue struct {
+	Samples int64
+	Sum     int64
+	Max     int64
+	Cap     int64
+}
+
+// Mean returns the average sampled queue length
+func (q Queue) Mean() float64 {
+	if q.Samples == 0 {
+		return 0
+	}
+	return float64(q.Sum) / float64(q.Samples)
+}
+
+// StageSnapshot is a point-in-time copy of a stage's statistics
+type StageSnapshot struct {
+	Name        string
+	In          int64
+	Out         int64
+	Latency     Histogram
+	RecvBlocked time.Duration
+	SendBlocked time.Duration
+	Queue       Queue
+}
+
+// Snapshot returns the stage's current statistics
+func (s *Stage) Snapshot() StageSnapshot {
+	return StageSnapshot{
+		Name:        s.name,
+		In:          s.in.Load(),
+		Out:         s.out.Load(),
+		Latency:     s.latency.snapshot(),
+		RecvBlocked: time.Duration(s.recvBlocked.Load()),
+		SendBlocked: time.Duration(s.sendBlocked.Load()),
+		Queue: Queue{
+			Samples: s.queueSamples.Load(),
+			Sum:     s.queueSum.Load(),
+			Max:     s.queueMax.Load(),
+			Cap:     s.queueCap.Load(),
+		},
+	}
+}
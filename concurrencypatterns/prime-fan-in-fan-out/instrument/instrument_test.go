@@ -0,0 +1,93 @@
+package instrument
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessCountsItems(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	rec := NewRecorder()
+	in := make(chan int, 10)
+	for i := 1; i <= 10; i++ {
+		in <- i
+	}
+	close(in)
+
+	evens := Process(done, rec.Stage("even"), in, func(n int) (int, bool) {
+		return n, n%2 == 0
+	})
+	var got []int
+	for n := range evens {
+		got = append(got, n)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("got %v, want 5 even numbers", got)
+	}
+	snap := rec.Snapshot()
+	if len(snap) != 1 {
+		t.Fatalf("got %d stages, want 1", len(snap))
+	}
+	s := snap[0]
+	if s.In != 10 || s.Out != 5 {
+		t.Errorf("in/out = %d/%d, want 10/5", s.In, s.Out)
+	}
+	if s.Latency.Count != 10 {
+		t.Errorf("latency count = %d, want 10", s.Latency.Count)
+	}
+	if s.Queue.Cap != 10 || s.Queue.Max != 10 {
+		t.Errorf("queue max/cap = %d/%d, want 10/10", s.Queue.Max, s.Queue.Cap)
+	}
+}
+
+func TestStageSharedByName(t *testing.T) {
+	rec := NewRecorder()
+	if rec.Stage("prime") != rec.Stage("prime") {
+		t.Fatal("Stage returned different stages for the same name")
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	for i := 0; i < 99; i++ {
+		h.observe(time.Microsecond)
+	}
+	h.observe(time.Second)
+
+	snap := h.snapshot()
+	if q := snap.Quantile(0.5); q != time.Microsecond {
+		t.Errorf("p50 = %v, want 1µs", q)
+	}
+	if q := snap.Quantile(1); q < time.Second {
+		t.Errorf("p100 = %v, want >= 1s", q)
+	}
+
+	// The median of three observations is the second one, not the first
+	var small histogram
+	small.observe(time.Microsecond)
+	small.observe(time.Millisecond)
+	small.observe(time.Millisecond)
+	if q := small.snapshot().Quantile(0.5); q < time.Millisecond {
+		t.Errorf("p50 of 1µs, 1ms, 1ms = %v, want >= 1ms", q)
+	}
+}
+
+func TestReport(t *testing.T) {
+	rec := NewRecorder()
+	s := rec.Stage("generator")
+	s.Received(time.Millisecond)
+	s.Processed(time.Millisecond)
+	s.Sent(2 * time.Millisecond)
+
+	var b strings.Builder
+	if err := rec.Report(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "generator") {
+		t.Errorf("report does not mention the stage:\n%s", b.String())
+	}
+}
@@ -0,0 +1,75 @@
+package instrument
+
+import "time"
+
+// Generate is an instrumented streamGenerator: it calls fn until done is closed
+// and sends every value downstream. Time spent in fn counts as processing latency.
+func Generate[T any, K any](done <-chan T, s *Stage, fn func() K) <-chan K {
+	stream := make(chan K)
+	go func() {
+		defer close(stream)
+		for {
+			start := time.Now()
+			v := fn()
+			s.Processed(time.Since(start))
+			if !send(done, s, stream, v) {
+				return
+			}
+		}
+	}()
+	return stream
+}
+
+// Process runs fn over every item received from in and sends the results whose
+// keep flag is true downstream. It stops when done is closed or in is drained.
+func Process[T any, In any, Out any](done <-chan T, s *Stage, in <-chan In, fn func(In) (Out, bool)) <-chan Out {
+	out := make(chan Out)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := receive(done, s, in)
+			if !ok {
+				return
+			}
+			start := time.Now()
+			res, keep := fn(v)
+			s.Processed(time.Since(start))
+			if keep && !send(done, s, out, res) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Tap forwards in unchanged while recording throughput, blocking and queue depth.
+// Use it to observe a stage whose internals cannot be wrapped, such as fanIn.
+func Tap[T any, K any](done <-chan T, s *Stage, in <-chan K) <-chan K {
+	return Process(done, s, in, func(v K) (K, bool) { return v, true })
+}
+
+func receive[T any, K any](done <-chan T, s *Stage, in <-chan K) (K, bool) {
+	s.SampleQueue(len(in), cap(in))
+	start := time.Now()
+	select {
+	case <-done:
+	case v, ok := <-in:
+		if ok {
+			s.Received(time.Since(start))
+			return v, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+func send[T any, K any](done <-chan T, s *Stage, out chan<- K, v K) bool {
+	start := time.Now()
+	select {
+	case <-done:
+		return false
+	case out <- v:
+		s.Sent(time.Since(start))
+		return true
+	}
+}
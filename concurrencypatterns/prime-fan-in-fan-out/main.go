@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"prime-fan-in-fan-out/instrument"
+	"primes"
 	"runtime"
 	"sync"
 	"time"
+	"worker-pool/workerpool"
 )
 
 func fanIn[T any, K any](done <- chan T, channels ...<-chan K) <- chan K{
@@ -36,19 +42,8 @@ func randIntFetcher() int {
 	return rand.Intn(50000000)
 }
 
-func streamGenerator[T any, K any](done <-chan T, fn func() K) <-chan K {
-	stream := make(chan K)
-	go func() {
-		defer close(stream)
-		for {
-			select {
-			case <-done:
-				return
-			case stream <- fn():
-			}
-		}
-	}()
-	return stream
+func streamGenerator[T any, K any](done <-chan T, stage *instrument.Stage, fn func() K) <-chan K {
+	return instrument.Generate(done, stage, fn)
 }
 
 func take[T any, K any](done <-chan T, stream <-chan K, n int) <-chan K {
@@ -66,52 +61,79 @@ func take[T any, K any](done <-chan T, stream <-chan K, n int) <-chan K {
 	return taken
 }
 
-func primeStreamGenerator[T any](done <-chan T, stream <-chan int) <-chan int {
-	primes := make(chan int)
-	isPrime := func(n int) bool {
-		for i := n - 1; i > 1; i-- {
-			if n%i == 0 {
-				return false
-			}
-		}
-		return true
-	}
+// primeCheck is a number whose primality is being checked on the pool
+type primeCheck struct {
+	num    int
+	future *workerpool.Future[bool]
+}
+
+// primeStreamGenerator checks the numbers it takes from stream on pool and
+// sends the primes downstream. Its checks are awaited in order, with the next
+// one already submitted while it waits.
+func primeStreamGenerator(ctx context.Context, stage *instrument.Stage, pool *workerpool.WorkerPool[bool], stream <-chan int) <-chan int {
+	checks := make(chan primeCheck, 1)
 	go func() {
-		defer close(primes)
+		defer close(checks)
 		for {
+			var num int
 			select {
-			case <-done:
+			case <-ctx.Done():
 				return
-			case num := <-stream:
-				if isPrime(num) {
-					primes <- num
+			case n, ok := <-stream:
+				if !ok {
+					return
 				}
+				num = n
+			}
+			f, err := pool.Submit(ctx, func(context.Context) (bool, error) {
+				return primes.IsPrime(uint64(num)), nil
+			})
+			if err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case checks <- primeCheck{num: num, future: f}:
 			}
 		}
 	}()
-	return primes
+	return instrument.Process(ctx.Done(), stage, checks, func(c primeCheck) (int, bool) {
+		isPrime, err := c.future.Wait(ctx)
+		return c.num, err == nil && isPrime
+	})
 }
 
 func main() {
+	stats := flag.Bool("stats", false, "print per-stage pipeline statistics at shutdown")
+	flag.Parse()
+
 	t := time.Now()
-	done := make(chan bool)
-	defer close(done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := ctx.Done()
+
+	// Every stage records throughput, latency and blocking into the recorder
+	recorder := instrument.NewRecorder()
 
 	// Fan-out: Create a single source of random integers
-	randomIntStream := streamGenerator(done, randIntFetcher)
+	randomIntStream := streamGenerator(done, recorder.Stage("generator"), randIntFetcher)
 
 	availableCPUs := runtime.NumCPU()
 	fmt.Println("available cpus are:", availableCPUs)
 
-	// Fan-out: Distribute work across multiple goroutines
-	// Create multiple prime finder channels, each processing the random int stream
+	// Fan-out: Distribute work across multiple prime finders, each processing
+	// the random int stream. Their checks run on a worker pool that grows up
+	// to one worker per CPU while checks wait in its queue.
+	pool := workerpool.New[bool](workerpool.Config{MinWorkers: 1, MaxWorkers: availableCPUs, QueueSize: availableCPUs})
+	defer pool.Shutdown(context.Background())
 	primeFinderChannels := make([]<-chan int, availableCPUs)
 	for i := 0; i < availableCPUs; i++ {
-		primeFinderChannels[i] = primeStreamGenerator(done, randomIntStream)
+		primeFinderChannels[i] = primeStreamGenerator(ctx, recorder.Stage("prime"), pool, randomIntStream)
 	}
 
 	// Fan-in: Combine results from multiple channels into a single channel
-	finalChannel := fanIn(done, primeFinderChannels...)
+	finalChannel := instrument.Tap(done, recorder.Stage("fan-in"), fanIn(done, primeFinderChannels...))
 
 	// Process the results from the combined channel
 	for num := range take(done, finalChannel, 10) {
@@ -119,4 +141,9 @@ func main() {
 	}
 
 	fmt.Println(time.Since(t))
+	if *stats {
+		if err := recorder.Report(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "writing stats:", err)
+		}
+	}
 }
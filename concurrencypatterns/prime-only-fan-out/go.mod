@@ -1,4 +1,7 @@
-module prime-fan-in-fan-out
+module prime-only-fan-out
 
 go 1.23.2
 
+require primes v0.0.0
+
+replace primes => ../primes
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"primes"
 	"runtime"
 	"sync"
 	"time"
@@ -67,14 +68,6 @@ func take[T any, K any](done <-chan T, stream <-chan K, n int) <-chan K {
 }
 
 func primeStreamGenerator[T any](done <-chan T, stream <-chan int, finalChannel chan int){
-	isPrime := func(n int) bool {
-		for i := n - 1; i > 1; i-- {
-			if n%i == 0 {
-				return false
-			}
-		}
-		return true
-	}
 	go func() {
 		defer close(finalChannel)
 		for {
@@ -82,7 +75,7 @@ func primeStreamGenerator[T any](done <-chan T, stream <-chan int, finalChannel
 			case <-done:
 				return
 			case num := <-stream:
-				if isPrime(num) {
+				if primes.IsPrime(uint64(num)) {
 					finalChannel <- num
 				}
 			}
@@ -2,3 +2,12 @@ module prime-pipeline
 
 go 1.23.2
 
+require (
+	primes v0.0.0
+	shared v0.0.0
+)
+
+replace (
+	primes => ../primes
+	shared => ../../webserver/shared
+)
@@ -1,11 +1,28 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"primes"
+	"shared/metrics"
 	"time"
 )
 
+// stageMetrics records what passes through each pipeline stage
+type stageMetrics struct {
+	items   *metrics.Counter   // items leaving a stage
+	seconds *metrics.Histogram // time a stage spends on one item
+}
+
+func newStageMetrics(reg *metrics.Registry) *stageMetrics {
+	return &stageMetrics{
+		items:   reg.NewCounter("pipeline_items_total", "Items leaving each stage.", "stage"),
+		seconds: reg.NewHistogram("pipeline_item_seconds", "Processing time per item.", []float64{1e-6, 1e-5, 1e-4, 1e-3}, "stage"),
+	}
+}
+
 func randIntFetcher() int {
 	return rand.Intn(50000000)
 }
@@ -40,40 +57,50 @@ func take[T any, K any](done <-chan T, stream <-chan K, n int) <-chan K {
 	return taken
 }
 
-func primeStreamGenerator[T any](done <-chan T, stream <-chan int) <-chan int {
-	primes := make(chan int)
+func primeStreamGenerator[T any](done <-chan T, m *stageMetrics, stream <-chan int) <-chan int {
+	primeStream := make(chan int)
 	isPrime := func(n int) bool {
-		for i := n - 1; i > 1; i-- {
-			if n%i == 0 {
-				return false
-			}
-		}
-		return true
+		return primes.IsPrime(uint64(n))
 	}
 	go func() {
-		defer close(primes)
+		defer close(primeStream)
 		for {
 			select {
 			case <-done:
 				return
 			case num := <-stream:
-				if isPrime(num) {
-					primes <- num
+				start := time.Now()
+				prime := isPrime(num)
+				m.seconds.Observe(time.Since(start).Seconds(), "prime")
+				if prime {
+					m.items.Inc("prime")
+					primeStream <- num
 				}
 			}
 		}
 	}()
-	return primes
+	return primeStream
 }
 
 func main() {
+	showMetrics := flag.Bool("metrics", false, "print the pipeline metrics at exit")
+	flag.Parse()
+
 	t := time.Now()
 	done := make(chan bool)
 	defer close(done)
+	reg := metrics.NewRegistry()
+	m := newStageMetrics(reg)
 	randomIntStream := streamGenerator(done, randIntFetcher)
-	primeIntStream := primeStreamGenerator(done, randomIntStream)
+	primeIntStream := primeStreamGenerator(done, m, randomIntStream)
 	for num := range take(done, primeIntStream, 10) {
+		m.items.Inc("take")
 		fmt.Println(num)
 	}
 	fmt.Println(time.Since(t))
+	if *showMetrics {
+		if _, err := reg.WriteTo(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "writing metrics:", err)
+		}
+	}
 }
@@ -0,0 +1,68 @@
+// Package primes provides fast primality testing and prime generation for the
+// prime pipelines: deterministic Miller-Rabin for 64-bit integers and a
+// segmented Sieve of Eratosthenes that streams primes over a channel.
+package primes
+
+import "math/bits"
+
+// witnesses are the bases that make Miller-Rabin deterministic for every n < 2^64
+var witnesses = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// IsPrime reports whether n is prime using deterministic Miller-Rabin
+func IsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range witnesses {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// Write n-1 as d * 2^r with d odd
+	d := n - 1
+	r := bits.TrailingZeros64(d)
+	d >>= r
+
+	for _, a := range witnesses {
+		if !millerRabinRound(n, a, d, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// millerRabinRound reports whether n is a strong probable prime to base a
+func millerRabinRound(n, a, d uint64, r int) bool {
+	x := powMod(a, d, n)
+	if x == 1 || x == n-1 {
+		return true
+	}
+	for i := 1; i < r; i++ {
+		x = mulMod(x, x, n)
+		if x == n-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// mulMod returns a*b mod m without overflowing 64 bits
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, m)
+}
+
+// powMod returns base^exp mod m
+func powMod(base, exp, m uint64) uint64 {
+	result := uint64(1)
+	base %= m
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = mulMod(result, base, m)
+		}
+		base = mulMod(base, base, m)
+		exp >>= 1
+	}
+	return result
+}
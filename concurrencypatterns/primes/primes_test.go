@@ -0,0 +1,186 @@
+package primes
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// trialDivision is the isPrime used by the prime pipeline mains
+func trialDivision(n int) bool {
+	for i := n - 1; i > 1; i-- {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func collect(stream <-chan uint64) []uint64 {
+	var out []uint64
+	for p := range stream {
+		out = append(out, p)
+	}
+	return out
+}
+
+func TestIsPrime(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    uint64
+		expected bool
+	}{
+		{"Zero", 0, false},
+		{"One", 1, false},
+		{"Two", 2, true},
+		{"Witness", 37, true},
+		{"Carmichael number", 561, false},
+		{"Strong pseudoprime to base 2", 2047, false},
+		{"Large prime", 49999991, true},
+		{"Largest 64-bit prime", 18446744073709551557, true},
+		{"Largest 64-bit integer", 18446744073709551615, false},
+		{"Product of two 32-bit primes", 4294967291 * 4294967279, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPrime(tt.input); got != tt.expected {
+				t.Errorf("IsPrime(%d) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsPrimeMatchesTrialDivision(t *testing.T) {
+	for n := 2; n < 10000; n++ {
+		if IsPrime(uint64(n)) != trialDivision(n) {
+			t.Fatalf("IsPrime(%d) disagrees with trial division", n)
+		}
+	}
+}
+
+// must returns a function that fails the test if a sieve rejected its range
+func must(t testing.TB) func(<-chan uint64, error) <-chan uint64 {
+	return func(stream <-chan uint64, err error) <-chan uint64 {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+}
+
+func TestSegmented(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	got := collect(must(t)(Segmented(done, 0, 30)))
+	want := []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Segmented(0, 30) = %v, want %v", got, want)
+	}
+
+	// Spans several segments and starts mid-segment
+	lo, hi := uint64(1000003), uint64(1000003+3*segmentSize+17)
+	for _, p := range collect(must(t)(Segmented(done, lo, hi))) {
+		if p < lo || p >= hi || !IsPrime(p) {
+			t.Fatalf("Segmented(%d, %d) produced %d", lo, hi, p)
+		}
+	}
+}
+
+func TestParallelMatchesSegmented(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	lo, hi := uint64(10), uint64(10*segmentSize+123)
+	want := collect(must(t)(Segmented(done, lo, hi)))
+	got := collect(must(t)(Parallel(done, lo, hi, 4)))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parallel produced %d primes, Segmented produced %d", len(got), len(want))
+	}
+}
+
+func TestSieveRange(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	tests := []struct {
+		name    string
+		lo, hi  uint64
+		wantErr bool
+	}{
+		{"Empty", 10, 10, false},
+		{"Top of the range", MaxHi - 100, MaxHi, false},
+		{"Reversed", 20, 10, true},
+		{"Too large", 0, MaxHi + 1, true},
+		{"Top of uint64", math.MaxUint64 - 10, math.MaxUint64, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Segmented(done, tt.lo, tt.hi)
+			if got := errors.Is(err, ErrRange); got != tt.wantErr {
+				t.Errorf("Segmented(%d, %d) error = %v, want ErrRange %v", tt.lo, tt.hi, err, tt.wantErr)
+			}
+			_, err = Parallel(done, tt.lo, tt.hi, 2)
+			if got := errors.Is(err, ErrRange); got != tt.wantErr {
+				t.Errorf("Parallel(%d, %d) error = %v, want ErrRange %v", tt.lo, tt.hi, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Near MaxHi the sieve still stops at hi
+	for _, p := range collect(must(t)(Segmented(done, MaxHi-100, MaxHi))) {
+		if p >= MaxHi || !IsPrime(p) {
+			t.Errorf("Segmented near MaxHi produced %d", p)
+		}
+	}
+}
+
+func TestParallelStopsOnDone(t *testing.T) {
+	done := make(chan struct{})
+	stream := must(t)(Parallel(done, 0, 1<<40, 2))
+	<-stream
+	close(done)
+	for range stream {
+	}
+}
+
+func benchmarkInputs() []int {
+	r := rand.New(rand.NewSource(1))
+	inputs := make([]int, 64)
+	for i := range inputs {
+		inputs[i] = r.Intn(50000000)
+	}
+	return inputs
+}
+
+func BenchmarkTrialDivision(b *testing.B) {
+	inputs := benchmarkInputs()
+	for i := 0; i < b.N; i++ {
+		trialDivision(inputs[i%len(inputs)])
+	}
+}
+
+func BenchmarkIsPrime(b *testing.B) {
+	inputs := benchmarkInputs()
+	for i := 0; i < b.N; i++ {
+		IsPrime(uint64(inputs[i%len(inputs)]))
+	}
+}
+
+func BenchmarkSegmented(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for range must(b)(Segmented(make(chan struct{}), 0, 5000000)) {
+		}
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for range must(b)(Parallel(make(chan struct{}), 0, 5000000, 0)) {
+		}
+	}
+}
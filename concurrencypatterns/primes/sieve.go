@@ -0,0 +1,200 @@
+package primes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+)
+
+// segmentSize is how many numbers one sieve segment covers
+const segmentSize = 1 << 16
+
+// MaxHi is the largest upper bound the sieves accept. The base primes up to
+// sqrt(hi) are sieved in memory, which takes sqrt(MaxHi) bytes (16 MiB).
+const MaxHi = 1 << 48
+
+// ErrRange is returned for a range the sieves can't handle
+var ErrRange = errors.New("primes: range out of bounds")
+
+// checkRange validates [lo, hi) for the sieves
+func checkRange(lo, hi uint64) error {
+	if hi > MaxHi {
+		return fmt.Errorf("%w: hi %d exceeds %d", ErrRange, hi, uint64(MaxHi))
+	}
+	if lo > hi {
+		return fmt.Errorf("%w: lo %d exceeds hi %d", ErrRange, lo, hi)
+	}
+	return nil
+}
+
+// Segmented streams the primes in [lo, hi) in ascending order using a segmented
+// Sieve of Eratosthenes. Memory use is one segment plus the primes up to sqrt(hi).
+// It fails with ErrRange if lo > hi or hi > MaxHi.
+func Segmented[T any](done <-chan T, lo, hi uint64) (<-chan uint64, error) {
+	if err := checkRange(lo, hi); err != nil {
+		return nil, err
+	}
+	stream := make(chan uint64)
+	go func() {
+		defer close(stream)
+		if hi == lo {
+			return
+		}
+		base := basePrimes(isqrt(hi - 1))
+		for segLo := lo; segLo < hi; {
+			segHi := segmentEnd(segLo, hi)
+			for _, p := range sieveSegment(segLo, segHi, base) {
+				select {
+				case <-done:
+					return
+				case stream <- p:
+				}
+			}
+			segLo = segHi
+		}
+	}()
+	return stream, nil
+}
+
+// segment is one unit of work for Parallel. primes receives the result exactly once.
+type segment struct {
+	lo, hi uint64
+	primes chan []uint64
+}
+
+// Parallel streams the primes in [lo, hi) in ascending order, sieving segments
+// on workers goroutines. workers <= 0 means runtime.NumCPU(). It fails with
+// ErrRange if lo > hi or hi > MaxHi.
+func Parallel[T any](done <-chan T, lo, hi uint64, workers int) (<-chan uint64, error) {
+	if err := checkRange(lo, hi); err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	stream := make(chan uint64)
+	if hi == lo {
+		close(stream)
+		return stream, nil
+	}
+	base := basePrimes(isqrt(hi - 1))
+
+	// jobs feeds the workers; order remembers segment order for the emitter
+	// and bounds how far the workers can run ahead of the consumer.
+	jobs := make(chan segment)
+	order := make(chan segment, workers)
+	go func() {
+		defer close(jobs)
+		defer close(order)
+		for segLo := lo; segLo < hi; {
+			seg := segment{lo: segLo, hi: segmentEnd(segLo, hi), primes: make(chan []uint64, 1)}
+			select {
+			case <-done:
+				return
+			case order <- seg:
+			}
+			select {
+			case <-done:
+				return
+			case jobs <- seg:
+			}
+			segLo = seg.hi
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for seg := range jobs {
+				seg.primes <- sieveSegment(seg.lo, seg.hi, base)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(stream)
+		for seg := range order {
+			var found []uint64
+			select {
+			case <-done:
+				return
+			case found = <-seg.primes:
+			}
+			for _, p := range found {
+				select {
+				case <-done:
+					return
+				case stream <- p:
+				}
+			}
+		}
+	}()
+	return stream, nil
+}
+
+// segmentEnd returns the exclusive end of the segment starting at lo
+func segmentEnd(lo, hi uint64) uint64 {
+	if hi-lo < segmentSize {
+		return hi
+	}
+	return lo + segmentSize
+}
+
+// basePrimes returns every prime <= limit with a plain sieve
+func basePrimes(limit uint64) []uint64 {
+	if limit < 2 {
+		return nil
+	}
+	composite := make([]bool, limit+1)
+	var found []uint64
+	for i := uint64(2); i <= limit; i++ {
+		if composite[i] {
+			continue
+		}
+		found = append(found, i)
+		for m := i * i; m <= limit; m += i {
+			composite[m] = true
+		}
+	}
+	return found
+}
+
+// sieveSegment returns the primes in [lo, hi) given every prime <= sqrt(hi-1)
+func sieveSegment(lo, hi uint64, base []uint64) []uint64 {
+	composite := make([]bool, hi-lo)
+	for _, p := range base {
+		if p*p >= hi {
+			break
+		}
+		start := p * p
+		if start < lo {
+			start = (lo + p - 1) / p * p
+		}
+		for m := start; m < hi; m += p {
+			composite[m-lo] = true
+			if hi-m <= p {
+				break // m+p would reach hi, or wrap around near the top of uint64
+			}
+		}
+	}
+
+	var found []uint64
+	for i, c := range composite {
+		if n := lo + uint64(i); !c && n >= 2 {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// isqrt returns floor(sqrt(n))
+func isqrt(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	for r*r > n {
+		r--
+	}
+	for (r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
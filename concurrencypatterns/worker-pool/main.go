@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"time"
+	"worker-pool/workerpool"
+)
+
+// slowSquare simulates a job whose duration varies like the prime checks in prime-fan-in-fan-out
+func slowSquare(n int) workerpool.Job[int] {
+	return func(ctx context.Context) (int, error) {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Duration(50+rand.Intn(250)) * time.Millisecond):
+			return n * n, nil
+		}
+	}
+}
+
+func main() {
+	t := time.Now()
+	ctx := context.Background()
+
+	pool := workerpool.New[int](workerpool.Config{
+		MinWorkers:     1,
+		MaxWorkers:     runtime.NumCPU() * 4,
+		QueueSize:      100,
+		ScaleUpLatency: 100 * time.Millisecond,
+		IdleTimeout:    time.Second,
+	})
+
+	// Report the worker count while the load comes and goes
+	stopReporting := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopReporting:
+				return
+			case <-ticker.C:
+				fmt.Println("workers:", pool.Workers())
+			}
+		}
+	}()
+
+	// A burst of work makes the pool grow
+	futures := make([]*workerpool.Future[int], 0, 50)
+	for i := 1; i <= 50; i++ {
+		f, err := pool.Submit(ctx, slowSquare(i))
+		if err != nil {
+			fmt.Println("submit failed:", err)
+			continue
+		}
+		futures = append(futures, f)
+	}
+
+	// A panicking job fails its own future without killing a worker
+	bad, _ := pool.Submit(ctx, func(ctx context.Context) (int, error) {
+		panic("bad input")
+	})
+
+	sum := 0
+	for _, f := range futures {
+		n, err := f.Wait(ctx)
+		if err != nil {
+			fmt.Println("job failed:", err)
+			continue
+		}
+		sum += n
+	}
+	fmt.Println("sum of squares:", sum)
+
+	var panicErr *workerpool.PanicError
+	if _, err := bad.Wait(ctx); errors.As(err, &panicErr) {
+		fmt.Println("recovered:", panicErr.Value)
+	}
+
+	// Idle workers retire back down to MinWorkers
+	time.Sleep(2500 * time.Millisecond)
+	close(stopReporting)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("shutdown:", err)
+	}
+
+	fmt.Println(time.Since(t))
+}
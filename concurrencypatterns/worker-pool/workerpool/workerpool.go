@@ -0,0 +1,300 @@
+// Package workerpool runs jobs on a pool of goroutines that grows when jobs
+// wait too long in the queue and shrinks when workers sit idle.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPoolClosed is returned when submitting to, or abandoning jobs in, a pool that is shutting down
+var ErrPoolClosed = errors.New("workerpool: pool is shut down")
+
+// PanicError is the error a Future reports when its job panicked
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("workerpool: job panicked: %v", e.Value)
+}
+
+// Job is a unit of work. ctx is cancelled when the submitter's context is
+// cancelled or the pool is forced to stop.
+type Job[T any] func(ctx context.Context) (T, error)
+
+// Future holds the eventual result of a submitted job
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Done is closed once the job has finished
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the job has finished or ctx is done
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+// Config controls the size of a WorkerPool. Zero values get sensible defaults.
+type Config struct {
+	MinWorkers     int           // workers kept alive even when idle (default 1)
+	MaxWorkers     int           // upper bound on workers (default MinWorkers)
+	QueueSize      int           // jobs that may wait for a worker (default MaxWorkers)
+	ScaleUpLatency time.Duration // queue wait that triggers a new worker (default 100ms)
+	IdleTimeout    time.Duration // idle time after which a worker above MinWorkers exits (default 10s)
+}
+
+type task[T any] struct {
+	ctx    context.Context
+	job    Job[T]
+	future *Future[T]
+	queued time.Time
+}
+
+// WorkerPool runs jobs returning T on between MinWorkers and MaxWorkers goroutines
+type WorkerPool[T any] struct {
+	cfg   Config
+	tasks chan task[T]
+
+	// mu guards closed and every send on tasks, so Shutdown can close it safely
+	mu     sync.RWMutex
+	closed bool
+
+	workersMu sync.Mutex
+	workers   int
+
+	busy        atomic.Int64
+	lastDequeue atomic.Int64 // unix nanoseconds
+
+	wg       sync.WaitGroup
+	stop     chan struct{} // closed first by Shutdown, waking blocked Submits
+	stopOnce sync.Once
+	abort    context.Context
+	kill     context.CancelFunc
+}
+
+// New starts a WorkerPool with cfg.MinWorkers workers
+func New[T any](cfg Config) *WorkerPool[T] {
+	if cfg.MinWorkers < 1 {
+		cfg.MinWorkers = 1
+	}
+	if cfg.MaxWorkers < cfg.MinWorkers {
+		cfg.MaxWorkers = cfg.MinWorkers
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = cfg.MaxWorkers
+	}
+	if cfg.ScaleUpLatency <= 0 {
+		cfg.ScaleUpLatency = 100 * time.Millisecond
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 10 * time.Second
+	}
+
+	abort, kill := context.WithCancel(context.Background())
+	p := &WorkerPool[T]{
+		cfg:   cfg,
+		tasks: make(chan task[T], cfg.QueueSize),
+		stop:  make(chan struct{}),
+		abort: abort,
+		kill:  kill,
+	}
+	p.lastDequeue.Store(time.Now().UnixNano())
+
+	p.workersMu.Lock()
+	for i := 0; i < cfg.MinWorkers; i++ {
+		p.spawn()
+	}
+	p.workersMu.Unlock()
+
+	p.wg.Add(1)
+	go p.supervise()
+	return p
+}
+
+// Submit queues job and returns its Future. It blocks while the queue is full
+// and fails with ctx.Err() if ctx is done first, or ErrPoolClosed after Shutdown.
+func (p *WorkerPool[T]) Submit(ctx context.Context, job Job[T]) (*Future[T], error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	f := &Future[T]{done: make(chan struct{})}
+	select {
+	case p.tasks <- task[T]{ctx: ctx, job: job, future: f, queued: time.Now()}:
+		return f, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.stop:
+		return nil, ErrPoolClosed
+	}
+}
+
+// Shutdown stops accepting jobs and waits for queued and in-flight jobs to finish.
+// If ctx is done first, running jobs are cancelled, jobs still queued fail with
+// ErrPoolClosed, and ctx.Err() is returned.
+func (p *WorkerPool[T]) Shutdown(ctx context.Context) error {
+	// Closing stop before taking mu releases any Submit holding the read
+	// lock while it waits on a full queue
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.kill()
+		return nil
+	case <-ctx.Done():
+		p.kill()
+		return ctx.Err()
+	}
+}
+
+// Workers returns the current number of workers
+func (p *WorkerPool[T]) Workers() int {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	return p.workers
+}
+
+// spawn starts a worker. Callers must hold workersMu.
+func (p *WorkerPool[T]) spawn() {
+	p.workers++
+	p.wg.Add(1)
+	go p.worker()
+}
+
+// scaleUp adds a worker unless the pool is already at MaxWorkers
+func (p *WorkerPool[T]) scaleUp() {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if p.workers < p.cfg.MaxWorkers {
+		p.spawn()
+	}
+}
+
+// retire removes the calling worker if the pool is above MinWorkers
+func (p *WorkerPool[T]) retire() bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if p.workers > p.cfg.MinWorkers {
+		p.workers--
+		return true
+	}
+	return false
+}
+
+func (p *WorkerPool[T]) worker() {
+	defer p.wg.Done()
+	idle := time.NewTimer(p.cfg.IdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case t, ok := <-p.tasks:
+			if !ok {
+				p.workersMu.Lock()
+				p.workers--
+				p.workersMu.Unlock()
+				return
+			}
+			p.lastDequeue.Store(time.Now().UnixNano())
+			if time.Since(t.queued) > p.cfg.ScaleUpLatency {
+				p.scaleUp()
+			}
+			p.run(t)
+			idle.Reset(p.cfg.IdleTimeout)
+		case <-idle.C:
+			if p.retire() {
+				return
+			}
+			idle.Reset(p.cfg.IdleTimeout)
+		}
+	}
+}
+
+// supervise adds a worker when jobs are queued, every worker is busy and
+// nothing has been dequeued for longer than ScaleUpLatency.
+func (p *WorkerPool[T]) supervise() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.ScaleUpLatency / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			stalled := time.Since(time.Unix(0, p.lastDequeue.Load())) > p.cfg.ScaleUpLatency
+			if len(p.tasks) > 0 && stalled && int(p.busy.Load()) >= p.Workers() {
+				p.scaleUp()
+			}
+		}
+	}
+}
+
+// run executes one task and completes its future, converting panics into errors
+func (p *WorkerPool[T]) run(t task[T]) {
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+
+	var zero T
+	if p.abort.Err() != nil {
+		t.future.complete(zero, ErrPoolClosed)
+		return
+	}
+	if err := t.ctx.Err(); err != nil {
+		t.future.complete(zero, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.abort, cancel)
+	defer stop()
+
+	t.future.complete(call(ctx, t.job))
+}
+
+func call[T any](ctx context.Context, job Job[T]) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return job(ctx)
+}
@@ -0,0 +1,178 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubmitReturnsResult(t *testing.T) {
+	pool := New[int](Config{MinWorkers: 2})
+	defer pool.Shutdown(context.Background())
+
+	f, err := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.Wait(context.Background())
+	if err != nil || got != 42 {
+		t.Errorf("Wait() = %d, %v, want 42, nil", got, err)
+	}
+}
+
+func TestPanicIsIsolated(t *testing.T) {
+	pool := New[int](Config{MinWorkers: 1})
+	defer pool.Shutdown(context.Background())
+
+	bad, _ := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	if _, err := bad.Wait(context.Background()); !errors.As(err, &panicErr) {
+		t.Fatalf("Wait() error = %v, want *PanicError", err)
+	}
+
+	// The single worker must survive to run the next job
+	good, _ := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if _, err := good.Wait(context.Background()); err != nil {
+		t.Errorf("job after panic failed: %v", err)
+	}
+}
+
+func TestShutdownDrainsQueuedJobs(t *testing.T) {
+	pool := New[int](Config{MinWorkers: 1, QueueSize: 10})
+
+	var finished atomic.Int64
+	for i := 0; i < 10; i++ {
+		_, err := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+			time.Sleep(5 * time.Millisecond)
+			finished.Add(1)
+			return 0, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := finished.Load(); n != 10 {
+		t.Errorf("%d jobs finished before Shutdown returned, want 10", n)
+	}
+	if _, err := pool.Submit(context.Background(), nil); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Submit after Shutdown = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestShutdownDeadlineCancelsJobs(t *testing.T) {
+	pool := New[int](Config{MinWorkers: 1})
+
+	started := make(chan struct{})
+	f, _ := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+	}
+	if _, err := f.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("in-flight job error = %v, want context.Canceled", err)
+	}
+}
+
+func TestShutdownReleasesBlockedSubmit(t *testing.T) {
+	pool := New[int](Config{MinWorkers: 1, QueueSize: 1})
+
+	// Occupy the worker and fill the queue, so the next Submit blocks
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := func(ctx context.Context) (int, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return 0, nil
+	}
+	pool.Submit(context.Background(), block)
+	<-started
+	pool.Submit(context.Background(), block)
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := pool.Submit(context.Background(), block)
+		submitted <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // let Submit block on the full queue
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- pool.Shutdown(ctx) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown ignored its deadline behind a blocked Submit")
+	}
+	if err := <-submitted; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("blocked Submit = %v, want ErrPoolClosed", err)
+	}
+	close(release)
+}
+
+func TestPoolScalesUpAndDown(t *testing.T) {
+	pool := New[int](Config{
+		MinWorkers:     1,
+		MaxWorkers:     4,
+		QueueSize:      20,
+		ScaleUpLatency: 10 * time.Millisecond,
+		IdleTimeout:    50 * time.Millisecond,
+	})
+	defer pool.Shutdown(context.Background())
+
+	var futures []*Future[int]
+	for i := 0; i < 20; i++ {
+		f, _ := pool.Submit(context.Background(), func(ctx context.Context) (int, error) {
+			time.Sleep(20 * time.Millisecond)
+			return 0, nil
+		})
+		futures = append(futures, f)
+	}
+
+	peak := 0
+	for _, f := range futures {
+		f.Wait(context.Background())
+		if n := pool.Workers(); n > peak {
+			peak = n
+		}
+	}
+	if peak < 2 {
+		t.Errorf("peak workers = %d, want the pool to scale up", peak)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Workers() > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := pool.Workers(); n != 1 {
+		t.Errorf("workers after idling = %d, want 1", n)
+	}
+}
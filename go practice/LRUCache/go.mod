@@ -0,0 +1,7 @@
+module lrucache
+
+go 1.23.2
+
+require shared v0.0.0
+
+replace shared => ../../webserver/shared
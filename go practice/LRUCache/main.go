@@ -2,8 +2,11 @@ package main
 
 import (
 	"container/list" // For doubly linked list implementation
+	"flag"
 	"fmt"
-	"sync" // For mutex to make cache thread-safe
+	"os"
+	"shared/metrics" // Prometheus-style counters and gauges
+	"sync"           // For mutex to make cache thread-safe
 )
 
 // Pair represents a key-value pair stored in the cache
@@ -18,9 +21,16 @@ type Pair[K comparable, V any] struct {
 // Generic types K (key) and V (value) allow for flexible usage
 type LRUCache[K comparable, V any] struct {
 	cache    map[K]*list.Element // Maps keys to doubly linked list nodes
-	list     *list.List         // Doubly linked list to maintain access order
-	mutex    sync.Mutex         // Ensures thread-safety for cache operations
-	capacity int                // Maximum number of items cache can hold
+	list     *list.List          // Doubly linked list to maintain access order
+	mutex    sync.Mutex          // Ensures thread-safety for cache operations
+	capacity int                 // Maximum number of items cache can hold
+	metrics  *cacheMetrics       // Lookup and eviction counters, nil until Instrument is called
+}
+
+// cacheMetrics holds the counters an instrumented cache updates
+type cacheMetrics struct {
+	lookups   *metrics.Counter // Lookups by result, hit or miss
+	evictions *metrics.Counter // Entries dropped to make room
 }
 
 // NewLRUCache creates and initializes a new LRU cache with specified capacity
@@ -28,9 +38,30 @@ type LRUCache[K comparable, V any] struct {
 func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
 	return &LRUCache[K, V]{
 		capacity: capacity,
-		list:     list.New(),                    // Initialize empty doubly linked list
-		cache:    make(map[K]*list.Element),     // Initialize empty map
+		list:     list.New(),                // Initialize empty doubly linked list
+		cache:    make(map[K]*list.Element), // Initialize empty map
+	}
+}
+
+// Instrument registers the cache's metrics on reg: lookups by result,
+// evictions, and the number of entries, which is computed when scraped
+func (c *LRUCache[K, V]) Instrument(reg *metrics.Registry) {
+	m := &cacheMetrics{
+		lookups:   reg.NewCounter("lru_cache_lookups_total", "Cache lookups by result.", "result"),
+		evictions: reg.NewCounter("lru_cache_evictions_total", "Entries evicted to make room."),
 	}
+	reg.NewGaugeFunc("lru_cache_entries", "Entries in the cache.", func() float64 { return float64(c.Len()) })
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.metrics = m
+}
+
+// Len returns the number of items in the cache
+func (c *LRUCache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.list.Len()
 }
 
 // Get retrieves a value from the cache by its key
@@ -41,8 +72,14 @@ func (c *LRUCache[K, V]) Get(key K) (V, bool) {
 	defer c.mutex.Unlock() // Ensures mutex is unlocked even if panic occurs
 
 	if elem, ok := c.cache[key]; ok {
-		c.list.MoveToFront(elem)                    // Mark as most recently used
-		return elem.Value.(Pair[K, V]).value, true  // Type assert and return value
+		if c.metrics != nil {
+			c.metrics.lookups.Inc("hit")
+		}
+		c.list.MoveToFront(elem)                   // Mark as most recently used
+		return elem.Value.(Pair[K, V]).value, true // Type assert and return value
+	}
+	if c.metrics != nil {
+		c.metrics.lookups.Inc("miss")
 	}
 	var zeroValue V // Return zero value if key not found
 	return zeroValue, false
@@ -67,6 +104,9 @@ func (c *LRUCache[K, V]) Put(key K, val V) {
 			if oldest != nil {
 				c.list.Remove(oldest)
 				delete(c.cache, oldest.Value.(Pair[K, V]).key)
+				if c.metrics != nil {
+					c.metrics.evictions.Inc()
+				}
 			}
 		}
 		// Add new item to front of list and map
@@ -82,14 +122,19 @@ func (c *LRUCache[K, V]) Remove(key K) {
 	defer c.mutex.Unlock()
 
 	if elem, ok := c.cache[key]; ok {
-		delete(c.cache, key)    // Remove from map
-		c.list.Remove(elem)     // Remove from list
+		delete(c.cache, key) // Remove from map
+		c.list.Remove(elem)  // Remove from list
 	}
 }
 
 func main() {
-	// Create new cache with capacity 3
+	showMetrics := flag.Bool("metrics", false, "print the cache metrics at exit")
+	flag.Parse()
+
+	// Create new cache with capacity 3, counting its lookups and evictions
 	cache := NewLRUCache[string, int](3)
+	reg := metrics.NewRegistry()
+	cache.Instrument(reg)
 
 	// Add three items
 	cache.Put("one", 1)
@@ -116,4 +161,10 @@ func main() {
 	if _, ok := cache.Get("three"); !ok {
 		fmt.Println("Key 'three' has been removed from the cache")
 	}
-}
\ No newline at end of file
+
+	if *showMetrics {
+		if _, err := reg.WriteTo(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "writing metrics:", err)
+		}
+	}
+}
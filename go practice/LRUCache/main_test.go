@@ -0,0 +1,35 @@
+package main
+
+import (
+	"shared/metrics"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentedCache(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	reg := metrics.NewRegistry()
+	cache.Instrument(reg)
+
+	cache.Put("one", 1)
+	cache.Put("two", 2)
+	cache.Get("one")
+	cache.Put("three", 3) // evicts "two"
+	cache.Get("two")
+	cache.Get("three")
+
+	var out strings.Builder
+	if _, err := reg.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`lru_cache_entries 2`,
+		`lru_cache_evictions_total 1`,
+		`lru_cache_lookups_total{result="hit"} 2`,
+		`lru_cache_lookups_total{result="miss"} 1`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
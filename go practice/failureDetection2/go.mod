@@ -0,0 +1,7 @@
+module failureDetection2
+
+go 1.23.2
+
+require lifecycle v0.0.0
+
+replace lifecycle => ../../concurrencypatterns/lifecycle
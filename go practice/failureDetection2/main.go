@@ -3,19 +3,22 @@ package main
 import (
 	"context"
 	"fmt"
+	"lifecycle"
 	"math/rand"
 	"time"
 )
 
-func numberGenerator(ctx context.Context) chan any{
+// numberGenerator streams random numbers until ctx is done, then sends why
+// ctx ended and closes the stream
+func numberGenerator(ctx context.Context) chan any {
 	stream := make(chan any)
-	go func(){
+	go func() {
 		defer close(stream)
-		for{
-			select{
-			case <- ctx.Done():
+		for {
+			select {
+			case <-ctx.Done():
 				fmt.Println("context cancelled")
-				stream <- ctx.Err()
+				stream <- context.Cause(ctx)
 				return
 			case stream <- rand.Intn(100):
 			}
@@ -25,20 +28,21 @@ func numberGenerator(ctx context.Context) chan any{
 }
 
 func main() {
-	// ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Second*10))
-	ctx, cancel := context.WithTimeout(context.Background(),time.Second*10)
+	// Run for ten seconds, or until Ctrl+C or SIGTERM
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 	numberStream := numberGenerator(ctx)
-	for num := range numberStream{
-		time.Sleep(time.Second/4)
-		switch num.(type){
+	for num := range numberStream {
+		switch num := num.(type) {
 		case int:
 			fmt.Println(num)
+			// Pace the output; the generator reports when ctx ends
+			lifecycle.Sleep(ctx, time.Second/4)
 		case error:
 			fmt.Println(num)
 			return
 		}
 	}
-
-
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"failuredetection/membership"
+	"fmt"
+	"lifecycle"
+	"time"
+)
+
+// addCluster adds three UDP peers on localhost that watch each other to group.
+// Node "c" crashes after three seconds, without saying goodbye, so the others
+// see it go suspect and then dead.
+func addCluster(group *lifecycle.Group) error {
+	names := []string{"a", "b", "c"}
+	transports := make([]*membership.UDPTransport, len(names))
+	for i := range names {
+		transport, err := membership.ListenUDP("127.0.0.1:0")
+		if err != nil {
+			for _, t := range transports[:i] {
+				t.Close()
+			}
+			return err
+		}
+		transports[i] = transport
+	}
+
+	for i, name := range names {
+		transport := transports[i]
+		node := membership.New(membership.Config{
+			Name:              name,
+			Transport:         transport,
+			HeartbeatInterval: 500 * time.Millisecond,
+		})
+		// b and c only know node a, which learns about them from their heartbeats
+		if i > 0 {
+			node.AddPeer(names[0], transports[0].Addr())
+		}
+		if name == "c" {
+			time.AfterFunc(3*time.Second, func() { transport.Close() })
+		}
+
+		group.Add(name, lifecycle.Func(func(ctx context.Context) error {
+			go func() {
+				for e := range node.Events() {
+					fmt.Printf("[%s] %v\n", name, e)
+				}
+			}()
+			err := node.Run(ctx)
+			if ctx.Err() == nil {
+				// A crashed node must not take the rest of the cluster down with it
+				fmt.Printf("[%s] crashed: %v\n", name, err)
+				<-ctx.Done()
+			}
+			return err
+		}))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+module failuredetection
+
+go 1.23.2
+
+require (
+	lifecycle v0.0.0
+	shared v0.0.0
+)
+
+replace (
+	lifecycle => ../../concurrencypatterns/lifecycle
+	shared => ../../webserver/shared
+)
@@ -1,13 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"failuredetection/phi"
+	"flag"
 	"fmt"
+	"lifecycle"
+	"math/rand"
+	"net/http"
+	"shared/health"
 	"time"
 )
 
-func Heartbeat(heartbeatChan chan<- struct{}, interval time.Duration) {
+// ErrFailureDetected is returned by Monitor when the heartbeat stops
+var ErrFailureDetected = errors.New("heartbeat overdue, failure detected")
+
+// Heartbeat sends a heartbeat roughly every interval, randomly early or late by
+// up to jitter, until ctx is done. It returns why ctx ended.
+func Heartbeat(ctx context.Context, heartbeatChan chan<- struct{}, interval, jitter time.Duration) error {
 	for {
-		time.Sleep(interval)
+		delay := interval
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(delay):
+		}
+
 		select {
 		case heartbeatChan <- struct{}{}:
 			fmt.Println("Heartbeat sent")
@@ -17,44 +39,116 @@ func Heartbeat(heartbeatChan chan<- struct{}, interval time.Duration) {
 	}
 }
 
-func Monitor(heartbeatChan <-chan struct{}, timeout time.Duration) {
+// Monitor feeds heartbeats into the phi-accrual detector and checks the
+// suspicion level every checkInterval. It returns ErrFailureDetected once phi
+// crosses the threshold, or why ctx ended. A detector that has not seen a
+// heartbeat yet is seeded with the start time, so a peer that never sends one
+// is suspected too.
+func Monitor(ctx context.Context, heartbeatChan <-chan struct{}, detector *phi.Detector, checkInterval time.Duration) error {
+	if detector.LastHeartbeat().IsZero() {
+		detector.Heartbeat(time.Now())
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
 		case <-heartbeatChan:
+			detector.Heartbeat(time.Now())
 			fmt.Println("Heartbeat received")
-		case <-time.After(timeout):
-			fmt.Println("Heartbeat timeout, failure detected")
-			return
+		case now := <-ticker.C:
+			suspicion := detector.Phi(now)
+			if suspicion >= detector.Threshold() {
+				return fmt.Errorf("%w (phi %.2f)", ErrFailureDetected, suspicion)
+			}
+			fmt.Printf("phi %.2f\n", suspicion)
 		}
 	}
 }
+
 func main() {
+	duration := flag.Duration("duration", 10*time.Second, "how long to run; 0 runs until SIGINT or SIGTERM")
+	cluster := flag.Bool("cluster", false, "run the multi-peer membership demo over UDP instead")
+	httpAddr := flag.String("http", "", "serve /healthz and /readyz for the monitored heartbeat on this address, e.g. :8081")
+	flag.Parse()
+
+	// Ctrl+C or SIGTERM cancels ctx, and every component stops cleanly
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	group := &lifecycle.Group{
+		OnStop: func(name string, err error) {
+			fmt.Printf("%s stopped: %v\n", name, err)
+		},
+	}
+	if *cluster {
+		if err := addCluster(group); err != nil {
+			fmt.Println("Main:", err)
+			return
+		}
+	} else {
+		detector := addHeartbeatMonitor(group)
+		if *httpAddr != "" {
+			addHealthServer(group, *httpAddr, detector)
+		}
+	}
+
+	err := group.Run(ctx)
+	fmt.Println("Main: shutting down:", err)
+}
+
+// addHeartbeatMonitor adds the single heartbeat and monitor pair to group and
+// returns the detector the monitor feeds
+func addHeartbeatMonitor(group *lifecycle.Group) *phi.Detector {
 	heartbeatChan := make(chan struct{}, 1)
 	heartbeatInterval := 1 * time.Second
-	heartbeatTimeout := 3 * time.Second
-
-	// Start the heartbeat goroutine
-	go Heartbeat(heartbeatChan, heartbeatInterval)
-
-	// Start the monitor goroutine
-	go Monitor(heartbeatChan, heartbeatTimeout)
-
-	// // Enhancement: Add a way to gracefully shut down the goroutines
-	// stopChan := make(chan struct{})
-	// go func() {
-	// 	time.Sleep(time.Second * 5)
-	// 	fmt.Println("Simulating Ctrl+C signal")
-	// 	// Create a channel to receive OS signals
-	// 	sigChan := make(chan os.Signal, 1)
-	// 	// Notify the channel for SIGINT (Ctrl+C)
-	// 	signal.Notify(sigChan, os.Interrupt)
-	// 	// Wait for the signal
-	// 	<-sigChan
-	// 	fmt.Println("Ctrl+C received, initiating shutdown")
-	// 	close(stopChan)
-	// }()
-
-	// <-stopChan
-	time.Sleep(time.Second * 10)
-	fmt.Println("Main: shutting down")
+	heartbeatJitter := 300 * time.Millisecond
+
+	detector := phi.New(phi.Config{
+		Threshold:              8,
+		FirstHeartbeatEstimate: heartbeatInterval,
+		AcceptablePause:        500 * time.Millisecond,
+	})
+
+	group.Add("heartbeat", lifecycle.Func(func(ctx context.Context) error {
+		return Heartbeat(ctx, heartbeatChan, heartbeatInterval, heartbeatJitter)
+	}))
+	group.Add("monitor", lifecycle.Func(func(ctx context.Context) error {
+		return Monitor(ctx, heartbeatChan, detector, 500*time.Millisecond)
+	}))
+	return detector
+}
+
+// addHealthServer adds an HTTP server to group whose probes report the
+// detector's view of the heartbeat
+func addHealthServer(group *lifecycle.Group, addr string, detector *phi.Detector) {
+	checks := health.NewRegistry()
+	// Monitor seeds the detector at start, so this passes for maxAge before
+	// the first real heartbeat and fails after that if none arrives
+	checks.Register("heartbeat", health.Heartbeat(detector.LastHeartbeat, 5*time.Second))
+	checks.Register("phi", health.Detector(detector), health.ReadinessOnly())
+
+	mux := http.NewServeMux()
+	checks.Mount(mux)
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	group.Add("http", lifecycle.Func(func(ctx context.Context) error {
+		errc := make(chan error, 1)
+		go func() { errc <- server.ListenAndServe() }()
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+			return context.Cause(ctx)
+		}
+	}))
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"failuredetection/phi"
+	"testing"
+	"time"
+)
+
+func TestMonitorDetectsFailure(t *testing.T) {
+	heartbeatChan := make(chan struct{}, 1)
+	heartbeatChan <- struct{}{} // one heartbeat, then silence
+	detector := phi.New(phi.Config{FirstHeartbeatEstimate: 20 * time.Millisecond, MinStdDev: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Monitor(ctx, heartbeatChan, detector, 10*time.Millisecond); !errors.Is(err, ErrFailureDetected) {
+		t.Errorf("Monitor() = %v, want ErrFailureDetected", err)
+	}
+}
+
+func TestMonitorDetectsSilentPeer(t *testing.T) {
+	heartbeatChan := make(chan struct{}) // the peer never sends a heartbeat
+	detector := phi.New(phi.Config{FirstHeartbeatEstimate: 20 * time.Millisecond, MinStdDev: 5 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Monitor(ctx, heartbeatChan, detector, 10*time.Millisecond); !errors.Is(err, ErrFailureDetected) {
+		t.Errorf("Monitor() = %v, want ErrFailureDetected", err)
+	}
+}
+
+func TestHeartbeatStopsWithCause(t *testing.T) {
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	heartbeatChan := make(chan struct{}, 1)
+
+	go func() {
+		<-heartbeatChan
+		cancel(errStop)
+	}()
+	if err := Heartbeat(ctx, heartbeatChan, 10*time.Millisecond, 0); !errors.Is(err, errStop) {
+		t.Errorf("Heartbeat() = %v, want the cancellation cause", err)
+	}
+}
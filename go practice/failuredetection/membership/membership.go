@@ -0,0 +1,331 @@
+// Package membership tracks the health of many named peers on top of the
+// phi-accrual detector. Every peer runs through the state machine
+//
+//	alive → suspect → dead, and → left when it announces a graceful exit
+//
+// and every transition is published as an Event. Each node carries an
+// incarnation number that grows every time it starts, so a node that rejoins
+// after being declared dead is told apart from stale messages of its old self.
+// Heartbeats also carry the sender's view of the recipient: a node that hears
+// it is suspected or dead refutes it by bumping its incarnation, so it is
+// trusted again once a partition heals.
+package membership
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"failuredetection/phi"
+)
+
+// State is where a peer is in its life cycle
+type State int
+
+const (
+	Unknown State = iota // configured but never heard from
+	Alive
+	Suspect
+	Dead
+	Left
+)
+
+func (s State) String() string {
+	switch s {
+	case Unknown:
+		return "unknown"
+	case Alive:
+		return "alive"
+	case Suspect:
+		return "suspect"
+	case Dead:
+		return "dead"
+	case Left:
+		return "left"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Member is a snapshot of one peer
+type Member struct {
+	Name        string
+	Addr        string
+	State       State
+	Incarnation uint64
+	Since       time.Time // when State was entered
+}
+
+// Event reports a peer changing state
+type Event struct {
+	Member      string
+	From, To    State
+	Incarnation uint64
+	At          time.Time
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s: %s → %s (incarnation %d)", e.Member, e.From, e.To, e.Incarnation)
+}
+
+// Config configures a Membership. Zero values get the defaults noted on each field.
+type Config struct {
+	Name      string
+	Transport Transport
+	// Incarnation of this node (default: the start time in nanoseconds, so restarts increase it)
+	Incarnation uint64
+	// HeartbeatInterval is how often heartbeats are sent and peers re-evaluated (default 1s)
+	HeartbeatInterval time.Duration
+	// Detector configures the per-peer phi-accrual detector
+	Detector phi.Config
+	// SuspectPhi marks a peer suspect (default 5)
+	SuspectPhi float64
+	// DeadPhi marks a suspect peer dead (default Detector.Threshold, or 8)
+	DeadPhi float64
+	// EventBuffer is the capacity of the Events channel (default 128)
+	EventBuffer int
+}
+
+type peer struct {
+	member   Member
+	detector *phi.Detector
+}
+
+// Membership tracks a set of named peers. Start it with Run.
+type Membership struct {
+	cfg    Config
+	events chan Event
+
+	mu          sync.Mutex
+	incarnation uint64
+	peers       map[string]*peer
+}
+
+// New creates a Membership for the local node cfg.Name
+func New(cfg Config) *Membership {
+	if cfg.Incarnation == 0 {
+		cfg.Incarnation = uint64(time.Now().UnixNano())
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = time.Second
+	}
+	if cfg.Detector.FirstHeartbeatEstimate <= 0 {
+		cfg.Detector.FirstHeartbeatEstimate = cfg.HeartbeatInterval
+	}
+	if cfg.SuspectPhi <= 0 {
+		cfg.SuspectPhi = 5
+	}
+	if cfg.DeadPhi <= 0 {
+		cfg.DeadPhi = cfg.Detector.Threshold
+	}
+	if cfg.DeadPhi <= 0 {
+		cfg.DeadPhi = 8
+	}
+	if cfg.EventBuffer <= 0 {
+		cfg.EventBuffer = 128
+	}
+	return &Membership{
+		cfg:         cfg,
+		events:      make(chan Event, cfg.EventBuffer),
+		incarnation: cfg.Incarnation,
+		peers:       make(map[string]*peer),
+	}
+}
+
+// Incarnation returns this node's current incarnation. It starts at
+// cfg.Incarnation and grows each time the node refutes a suspicion.
+func (m *Membership) Incarnation() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.incarnation
+}
+
+// Events streams state transitions. It is closed when Run returns.
+// Run blocks while the channel is full, so it must be drained.
+func (m *Membership) Events() <-chan Event {
+	return m.events
+}
+
+// AddPeer registers a peer to send heartbeats to. Peers that contact this node
+// first are added automatically. The peer's detector is seeded with the time it
+// was added, so a peer that never answers is suspected and then declared dead.
+func (m *Membership) AddPeer(name, addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.peers[name]; !ok && name != m.cfg.Name {
+		now := time.Now()
+		detector := phi.New(m.cfg.Detector)
+		detector.Heartbeat(now)
+		m.peers[name] = &peer{member: Member{Name: name, Addr: addr, Since: now}, detector: detector}
+	}
+}
+
+// Members returns every known peer sorted by name
+func (m *Membership) Members() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make([]Member, 0, len(m.peers))
+	for _, p := range m.peers {
+		members = append(members, p.member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// Member returns the named peer
+func (m *Membership) Member(name string) (Member, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.peers[name]
+	if !ok {
+		return Member{}, false
+	}
+	return p.member, true
+}
+
+// Run sends heartbeats, processes incoming messages and evaluates peers until
+// ctx is done. On the way out it tells every peer this node is leaving and
+// closes the transport.
+func (m *Membership) Run(ctx context.Context) error {
+	defer close(m.events)
+	defer m.cfg.Transport.Close()
+
+	ticker := time.NewTicker(m.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	inbox := m.cfg.Transport.Receive()
+	m.broadcast(false)
+	for {
+		select {
+		case <-ctx.Done():
+			m.broadcast(true)
+			return context.Cause(ctx)
+		case msg, ok := <-inbox:
+			if !ok {
+				return ErrTransportClosed
+			}
+			if m.refute(msg) {
+				// Tell everyone right away rather than at the next tick
+				m.broadcast(false)
+			}
+			m.emit(ctx, m.receive(msg, time.Now()))
+		case now := <-ticker.C:
+			m.broadcast(false)
+			m.emit(ctx, m.evaluate(now))
+		}
+	}
+}
+
+func (m *Membership) broadcast(leaving bool) {
+	msg := Message{
+		From:        m.cfg.Name,
+		Addr:        m.cfg.Transport.Addr(),
+		Incarnation: m.Incarnation(),
+		Leaving:     leaving,
+	}
+	for _, member := range m.Members() {
+		if member.Addr != "" {
+			msg.SeenState, msg.SeenIncarnation = member.State, member.Incarnation
+			m.cfg.Transport.Send(member.Addr, msg)
+		}
+	}
+}
+
+// refute bumps this node's incarnation past the one msg's sender suspects or
+// declared dead, and reports whether it did
+func (m *Membership) refute(msg Message) bool {
+	if msg.From == m.cfg.Name || (msg.SeenState != Suspect && msg.SeenState != Dead) {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if msg.SeenIncarnation < m.incarnation {
+		// Already refuted, or about an earlier life of this node
+		return false
+	}
+	m.incarnation = msg.SeenIncarnation + 1
+	return true
+}
+
+func (m *Membership) emit(ctx context.Context, events []Event) {
+	for _, e := range events {
+		select {
+		case m.events <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive applies a message to the sender's state machine and returns the resulting events
+func (m *Membership) receive(msg Message, now time.Time) []Event {
+	if msg.From == "" || msg.From == m.cfg.Name {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.peers[msg.From]
+	if !ok {
+		p = &peer{member: Member{Name: msg.From, Since: now}}
+		m.peers[msg.From] = p
+	}
+	if msg.Addr != "" {
+		p.member.Addr = msg.Addr
+	}
+
+	switch {
+	case msg.Incarnation < p.member.Incarnation:
+		// A delayed message from an earlier life of this node
+		return nil
+	case msg.Incarnation > p.member.Incarnation:
+		// First contact, or the node restarted: start its history afresh
+		p.member.Incarnation = msg.Incarnation
+		p.detector = phi.New(m.cfg.Detector)
+	case p.member.State == Dead || p.member.State == Left:
+		// Same incarnation as a node already declared gone; it must rejoin
+		// with a higher incarnation to be trusted again.
+		return nil
+	}
+
+	if msg.Leaving {
+		return m.transition(p, Left, now)
+	}
+	p.detector.Heartbeat(now)
+	if p.member.State != Alive {
+		return m.transition(p, Alive, now)
+	}
+	return nil
+}
+
+// evaluate moves peers whose heartbeats are overdue towards dead, including
+// configured peers that have never been heard from
+func (m *Membership) evaluate(now time.Time) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []Event
+	for _, p := range m.peers {
+		if p.detector == nil {
+			continue
+		}
+		suspicion := p.detector.Phi(now)
+		switch {
+		case (p.member.State == Unknown || p.member.State == Alive) && suspicion >= m.cfg.SuspectPhi:
+			events = append(events, m.transition(p, Suspect, now)...)
+		case p.member.State == Suspect && suspicion >= m.cfg.DeadPhi:
+			events = append(events, m.transition(p, Dead, now)...)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Member < events[j].Member })
+	return events
+}
+
+// transition moves p to state. Callers must hold mu.
+func (m *Membership) transition(p *peer, to State, now time.Time) []Event {
+	from := p.member.State
+	p.member.State = to
+	p.member.Since = now
+	return []Event{{Member: p.member.Name, From: from, To: to, Incarnation: p.member.Incarnation, At: now}}
+}
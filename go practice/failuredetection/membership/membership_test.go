@@ -0,0 +1,241 @@
+package membership
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"failuredetection/phi"
+)
+
+const interval = 20 * time.Millisecond
+
+func testConfig(name string, t Transport) Config {
+	return Config{
+		Name:              name,
+		Transport:         t,
+		HeartbeatInterval: interval,
+		Detector:          phi.Config{MinStdDev: 5 * time.Millisecond},
+		SuspectPhi:        3,
+		DeadPhi:           8,
+	}
+}
+
+// node is a running Membership whose events are collected on a channel
+type node struct {
+	m      *Membership
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func start(cfg Config) *node {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &node{m: New(cfg), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(n.done)
+		n.m.Run(ctx)
+	}()
+	return n
+}
+
+func (n *node) stop() {
+	n.cancel()
+	<-n.done
+}
+
+// await drains events until one matches member and state, or fails after a timeout
+func await(t *testing.T, n *node, member string, to State) Event {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e, ok := <-n.m.Events():
+			if !ok {
+				t.Fatalf("events closed while waiting for %s → %s", member, to)
+			}
+			if e.Member == member && e.To == to {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s → %s; members: %+v", member, to, n.m.Members())
+		}
+	}
+}
+
+func TestFailureAndRejoin(t *testing.T) {
+	network := NewChanNetwork()
+	a := start(testConfig("a", network.Join("a")))
+	defer a.stop()
+	a.m.AddPeer("b", "b")
+
+	b := start(Config{Incarnation: 1, Name: "b", Transport: network.Join("b"), HeartbeatInterval: interval})
+	await(t, a, "b", Alive)
+
+	// b crashes without saying goodbye
+	network.Disconnect("b")
+	await(t, a, "b", Suspect)
+	dead := await(t, a, "b", Dead)
+	b.stop()
+
+	// A stale heartbeat from the old incarnation must not resurrect b
+	a.m.receive(Message{From: "b", Addr: "b", Incarnation: 1}, time.Now())
+	if m, _ := a.m.Member("b"); m.State != Dead {
+		t.Fatalf("stale heartbeat moved b to %s", m.State)
+	}
+
+	// b restarts with a higher incarnation and is trusted again
+	restarted := start(Config{Incarnation: 2, Name: "b", Transport: network.Join("b"), HeartbeatInterval: interval})
+	defer restarted.stop()
+	alive := await(t, a, "b", Alive)
+	if alive.From != Dead || alive.Incarnation <= dead.Incarnation {
+		t.Errorf("rejoin event = %v, want dead → alive with a higher incarnation than %d", alive, dead.Incarnation)
+	}
+}
+
+func TestRejoinAfterPartition(t *testing.T) {
+	network := NewChanNetwork()
+	aCfg := testConfig("a", network.Join("a"))
+	aCfg.Incarnation = 1
+	a := start(aCfg)
+	defer a.stop()
+	a.m.AddPeer("b", "b")
+	bCfg := testConfig("b", network.Join("b"))
+	bCfg.Incarnation = 1
+	b := start(bCfg)
+	defer b.stop()
+	await(t, a, "b", Alive)
+	await(t, b, "a", Alive)
+
+	// Each side of the partition declares the other dead, but both keep running
+	network.Disconnect("b")
+	dead := await(t, a, "b", Dead)
+	await(t, b, "a", Dead)
+
+	// Once the partition heals each hears it was declared dead and refutes it
+	// with a higher incarnation
+	network.Reconnect("b")
+	if e := await(t, a, "b", Alive); e.From != Dead || e.Incarnation <= dead.Incarnation {
+		t.Errorf("rejoin event = %v, want dead → alive with a higher incarnation than %d", e, dead.Incarnation)
+	}
+	await(t, b, "a", Alive)
+	if got := b.m.Incarnation(); got <= bCfg.Incarnation {
+		t.Errorf("b's incarnation = %d, want it bumped past %d", got, bCfg.Incarnation)
+	}
+}
+
+func TestRefuteIgnoresStaleSuspicion(t *testing.T) {
+	m := New(Config{Name: "a", Incarnation: 5, Transport: NewChanNetwork().Join("a")})
+	if m.refute(Message{From: "b", SeenState: Dead, SeenIncarnation: 4}) {
+		t.Error("refuted a suspicion about an earlier incarnation")
+	}
+	if m.refute(Message{From: "b", SeenState: Alive, SeenIncarnation: 5}) {
+		t.Error("refuted a peer that sees this node alive")
+	}
+	if !m.refute(Message{From: "b", SeenState: Suspect, SeenIncarnation: 5}) || m.Incarnation() != 6 {
+		t.Errorf("after refuting, incarnation = %d, want 6", m.Incarnation())
+	}
+}
+
+func TestSilentConfiguredPeer(t *testing.T) {
+	network := NewChanNetwork()
+	a := start(testConfig("a", network.Join("a")))
+	defer a.stop()
+	a.m.AddPeer("b", "b") // b is configured but never starts
+
+	if e := await(t, a, "b", Suspect); e.From != Unknown {
+		t.Errorf("suspect event = %v, want unknown → suspect", e)
+	}
+	await(t, a, "b", Dead)
+}
+
+func TestRunStopsWithCause(t *testing.T) {
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	m := New(testConfig("a", NewChanNetwork().Join("a")))
+	go func() {
+		for range m.Events() {
+		}
+	}()
+	cancel(errStop)
+	if err := m.Run(ctx); !errors.Is(err, errStop) {
+		t.Errorf("Run() = %v, want the cancellation cause", err)
+	}
+}
+
+func TestGracefulLeave(t *testing.T) {
+	network := NewChanNetwork()
+	a := start(testConfig("a", network.Join("a")))
+	defer a.stop()
+	a.m.AddPeer("b", "b")
+
+	b := start(testConfig("b", network.Join("b")))
+	await(t, a, "b", Alive)
+
+	b.stop()
+	await(t, a, "b", Left)
+}
+
+func TestSuspectRecovers(t *testing.T) {
+	network := NewChanNetwork()
+	cfg := testConfig("a", network.Join("a"))
+	cfg.DeadPhi = math.MaxFloat64 // stay suspect however long the partition lasts
+	a := start(cfg)
+	defer a.stop()
+	a.m.AddPeer("b", "b")
+	b := start(testConfig("b", network.Join("b")))
+	defer b.stop()
+	await(t, a, "b", Alive)
+
+	network.Disconnect("b")
+	await(t, a, "b", Suspect)
+	network.Reconnect("b")
+	if e := await(t, a, "b", Alive); e.From != Suspect {
+		t.Errorf("recovery event = %v, want suspect → alive", e)
+	}
+}
+
+func TestPeersDiscoveredFromHeartbeats(t *testing.T) {
+	network := NewChanNetwork()
+	a := start(testConfig("a", network.Join("a")))
+	defer a.stop()
+
+	// Only b knows about a; a learns b's address from its heartbeats
+	cfg := testConfig("b", network.Join("b"))
+	b := New(cfg)
+	b.AddPeer("a", "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for range b.Events() {
+		}
+	}()
+	go b.Run(ctx)
+
+	await(t, a, "b", Alive)
+	if m, _ := a.m.Member("b"); m.Addr != "b" {
+		t.Errorf("b's address = %q, want b", m.Addr)
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	ta, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	tb, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		ta.Close()
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+
+	a := start(testConfig("a", ta))
+	defer a.stop()
+	a.m.AddPeer("b", tb.Addr())
+	b := start(testConfig("b", tb))
+
+	await(t, a, "b", Alive)
+	b.stop()
+	await(t, a, "b", Left)
+}
@@ -0,0 +1,213 @@
+package membership
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Message is what peers exchange: a heartbeat, or a notice that the sender is leaving
+type Message struct {
+	From        string `json:"from"`
+	Addr        string `json:"addr"` // where the sender can be reached
+	Incarnation uint64 `json:"incarnation"`
+	Leaving     bool   `json:"leaving,omitempty"`
+	// SeenState and SeenIncarnation are the sender's view of the recipient,
+	// so a recipient that is suspected or declared dead can refute it
+	SeenState       State  `json:"seen_state,omitempty"`
+	SeenIncarnation uint64 `json:"seen_incarnation,omitempty"`
+}
+
+// Transport delivers messages between peers. Delivery is best effort, like UDP:
+// messages may be dropped, and Send does not report whether they arrived.
+type Transport interface {
+	// Addr is the address other peers use to reach this transport
+	Addr() string
+	Send(to string, msg Message) error
+	// Receive streams incoming messages and is closed by Close
+	Receive() <-chan Message
+	Close() error
+}
+
+// ErrTransportClosed is returned when sending on a closed transport
+var ErrTransportClosed = errors.New("membership: transport closed")
+
+// ChanNetwork is an in-process network of ChanTransports, for tests and demos.
+// Nodes can be disconnected to simulate crashes and partitions.
+type ChanNetwork struct {
+	mu           sync.Mutex
+	nodes        map[string]*ChanTransport
+	disconnected map[string]bool
+}
+
+// NewChanNetwork creates an empty network
+func NewChanNetwork() *ChanNetwork {
+	return &ChanNetwork{
+		nodes:        make(map[string]*ChanTransport),
+		disconnected: make(map[string]bool),
+	}
+}
+
+// Join attaches a transport with the given address, replacing any previous one
+func (n *ChanNetwork) Join(addr string) *ChanTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	t := &ChanTransport{network: n, addr: addr, inbox: make(chan Message, 64)}
+	n.nodes[addr] = t
+	delete(n.disconnected, addr)
+	return t
+}
+
+// Disconnect drops every message to and from addr until Reconnect
+func (n *ChanNetwork) Disconnect(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.disconnected[addr] = true
+}
+
+// Reconnect undoes Disconnect
+func (n *ChanNetwork) Reconnect(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.disconnected, addr)
+}
+
+func (n *ChanNetwork) deliver(from, to string, msg Message) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	dst, ok := n.nodes[to]
+	if !ok || dst.closed || n.disconnected[from] || n.disconnected[to] {
+		return
+	}
+	select {
+	case dst.inbox <- msg:
+	default:
+		// Inbox full: drop, as a congested network would
+	}
+}
+
+// ChanTransport is a Transport on a ChanNetwork
+type ChanTransport struct {
+	network *ChanNetwork
+	addr    string
+	inbox   chan Message
+	closed  bool // guarded by network.mu
+}
+
+func (t *ChanTransport) Addr() string { return t.addr }
+
+func (t *ChanTransport) Send(to string, msg Message) error {
+	t.network.mu.Lock()
+	closed := t.closed
+	t.network.mu.Unlock()
+	if closed {
+		return ErrTransportClosed
+	}
+	t.network.deliver(t.addr, to, msg)
+	return nil
+}
+
+func (t *ChanTransport) Receive() <-chan Message { return t.inbox }
+
+func (t *ChanTransport) Close() error {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.inbox)
+		if t.network.nodes[t.addr] == t {
+			delete(t.network.nodes, t.addr)
+		}
+	}
+	return nil
+}
+
+// UDPTransport sends each message as one JSON datagram
+type UDPTransport struct {
+	conn  *net.UDPConn
+	inbox chan Message
+
+	mu    sync.Mutex
+	addrs map[string]*net.UDPAddr
+}
+
+// ListenUDP binds a UDP transport to addr, e.g. "127.0.0.1:7946" or "127.0.0.1:0"
+func ListenUDP(addr string) (*UDPTransport, error) {
+	local, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &UDPTransport{
+		conn:  conn,
+		inbox: make(chan Message, 64),
+		addrs: make(map[string]*net.UDPAddr),
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+func (t *UDPTransport) readLoop() {
+	defer close(t.inbox)
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		var msg Message
+		if json.Unmarshal(buf[:n], &msg) != nil {
+			continue // not one of ours
+		}
+		select {
+		case t.inbox <- msg:
+		default:
+		}
+	}
+}
+
+func (t *UDPTransport) Addr() string { return t.conn.LocalAddr().String() }
+
+func (t *UDPTransport) Send(to string, msg Message) error {
+	addr, err := t.resolve(to)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := t.conn.WriteToUDP(data, addr); err != nil {
+		if errors.Is(err, net.ErrClosed) {
+			return ErrTransportClosed
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *UDPTransport) resolve(to string) (*net.UDPAddr, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if addr, ok := t.addrs[to]; ok {
+		return addr, nil
+	}
+	addr, err := net.ResolveUDPAddr("udp", to)
+	if err != nil {
+		return nil, err
+	}
+	t.addrs[to] = addr
+	return addr, nil
+}
+
+func (t *UDPTransport) Receive() <-chan Message { return t.inbox }
+
+func (t *UDPTransport) Close() error { return t.conn.Close() }
@@ -0,0 +1,162 @@
+// Package phi implements the phi-accrual failure detector (Hayashibara et al.).
+//
+// Instead of a yes/no timeout, the detector keeps a sliding window of heartbeat
+// inter-arrival times and reports phi, a continuous suspicion level: phi = 1
+// means roughly a 10% chance the peer is still alive and just late, phi = 2 a
+// 1% chance, and so on. Callers pick a threshold that trades detection speed
+// for false positives.
+package phi
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Config tunes a Detector. Zero values get the defaults noted on each field.
+type Config struct {
+	// Threshold is the phi above which the peer is considered failed (default 8)
+	Threshold float64
+	// WindowSize is how many inter-arrival times are remembered (default 100)
+	WindowSize int
+	// MinStdDev stops very regular heartbeats from making phi hypersensitive (default 100ms)
+	MinStdDev time.Duration
+	// AcceptablePause is extra delay tolerated on top of the observed mean, e.g. GC pauses
+	AcceptablePause time.Duration
+	// FirstHeartbeatEstimate seeds the window before real intervals are known (default 1s)
+	FirstHeartbeatEstimate time.Duration
+}
+
+// Detector tracks heartbeats from one peer. It is safe for concurrent use.
+type Detector struct {
+	cfg Config
+
+	mu        sync.Mutex
+	intervals []float64 // ring buffer of inter-arrival times in milliseconds
+	next      int
+	full      bool
+	sum       float64
+	sumSq     float64
+	last      time.Time
+}
+
+// New creates a Detector that has not seen any heartbeats yet
+func New(cfg Config) *Detector {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 8
+	}
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 100
+	}
+	if cfg.MinStdDev <= 0 {
+		cfg.MinStdDev = 100 * time.Millisecond
+	}
+	if cfg.FirstHeartbeatEstimate <= 0 {
+		cfg.FirstHeartbeatEstimate = time.Second
+	}
+	return &Detector{cfg: cfg, intervals: make([]float64, cfg.WindowSize)}
+}
+
+// Threshold returns the configured failure threshold
+func (d *Detector) Threshold() float64 {
+	return d.cfg.Threshold
+}
+
+// Heartbeat records a heartbeat that arrived at now
+func (d *Detector) Heartbeat(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last.IsZero() {
+		// Seed the window so phi is meaningful before the second heartbeat,
+		// assuming a standard deviation of a quarter of the estimate.
+		est := millis(d.cfg.FirstHeartbeatEstimate)
+		d.add(est - est/4)
+		d.add(est + est/4)
+	} else if interval := now.Sub(d.last); interval > 0 {
+		d.add(millis(interval))
+	}
+	d.last = now
+}
+
+// add pushes an interval into the window, evicting the oldest when full.
+// Callers must hold mu.
+func (d *Detector) add(ms float64) {
+	if d.full {
+		old := d.intervals[d.next]
+		d.sum -= old
+		d.sumSq -= old * old
+	}
+	d.intervals[d.next] = ms
+	d.sum += ms
+	d.sumSq += ms * ms
+	d.next = (d.next + 1) % len(d.intervals)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// Phi returns the suspicion level at now. It is 0 before the first heartbeat.
+func (d *Detector) Phi(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last.IsZero() {
+		return 0
+	}
+	n := float64(d.next)
+	if d.full {
+		n = float64(len(d.intervals))
+	}
+	mean := d.sum / n
+	variance := d.sumSq/n - mean*mean
+	stdDev := math.Max(math.Sqrt(math.Max(variance, 0)), millis(d.cfg.MinStdDev))
+
+	return phi(millis(now.Sub(d.last)), mean+millis(d.cfg.AcceptablePause), stdDev)
+}
+
+// Available reports whether phi at now is below the threshold
+func (d *Detector) Available(now time.Time) bool {
+	return d.Phi(now) < d.cfg.Threshold
+}
+
+// LastHeartbeat returns when the last heartbeat arrived, or the zero time
+func (d *Detector) LastHeartbeat() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last
+}
+
+// Beat records a heartbeat on d every interval until ctx is done, and returns
+// why ctx ended. Fed from inside a process it turns d into a watchdog: phi
+// rises when the process stalls, for example when it is starved of CPU.
+func Beat(ctx context.Context, d *Detector, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	d.Heartbeat(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case now := <-ticker.C:
+			d.Heartbeat(now)
+		}
+	}
+}
+
+// phi returns -log10(P(next heartbeat arrives later than elapsed)) for a normal
+// distribution, using the logistic approximation of its CDF to stay finite
+// for large values.
+func phi(elapsed, mean, stdDev float64) float64 {
+	y := (elapsed - mean) / stdDev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,143 @@
+package phi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// replay feeds heartbeats at the given offsets from epoch and returns the
+// time of the last one
+func replay(d *Detector, intervals ...time.Duration) time.Time {
+	now := epoch
+	d.Heartbeat(now)
+	for _, interval := range intervals {
+		now = now.Add(interval)
+		d.Heartbeat(now)
+	}
+	return now
+}
+
+func repeat(interval time.Duration, n int) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = interval
+	}
+	return out
+}
+
+func TestPhiBeforeFirstHeartbeat(t *testing.T) {
+	d := New(Config{})
+	if got := d.Phi(epoch); got != 0 {
+		t.Errorf("Phi() = %v, want 0", got)
+	}
+	if !d.Available(epoch) {
+		t.Error("Available() = false before any heartbeat")
+	}
+}
+
+func TestPhiGrowsWithSilence(t *testing.T) {
+	d := New(Config{})
+	last := replay(d, repeat(time.Second, 20)...)
+
+	prev := -1.0
+	for _, after := range []time.Duration{0, 500 * time.Millisecond, time.Second, 1200 * time.Millisecond, 1500 * time.Millisecond, 3 * time.Second} {
+		got := d.Phi(last.Add(after))
+		if got < prev {
+			t.Errorf("Phi(+%v) = %v, decreased from %v", after, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestRegularHeartbeats(t *testing.T) {
+	d := New(Config{Threshold: 8})
+	last := replay(d, repeat(time.Second, 50)...)
+
+	tests := []struct {
+		name      string
+		after     time.Duration
+		available bool
+	}{
+		{"On time", time.Second, true},
+		{"Slightly late", 1100 * time.Millisecond, true},
+		{"Missed several beats", 5 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Available(last.Add(tt.after)); got != tt.available {
+				t.Errorf("Available(+%v) = %v (phi %.2f), want %v", tt.after, got, d.Phi(last.Add(tt.after)), tt.available)
+			}
+		})
+	}
+}
+
+func TestJitteryLinkDoesNotFlap(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	intervals := make([]time.Duration, 100)
+	for i := range intervals {
+		// 1s ± 400ms
+		intervals[i] = 600*time.Millisecond + time.Duration(r.Int63n(int64(800*time.Millisecond)))
+	}
+
+	jittery := New(Config{Threshold: 8})
+	last := replay(jittery, intervals...)
+	regular := New(Config{Threshold: 8})
+	regularLast := replay(regular, repeat(time.Second, 100)...)
+
+	// A 2s gap is normal on the jittery link, but more suspicious on the regular one
+	if !jittery.Available(last.Add(2 * time.Second)) {
+		t.Errorf("jittery link flagged after 2s (phi %.2f)", jittery.Phi(last.Add(2*time.Second)))
+	}
+	if j, r := jittery.Phi(last.Add(2*time.Second)), regular.Phi(regularLast.Add(2*time.Second)); j >= r {
+		t.Errorf("phi after 2s: jittery %.2f >= regular %.2f", j, r)
+	}
+	if jittery.Available(last.Add(10 * time.Second)) {
+		t.Error("jittery link still available after 10s of silence")
+	}
+}
+
+func TestWindowForgetsOldIntervals(t *testing.T) {
+	d := New(Config{WindowSize: 10})
+	// Slow heartbeats, then the peer speeds up for a full window
+	intervals := append(repeat(5*time.Second, 10), repeat(time.Second, 10)...)
+	last := replay(d, intervals...)
+
+	if d.Available(last.Add(4 * time.Second)) {
+		t.Errorf("4s gap tolerated after the window filled with 1s intervals (phi %.2f)", d.Phi(last.Add(4*time.Second)))
+	}
+}
+
+func TestAcceptablePause(t *testing.T) {
+	strict := New(Config{})
+	lenient := New(Config{AcceptablePause: 2 * time.Second})
+	last := replay(strict, repeat(time.Second, 20)...)
+	replay(lenient, repeat(time.Second, 20)...)
+
+	at := last.Add(2500 * time.Millisecond)
+	if strict.Phi(at) <= lenient.Phi(at) {
+		t.Errorf("phi with pause %.2f >= without %.2f", lenient.Phi(at), strict.Phi(at))
+	}
+	if !lenient.Available(at) {
+		t.Error("lenient detector flagged a gap within its acceptable pause")
+	}
+}
+
+func TestBeat(t *testing.T) {
+	d := New(Config{FirstHeartbeatEstimate: 5 * time.Millisecond, MinStdDev: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Beat(ctx, d, 5*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Beat() = %v, want the context's cause", err)
+	}
+	if !d.Available(time.Now()) {
+		t.Errorf("phi = %.2f right after beating stopped", d.Phi(time.Now()))
+	}
+	if d.Available(time.Now().Add(time.Second)) {
+		t.Error("still available a second after beating stopped")
+	}
+}
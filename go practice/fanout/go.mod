@@ -0,0 +1,7 @@
+module fanout
+
+go 1.23.2
+
+require worker-pool v0.0.0
+
+replace worker-pool => ../../concurrencypatterns/worker-pool
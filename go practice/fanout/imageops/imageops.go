@@ -0,0 +1,144 @@
+// Package imageops implements simple image operations (resize, grayscale, blur
+// and thumbnail) with only the standard library, and a declarative pipeline
+// spec to chain them, e.g. "resize=800x0,grayscale,blur=2".
+package imageops
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Op is a single image transformation
+type Op interface {
+	Name() string
+	Apply(img image.Image) image.Image
+}
+
+// Pipeline applies its operations in order
+type Pipeline []Op
+
+// Apply runs every operation in the pipeline over img
+func (p Pipeline) Apply(img image.Image) image.Image {
+	for _, op := range p {
+		img = op.Apply(img)
+	}
+	return img
+}
+
+// String renders the pipeline back into spec form
+func (p Pipeline) String() string {
+	names := make([]string, len(p))
+	for i, op := range p {
+		names[i] = op.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// ParsePipeline parses a comma separated list of operations:
+//
+//	grayscale      convert to grayscale
+//	resize=WxH     resize to W by H; a zero side keeps the aspect ratio
+//	blur=R         box blur with radius R pixels
+//	thumbnail=N    shrink to fit within N by N, keeping the aspect ratio
+func ParsePipeline(spec string) (Pipeline, error) {
+	var p Pipeline
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		op, err := parseOp(name, arg)
+		if err != nil {
+			return nil, fmt.Errorf("imageops: %q: %w", part, err)
+		}
+		p = append(p, op)
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("imageops: empty pipeline spec")
+	}
+	return p, nil
+}
+
+func parseOp(name, arg string) (Op, error) {
+	switch name {
+	case "grayscale":
+		return Grayscale{}, nil
+	case "resize":
+		w, h, ok := strings.Cut(arg, "x")
+		if !ok {
+			return nil, fmt.Errorf("want resize=WxH")
+		}
+		width, err1 := strconv.Atoi(w)
+		height, err2 := strconv.Atoi(h)
+		if err1 != nil || err2 != nil || width < 0 || height < 0 || width+height == 0 {
+			return nil, fmt.Errorf("invalid size %q", arg)
+		}
+		return Resize{Width: width, Height: height}, nil
+	case "blur":
+		radius, err := strconv.Atoi(arg)
+		if err != nil || radius < 1 {
+			return nil, fmt.Errorf("invalid radius %q", arg)
+		}
+		return Blur{Radius: radius}, nil
+	case "thumbnail":
+		size, err := strconv.Atoi(arg)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid size %q", arg)
+		}
+		return Thumbnail{Size: size}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+}
+
+// Load decodes a PNG or JPEG file and returns the image and its format name
+func Load(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	return image.Decode(f)
+}
+
+// Save encodes img as PNG or JPEG, chosen by the extension of path
+func Save(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		err = png.Encode(f, img)
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 90})
+	default:
+		err = fmt.Errorf("imageops: unsupported output format %q", filepath.Ext(path))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// toRGBA returns img as an *image.RGBA whose bounds start at the origin and
+// whose rows are packed, with no padding between them. An *image.RGBA
+// that already is one, unlike a SubImage of a wider one, is returned as is.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) && rgba.Stride == 4*rgba.Rect.Dx() {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,143 @@
+package imageops
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		expected    string
+		expectError bool
+	}{
+		{name: "All operations", spec: "resize=800x0, grayscale,blur=2,thumbnail=128", expected: "resize=800x0,grayscale,blur=2,thumbnail=128"},
+		{name: "Empty spec", spec: "", expectError: true},
+		{name: "Unknown operation", spec: "sharpen", expectError: true},
+		{name: "Bad size", spec: "resize=800", expectError: true},
+		{name: "Zero size", spec: "resize=0x0", expectError: true},
+		{name: "Bad radius", spec: "blur=0", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePipeline(tt.spec)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("ParsePipeline(%q) expected an error, got %v", tt.spec, p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePipeline(%q) unexpected error: %v", tt.spec, err)
+			}
+			if p.String() != tt.expected {
+				t.Errorf("ParsePipeline(%q) = %q, want %q", tt.spec, p.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestResizeKeepsAspectRatio(t *testing.T) {
+	img := Resize{Width: 50}.Apply(solid(200, 100, color.RGBA{255, 0, 0, 255}))
+	if got := img.Bounds().Size(); got != image.Pt(50, 25) {
+		t.Errorf("size = %v, want (50,25)", got)
+	}
+	if got := img.At(10, 10); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("pixel = %v, want solid red", got)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img := Thumbnail{Size: 64}.Apply(solid(100, 400, color.RGBA{0, 0, 0, 255}))
+	if got := img.Bounds().Size(); got != image.Pt(16, 64) {
+		t.Errorf("size = %v, want (16,64)", got)
+	}
+}
+
+func TestGrayscale(t *testing.T) {
+	img := Grayscale{}.Apply(solid(2, 2, color.RGBA{255, 0, 0, 255}))
+	r, g, b, _ := img.At(0, 0).RGBA()
+	if r != g || g != b {
+		t.Errorf("pixel = (%d,%d,%d), want equal channels", r, g, b)
+	}
+}
+
+func TestBlurSmoothsEdges(t *testing.T) {
+	img := solid(10, 1, color.RGBA{0, 0, 0, 255})
+	img.SetRGBA(5, 0, color.RGBA{255, 255, 255, 255})
+
+	blurred := Blur{Radius: 1}.Apply(img).(*image.RGBA)
+	if got := blurred.RGBAAt(5, 0).R; got != 85 {
+		t.Errorf("centre = %d, want 85", got)
+	}
+	if got := blurred.RGBAAt(4, 0).R; got != 85 {
+		t.Errorf("neighbour = %d, want 85", got)
+	}
+	if got := blurred.RGBAAt(0, 0).R; got != 0 {
+		t.Errorf("far pixel = %d, want 0", got)
+	}
+}
+
+func TestSubImage(t *testing.T) {
+	// The left half is red and the right half green. A SubImage of the left
+	// half shares the wider image's rows, so its stride is twice its width.
+	img := solid(8, 4, color.RGBA{255, 0, 0, 255})
+	for y := 0; y < 4; y++ {
+		for x := 4; x < 8; x++ {
+			img.SetRGBA(x, y, color.RGBA{0, 255, 0, 255})
+		}
+	}
+	red := img.SubImage(image.Rect(0, 0, 4, 4))
+	wantGray := Grayscale{}.Apply(solid(4, 4, color.RGBA{255, 0, 0, 255})).(*image.RGBA)
+
+	for _, op := range []Op{Grayscale{}, Blur{Radius: 1}} {
+		out := op.Apply(red).(*image.RGBA)
+		if got := out.Bounds(); got != image.Rect(0, 0, 4, 4) {
+			t.Fatalf("%s bounds = %v, want (0,0)-(4,4)", op.Name(), got)
+		}
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				want := color.RGBA{255, 0, 0, 255}
+				if _, ok := op.(Grayscale); ok {
+					want = wantGray.RGBAAt(x, y)
+				}
+				if got := out.RGBAAt(x, y); got != want {
+					t.Fatalf("%s pixel (%d,%d) = %v, want %v", op.Name(), x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	for _, name := range []string{"out.png", "out.jpg"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := Save(path, solid(8, 4, color.RGBA{0, 128, 0, 255})); err != nil {
+			t.Fatalf("Save(%s): %v", name, err)
+		}
+		img, _, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", name, err)
+		}
+		if got := img.Bounds().Size(); got != image.Pt(8, 4) {
+			t.Errorf("%s size = %v, want (8,4)", name, got)
+		}
+	}
+	if err := Save(filepath.Join(t.TempDir(), "out.gif"), solid(1, 1, color.RGBA{})); err == nil {
+		t.Error("Save(out.gif) expected an error")
+	}
+}
@@ -0,0 +1,144 @@
+package imageops
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Grayscale converts an image to shades of gray, keeping its alpha channel
+type Grayscale struct{}
+
+func (Grayscale) Name() string { return "grayscale" }
+
+func (Grayscale) Apply(img image.Image) image.Image {
+	src := toRGBA(img)
+	dst := image.NewRGBA(src.Rect)
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		s, d := src.PixOffset(src.Rect.Min.X, y), dst.PixOffset(dst.Rect.Min.X, y)
+		for end := s + 4*src.Rect.Dx(); s < end; s, d = s+4, d+4 {
+			// ITU-R BT.601 luma, the same weights as color.GrayModel
+			r, g, b := uint32(src.Pix[s]), uint32(src.Pix[s+1]), uint32(src.Pix[s+2])
+			l := uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 16)
+			dst.Pix[d], dst.Pix[d+1], dst.Pix[d+2], dst.Pix[d+3] = l, l, l, src.Pix[s+3]
+		}
+	}
+	return dst
+}
+
+// Resize scales an image with bilinear interpolation.
+// If Width or Height is zero it is derived from the other, keeping the aspect ratio.
+type Resize struct {
+	Width, Height int
+}
+
+func (r Resize) Name() string { return fmt.Sprintf("resize=%dx%d", r.Width, r.Height) }
+
+func (r Resize) Apply(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := r.Width, r.Height
+	switch {
+	case w == 0:
+		w = max(1, b.Dx()*h/b.Dy())
+	case h == 0:
+		h = max(1, b.Dy()*w/b.Dx())
+	}
+	return bilinear(toRGBA(img), w, h)
+}
+
+// Thumbnail shrinks an image to fit within Size by Size, keeping the aspect ratio.
+// Images that already fit are returned unchanged.
+type Thumbnail struct {
+	Size int
+}
+
+func (t Thumbnail) Name() string { return fmt.Sprintf("thumbnail=%d", t.Size) }
+
+func (t Thumbnail) Apply(img image.Image) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= t.Size && b.Dy() <= t.Size {
+		return img
+	}
+	if b.Dx() >= b.Dy() {
+		return Resize{Width: t.Size}.Apply(img)
+	}
+	return Resize{Height: t.Size}.Apply(img)
+}
+
+// Blur applies a box blur with the given radius in pixels
+type Blur struct {
+	Radius int
+}
+
+func (bl Blur) Name() string { return fmt.Sprintf("blur=%d", bl.Radius) }
+
+func (bl Blur) Apply(img image.Image) image.Image {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	// A box blur is separable: blur rows, then columns. toRGBA's images are
+	// compact, so src, tmp and dst share a stride.
+	tmp := image.NewRGBA(src.Rect)
+	boxBlur(src.Pix, tmp.Pix, w, h, 4, tmp.Stride, bl.Radius)
+	dst := image.NewRGBA(src.Rect)
+	boxBlur(tmp.Pix, dst.Pix, h, w, tmp.Stride, 4, bl.Radius)
+	return dst
+}
+
+// boxBlur averages each pixel with its neighbours along one axis. n pixels per
+// line and lines of them; step moves along the line and lineStep to the next one.
+// Edges are clamped.
+func boxBlur(src, dst []uint8, n, lines, step, lineStep, radius int) {
+	window := 2*radius + 1
+	for line := 0; line < lines; line++ {
+		base := line * lineStep
+		at := func(i int) int {
+			return base + min(max(i, 0), n-1)*step
+		}
+		for c := 0; c < 4; c++ {
+			sum := 0
+			for i := -radius; i <= radius; i++ {
+				sum += int(src[at(i)+c])
+			}
+			for i := 0; i < n; i++ {
+				dst[base+i*step+c] = uint8((sum + window/2) / window)
+				sum += int(src[at(i+radius+1)+c]) - int(src[at(i-radius)+c])
+			}
+		}
+	}
+}
+
+// bilinear resizes src to w by h
+func bilinear(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	scaleX := float64(sw) / float64(w)
+	scaleY := float64(sh) / float64(h)
+
+	for y := 0; y < h; y++ {
+		fy := (float64(y)+0.5)*scaleY - 0.5
+		y0 := int(math.Floor(fy))
+		wy := fy - float64(y0)
+		y1 := min(max(y0+1, 0), sh-1)
+		y0 = min(max(y0, 0), sh-1)
+
+		for x := 0; x < w; x++ {
+			fx := (float64(x)+0.5)*scaleX - 0.5
+			x0 := int(math.Floor(fx))
+			wx := fx - float64(x0)
+			x1 := min(max(x0+1, 0), sw-1)
+			x0 = min(max(x0, 0), sw-1)
+
+			p00 := src.PixOffset(x0, y0)
+			p10 := src.PixOffset(x1, y0)
+			p01 := src.PixOffset(x0, y1)
+			p11 := src.PixOffset(x1, y1)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[p00+c])*(1-wx) + float64(src.Pix[p10+c])*wx
+				bottom := float64(src.Pix[p01+c])*(1-wx) + float64(src.Pix[p11+c])*wx
+				dst.Pix[d+c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fanout/imageops"
+)
+
+// imageProcessor loads job.Image from disk, runs the pipeline and writes the
+// output with the same name into outDir
+type imageProcessor struct {
+	pipeline imageops.Pipeline
+	outDir   string
+}
+
+func (p imageProcessor) Process(ctx context.Context, job Job) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	img, _, err := imageops.Load(job.Image)
+	if err != nil {
+		return Result{}, fmt.Errorf("image %d: %w", job.ID, err)
+	}
+
+	out := filepath.Join(p.outDir, filepath.Base(job.Image))
+	if err := imageops.Save(out, p.pipeline.Apply(img)); err != nil {
+		return Result{}, fmt.Errorf("image %d: %w", job.ID, err)
+	}
+	return Result{JobID: job.ID, ProcessedImage: out}, nil
+}
+
+// imageJobs returns a job for every PNG or JPEG file in dir, sorted by name
+func imageJobs(dir string) ([]Job, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	jobs := make([]Job, len(names))
+	for i, name := range names {
+		jobs[i] = Job{ID: i + 1, Image: filepath.Join(dir, name)}
+	}
+	return jobs, nil
+}
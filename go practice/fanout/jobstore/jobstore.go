@@ -0,0 +1,380 @@
+// Package jobstore is a durable job queue backed by a write-ahead log.
+//
+// Every state change (enqueue, lease, ack) is appended to the log as a JSON
+// line and synced before it takes effect. Workers lease jobs for a visibility
+// timeout and ack them when done; a lease that expires makes its job available
+// again. Opening an existing log recovers every job that was never acked.
+package jobstore
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCompactEvery is how many records Store appends to the log before it
+// rewrites the log with only the unfinished jobs
+const DefaultCompactEvery = 1000
+
+// ErrDrained is returned by Next when every job has been acked
+var ErrDrained = errors.New("jobstore: no unfinished jobs")
+
+// ErrUnknownJob is returned when acking a job that is not in the store
+var ErrUnknownJob = errors.New("jobstore: unknown job")
+
+// Lease is a job handed to a worker until Expires
+type Lease[T any] struct {
+	ID      uint64
+	Job     T
+	Attempt int // 1 on first delivery, incremented on every redelivery
+	Expires time.Time
+}
+
+// record is one line of the write-ahead log
+type record struct {
+	Op      string          `json:"op"` // "enqueue", "lease", "ack" or "next"
+	ID      uint64          `json:"id"`
+	Job     json.RawMessage `json:"job,omitempty"`
+	Expires *time.Time      `json:"expires,omitempty"`
+}
+
+type entry struct {
+	id       uint64
+	job      json.RawMessage
+	attempts int
+	// ready is when the job can be leased: when it was enqueued, or when
+	// its lease expires
+	ready time.Time
+	index int // in Store.queue
+}
+
+// queue is a min-heap of entries by ready time, then ID, so its top is the
+// job that has been available longest or whose lease expires first
+type queue []*entry
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if !q[i].ready.Equal(q[j].ready) {
+		return q[i].ready.Before(q[j].ready)
+	}
+	return q[i].id < q[j].id
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *queue) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
+
+// Store is a durable FIFO of jobs of type T. It is safe for concurrent use.
+type Store[T any] struct {
+	// CompactEvery overrides DefaultCompactEvery. Set it before first use.
+	CompactEvery int
+
+	path       string
+	visibility time.Duration
+
+	mu       sync.Mutex
+	log      *os.File
+	appended int // records appended since the last compaction
+	nextID   uint64
+	entries  map[uint64]*entry
+	queue    queue         // the entries by ready time
+	changed  chan struct{} // closed and replaced on every state change
+}
+
+// Open opens or creates the log at path and recovers every unfinished job.
+// Leases held by a previous process are released, so their jobs are delivered
+// again straight away. The log is compacted to the unfinished jobs.
+func Open[T any](path string, visibility time.Duration) (*Store[T], error) {
+	s := &Store[T]{
+		path:       path,
+		visibility: visibility,
+		nextID:     1,
+		entries:    make(map[uint64]*entry),
+		changed:    make(chan struct{}),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	for _, e := range s.queue {
+		e.ready = time.Time{}
+	}
+	heap.Init(&s.queue)
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay rebuilds the in-memory state from the log. A torn final line left by
+// a crash mid-write is ignored; a corrupt line anywhere else is an error.
+func (s *Store[T]) replay() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Anything after the last newline was never fully written
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(bytes.TrimSpace(data), &rec); err != nil {
+			return fmt.Errorf("jobstore: %s line %d: %w", s.path, line, err)
+		}
+		s.apply(rec)
+	}
+}
+
+func (s *Store[T]) apply(rec record) {
+	switch rec.Op {
+	case "enqueue":
+		e := &entry{id: rec.ID, job: rec.Job, ready: time.Now()}
+		s.entries[rec.ID] = e
+		heap.Push(&s.queue, e)
+		if rec.ID >= s.nextID {
+			s.nextID = rec.ID + 1
+		}
+	case "lease":
+		if e, ok := s.entries[rec.ID]; ok {
+			e.attempts++
+			e.ready = time.Time{}
+			if rec.Expires != nil {
+				e.ready = *rec.Expires
+			}
+			heap.Fix(&s.queue, e.index)
+		}
+	case "next":
+		// Written by compact so acked jobs' IDs are not handed out again
+		s.nextID = max(s.nextID, rec.ID)
+	case "ack":
+		if e, ok := s.entries[rec.ID]; ok {
+			heap.Remove(&s.queue, e.index)
+			delete(s.entries, rec.ID)
+		}
+	}
+}
+
+// compact rewrites the log with only the unfinished jobs and the next ID, and
+// reopens it for appending
+func (s *Store[T]) compact() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := writeRecord(w, record{Op: "next", ID: s.nextID}); err != nil {
+		f.Close()
+		return err
+	}
+	for _, e := range s.ordered() {
+		recs := []record{{Op: "enqueue", ID: e.id, Job: e.job}}
+		// Keep the attempt count across restarts with lease records that have no expiry
+		for i := 0; i < e.attempts; i++ {
+			recs = append(recs, record{Op: "lease", ID: e.id})
+		}
+		for _, rec := range recs {
+			if err := writeRecord(w, rec); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	s.appended = 0
+	return err
+}
+
+func writeRecord(w io.Writer, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// append durably writes rec to the log before applying it, first compacting
+// the log once CompactEvery records have been appended. Callers must hold mu.
+func (s *Store[T]) append(rec record) error {
+	if s.log == nil {
+		return os.ErrClosed
+	}
+	limit := s.CompactEvery
+	if limit <= 0 {
+		limit = DefaultCompactEvery
+	}
+	if s.appended >= limit {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	if err := writeRecord(s.log, rec); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.apply(rec)
+	s.appended++
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return nil
+}
+
+// Enqueue durably adds job to the queue and returns its ID
+func (s *Store[T]) Enqueue(job T) (uint64, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	if err := s.append(record{Op: "enqueue", ID: id, Job: data}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Next leases the job that has been available longest, blocking until one is
+// available, every job has been acked (ErrDrained) or ctx is done. A job whose
+// lease expired is available from its expiry.
+func (s *Store[T]) Next(ctx context.Context) (Lease[T], error) {
+	for {
+		s.mu.Lock()
+		if len(s.entries) == 0 {
+			s.mu.Unlock()
+			return Lease[T]{}, ErrDrained
+		}
+
+		now := time.Now()
+		if e := s.queue[0]; !e.ready.After(now) {
+			lease, err := s.lease(e, now)
+			s.mu.Unlock()
+			return lease, err
+		}
+		wake := s.queue[0].ready
+		changed := s.changed
+		s.mu.Unlock()
+
+		// Wait for a new job, an ack, or the earliest lease to expire
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Lease[T]{}, ctx.Err()
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// lease hands e out until now+visibility. Callers must hold mu.
+func (s *Store[T]) lease(e *entry, now time.Time) (Lease[T], error) {
+	var job T
+	if err := json.Unmarshal(e.job, &job); err != nil {
+		return Lease[T]{}, fmt.Errorf("jobstore: job %d: %w", e.id, err)
+	}
+	expires := now.Add(s.visibility)
+	if err := s.append(record{Op: "lease", ID: e.id, Expires: &expires}); err != nil {
+		return Lease[T]{}, err
+	}
+	return Lease[T]{ID: e.id, Job: job, Attempt: e.attempts, Expires: expires}, nil
+}
+
+// Ack durably marks a job as finished so it is never delivered again
+func (s *Store[T]) Ack(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownJob, id)
+	}
+	return s.append(record{Op: "ack", ID: id})
+}
+
+// Unfinished returns how many jobs have not been acked yet
+func (s *Store[T]) Unfinished() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Close closes the log. The store must not be used afterwards.
+func (s *Store[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// ordered returns the unfinished entries oldest first. Callers must hold mu.
+func (s *Store[T]) ordered() []*entry {
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+	return entries
+}
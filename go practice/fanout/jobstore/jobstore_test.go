@@ -0,0 +1,162 @@
+package jobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type job struct {
+	Name string
+}
+
+func open(t *testing.T, path string, visibility time.Duration) *Store[job] {
+	t.Helper()
+	s, err := Open[job](path, visibility)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func next(t *testing.T, s *Store[job]) Lease[job] {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lease, err := s.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next() unexpected error: %v", err)
+	}
+	return lease
+}
+
+func TestLeaseAndAck(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "jobs.wal"), time.Minute)
+	s.Enqueue(job{"a"})
+	s.Enqueue(job{"b"})
+
+	first := next(t, s)
+	second := next(t, s)
+	if first.Job.Name != "a" || second.Job.Name != "b" {
+		t.Fatalf("leased %q then %q, want a then b", first.Job.Name, second.Job.Name)
+	}
+	if first.Attempt != 1 {
+		t.Errorf("first attempt = %d, want 1", first.Attempt)
+	}
+
+	s.Ack(first.ID)
+	s.Ack(second.ID)
+	if _, err := s.Next(context.Background()); !errors.Is(err, ErrDrained) {
+		t.Errorf("Next() after acking everything = %v, want ErrDrained", err)
+	}
+	if err := s.Ack(first.ID); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("second Ack() = %v, want ErrUnknownJob", err)
+	}
+}
+
+func TestExpiredLeaseIsRedelivered(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "jobs.wal"), 20*time.Millisecond)
+	s.Enqueue(job{"a"})
+
+	first := next(t, s)
+	again := next(t, s) // blocks until the first lease expires
+	if again.ID != first.ID || again.Attempt != 2 {
+		t.Errorf("redelivered job %d attempt %d, want job %d attempt 2", again.ID, again.Attempt, first.ID)
+	}
+}
+
+func TestNextWaitsForEnqueue(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "jobs.wal"), time.Minute)
+	s.Enqueue(job{"a"})
+	next(t, s)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Enqueue(job{"b"})
+	}()
+	if got := next(t, s); got.Job.Name != "b" {
+		t.Errorf("leased %q, want b", got.Job.Name)
+	}
+}
+
+func TestRecoverUnfinishedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	s, _ := Open[job](path, time.Minute)
+	s.Enqueue(job{"acked"})
+	s.Enqueue(job{"leased"})
+	s.Enqueue(job{"queued"})
+	s.Ack(next(t, s).ID)
+	next(t, s)
+	s.Close() // simulated crash while "leased" is in flight
+
+	recovered := open(t, path, time.Minute)
+	if n := recovered.Unfinished(); n != 2 {
+		t.Fatalf("Unfinished() = %d, want 2", n)
+	}
+	leased := next(t, recovered)
+	if leased.Job.Name != "leased" || leased.Attempt != 2 {
+		t.Errorf("got %q attempt %d, want leased attempt 2", leased.Job.Name, leased.Attempt)
+	}
+	if got := next(t, recovered); got.Job.Name != "queued" {
+		t.Errorf("got %q, want queued", got.Job.Name)
+	}
+
+	// New IDs must not reuse those of acked jobs
+	id, _ := recovered.Enqueue(job{"new"})
+	if id != 4 {
+		t.Errorf("new job ID = %d, want 4", id)
+	}
+}
+
+func TestCompactEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	s := open(t, path, time.Minute)
+	s.CompactEvery = 4
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		id, _ := s.Enqueue(job{name})
+		if err := s.Ack(next(t, s).ID); err != nil {
+			t.Fatalf("Ack(%d): %v", id, err)
+		}
+	}
+	s.Enqueue(job{"kept"})
+
+	// 15 records were appended, but the log was rewritten every 4
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > 5 {
+		t.Errorf("log has %d lines, want it compacted to at most 5", lines)
+	}
+	s.Close()
+
+	recovered := open(t, path, time.Minute)
+	if got := next(t, recovered); got.Job.Name != "kept" {
+		t.Errorf("got %q, want kept", got.Job.Name)
+	}
+	// Acked jobs' IDs stay retired even though their records were compacted away
+	if id, _ := recovered.Enqueue(job{"new"}); id != 7 {
+		t.Errorf("new job ID = %d, want 7", id)
+	}
+}
+
+func TestTornWriteIsIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	s, _ := Open[job](path, time.Minute)
+	s.Enqueue(job{"a"})
+	s.Close()
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"op":"enqueue","id":2,"job":{"Na`)
+	f.Close()
+
+	recovered := open(t, path, time.Minute)
+	if n := recovered.Unfinished(); n != 1 {
+		t.Errorf("Unfinished() = %d, want 1", n)
+	}
+}
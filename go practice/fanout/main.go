@@ -1,72 +1,229 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"sync"
+	"log"
+	"math/rand"
+	"os"
 	"time"
+
+	"fanout/imageops"
+	"worker-pool/workerpool"
 )
 
 // Job represents a task to be processed
 type Job struct {
 	ID    int
-	Image string // Simulated image, just a string for simplicity
+	Image string       // Simulated image, just a string for simplicity
+	Retry *RetryPolicy // nil uses DefaultRetryPolicy
 }
 
 // Result represents the outcome of processing a job
 type Result struct {
 	JobID          int
 	ProcessedImage string
+	Attempts       int
 }
 
-// worker processes jobs by counting words in the text
-func worker(id int, jobs <-chan Job, results chan<- Result) {
-	for job := range jobs {
-		fmt.Printf("Worker %d starting to process image %d\n", id, job.ID)
+// Processor does the actual work for a job and reports failures as errors
+type Processor interface {
+	Process(ctx context.Context, job Job) (Result, error)
+}
 
-		// Fixed processing time of 2 seconds
-		time.Sleep(2 * time.Second)
+// ProcessorFunc adapts a plain function to the Processor interface
+type ProcessorFunc func(ctx context.Context, job Job) (Result, error)
 
-		processedImage := fmt.Sprintf("enhanced_%d.jpg", job.ID)
-		fmt.Printf("Worker %d finished processing image %d\n", id, job.ID)
-		results <- Result{JobID: job.ID, ProcessedImage: processedImage}
-	}
+// Process calls f(ctx, job)
+func (f ProcessorFunc) Process(ctx context.Context, job Job) (Result, error) {
+	return f(ctx, job)
 }
 
-func main() {
-	const numJobs = 10
-	const numWorkers = 3
+// errEnhanceFailed is returned by the simulated processor for flaky jobs
+var errEnhanceFailed = errors.New("enhance failed")
+
+// simulatedProcessor pretends to enhance an image and fails some of the time
+func simulatedProcessor(failureRate float64) Processor {
+	return ProcessorFunc(func(ctx context.Context, job Job) (Result, error) {
+		select {
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		case <-time.After(time.Duration(300+rand.Intn(700)) * time.Millisecond):
+		}
+		if rand.Float64() < failureRate {
+			return Result{}, fmt.Errorf("image %d: %w", job.ID, errEnhanceFailed)
+		}
+		return Result{JobID: job.ID, ProcessedImage: fmt.Sprintf("enhanced_%d.jpg", job.ID)}, nil
+	})
+}
 
-	jobs := make(chan Job, numJobs)
-	results := make(chan Result, numJobs)
+// process runs job with retries; a job that exhausts its retries goes to dead
+func process(ctx context.Context, p Processor, job Job, results chan<- Result, dead chan<- DeadLetter, tracker *Tracker) {
+	tracker.Started(job.ID)
+	result, attempts, err := processWithRetry(ctx, p, job)
+	tracker.Finished(job.ID, err)
+	if err != nil {
+		dead <- DeadLetter{Job: job, Attempts: attempts, Err: err}
+		return
+	}
+	result.Attempts = attempts
+	results <- result
+}
 
-	// Create jobs
-	for j := 1; j <= numJobs; j++ {
-		jobs <- Job{ID: j, Image: fmt.Sprintf("image_%d.jpg", j)}
+// worker processes jobs until the channel is closed. Every worker fans its
+// output into the same results and dead channels. A worker takes a job only
+// when it is free, so a job leased from a durable queue doesn't wait in a buffer.
+func worker(ctx context.Context, p Processor, jobs <-chan Job, results chan<- Result, dead chan<- DeadLetter, tracker *Tracker) {
+	for job := range jobs {
+		process(ctx, p, job, results, dead, tracker)
 	}
-	close(jobs)
+}
 
-	// Start workers
-	var wg sync.WaitGroup
+// run processes jobs on numWorkers workers until jobs is closed and they
+// finish. Each job's first Result or DeadLetter is kept and acked; later
+// ones, from a job redelivered after its lease expired, are acked and dropped.
+func run(ctx context.Context, p Processor, jobs <-chan Job, numWorkers int, tracker *Tracker,
+	ack func(jobID int), show func(Progress)) ([]Result, []DeadLetter) {
+	results := make(chan Result)
+	dead := make(chan DeadLetter)
+
+	// The workers run on a worker pool sized to hold all of them
+	pool := workerpool.New[struct{}](workerpool.Config{MinWorkers: numWorkers})
 	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			worker(id, jobs, results)
-		}(i)
+		_, err := pool.Submit(ctx, func(ctx context.Context) (struct{}, error) {
+			worker(ctx, p, jobs, results, dead, tracker)
+			return struct{}{}, nil
+		})
+		if err != nil {
+			break
+		}
 	}
-
-	// Close results channel when all workers are done
 	go func() {
-		wg.Wait()
+		// Close the output channels once every worker is done
+		pool.Shutdown(context.Background())
 		close(results)
+		close(dead)
+		tracker.Close()
 	}()
 
-	// Process results
-	startTime := time.Now()
-	for result := range results {
-		fmt.Println("Time: ", time.Since(startTime))
-		fmt.Printf("Result: Image %d processed as %s\n", result.JobID, result.ProcessedImage)
+	var processed []Result
+	var failed []DeadLetter
+	finished := make(map[int]bool)
+	updates := tracker.Updates()
+	for results != nil || dead != nil || updates != nil {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			ack(result.JobID)
+			if !finished[result.JobID] {
+				finished[result.JobID] = true
+				processed = append(processed, result)
+			}
+		case letter, ok := <-dead:
+			if !ok {
+				dead = nil
+				continue
+			}
+			ack(letter.Job.ID)
+			if !finished[letter.Job.ID] {
+				finished[letter.Job.ID] = true
+				failed = append(failed, letter)
+			}
+		case progress, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			show(progress)
+		}
+	}
+	return processed, failed
+}
+
+func main() {
+	inDir := flag.String("in", "", "directory of PNG/JPEG images to process; simulated jobs if empty")
+	outDir := flag.String("out", "out", "directory the processed images are written to")
+	ops := flag.String("ops", "thumbnail=256", "comma separated operations: resize=WxH, grayscale, blur=R, thumbnail=N")
+	numWorkers := flag.Int("workers", 3, "number of workers")
+	queuePath := flag.String("queue", "", "write-ahead log that keeps queued jobs across restarts; in memory if empty")
+	visibility := flag.Duration("visibility", 30*time.Second, "how long a worker may hold a job from -queue before it is redelivered")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	var jobList []Job
+	var processor Processor
+	if *inDir == "" {
+		// Simulated jobs; the last one gets a single attempt instead of the default policy
+		const numSimulated = 10
+		for j := 1; j <= numSimulated; j++ {
+			job := Job{ID: j, Image: fmt.Sprintf("image_%d.jpg", j)}
+			if j == numSimulated {
+				job.Retry = &RetryPolicy{MaxAttempts: 1}
+			}
+			jobList = append(jobList, job)
+		}
+		processor = simulatedProcessor(0.3)
+	} else {
+		pipeline, err := imageops.ParsePipeline(*ops)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if jobList, err = imageJobs(*inDir); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Applying %v to %d images\n", pipeline, len(jobList))
+		processor = imageProcessor{pipeline: pipeline, outDir: *outDir}
+	}
+
+	var queue *durableQueue
+	numJobs := len(jobList)
+	if *queuePath != "" {
+		var err error
+		if queue, err = openDurableQueue(*queuePath, *visibility, jobList); err != nil {
+			log.Fatal(err)
+		}
+		defer queue.close()
+		numJobs = queue.unfinished()
+	}
+
+	var jobs chan Job
+	tracker := NewTracker(numJobs)
+	ack := func(int) {}
+	if queue != nil {
+		// Unbuffered, so the next job is leased only once a worker is free to
+		// take it, instead of every lease running out in a buffer
+		jobs = make(chan Job)
+		go queue.feed(ctx, jobs)
+		ack = queue.ack
+	} else {
+		jobs = make(chan Job, numJobs)
+		for _, job := range jobList {
+			jobs <- job
+		}
+		close(jobs)
+	}
+
+	// Render a live progress line while collecting results and dead letters
+	processed, failed := run(ctx, processor, jobs, *numWorkers, tracker, ack, func(progress Progress) {
+		fmt.Printf("\r\033[K%v", progress)
+	})
+	fmt.Println()
+
+	for _, result := range processed {
+		fmt.Printf("Result: Image %d processed as %s after %d attempt(s)\n", result.JobID, result.ProcessedImage, result.Attempts)
+	}
+	for _, letter := range failed {
+		fmt.Printf("Dead letter: Image %d failed after %d attempt(s): %v\n", letter.Job.ID, letter.Attempts, letter.Err)
 	}
 
-	fmt.Printf("All images processed in %v\n", time.Since(startTime))
+	fmt.Printf("All images processed in %v\n", tracker.Snapshot().Elapsed)
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of how far a batch of jobs has got
+type Progress struct {
+	Total   int
+	Queued  int
+	Running int
+	Done    int
+	Failed  int
+	Elapsed time.Duration
+	ETA     time.Duration // zero until at least one job has finished
+}
+
+// String renders the progress as a single status line
+func (p Progress) String() string {
+	eta := "--"
+	if p.ETA > 0 {
+		eta = p.ETA.Round(100 * time.Millisecond).String()
+	}
+	return fmt.Sprintf("[%d/%d] queued %d, running %d, done %d, failed %d, elapsed %v, ETA %s",
+		p.Done+p.Failed, p.Total, p.Queued, p.Running, p.Done, p.Failed,
+		p.Elapsed.Round(100*time.Millisecond), eta)
+}
+
+// Tracker counts job state transitions and publishes a Progress after each one.
+// Updates never block workers: a slow reader only sees the latest snapshot.
+// A job delivered more than once leaves the queue and finishes only once, so
+// the counts stay within Total.
+type Tracker struct {
+	mu       sync.Mutex
+	start    time.Time
+	current  Progress
+	started  map[int]bool
+	finished map[int]bool
+	updates  chan Progress
+}
+
+// NewTracker starts tracking total queued jobs
+func NewTracker(total int) *Tracker {
+	t := &Tracker{
+		start:    time.Now(),
+		current:  Progress{Total: total, Queued: total},
+		started:  make(map[int]bool),
+		finished: make(map[int]bool),
+		updates:  make(chan Progress, 1),
+	}
+	t.publish()
+	return t
+}
+
+// Updates streams progress snapshots until Close is called
+func (t *Tracker) Updates() <-chan Progress {
+	return t.updates
+}
+
+// Started moves job jobID from queued to running
+func (t *Tracker) Started(jobID int) {
+	t.update(func(p *Progress) {
+		if !t.started[jobID] {
+			t.started[jobID] = true
+			p.Queued--
+		}
+		p.Running++
+	})
+}
+
+// Finished moves job jobID from running to done, or to failed if err is not
+// nil. Only the first outcome of a redelivered job counts.
+func (t *Tracker) Finished(jobID int, err error) {
+	t.update(func(p *Progress) {
+		p.Running--
+		if t.finished[jobID] {
+			return
+		}
+		t.finished[jobID] = true
+		if err != nil {
+			p.Failed++
+		} else {
+			p.Done++
+		}
+	})
+}
+
+// Snapshot returns the current progress
+func (t *Tracker) Snapshot() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshotLocked()
+}
+
+// Close publishes a final snapshot and closes the updates stream
+func (t *Tracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.publishLocked()
+	close(t.updates)
+}
+
+func (t *Tracker) update(fn func(p *Progress)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(&t.current)
+	t.publishLocked()
+}
+
+func (t *Tracker) publish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.publishLocked()
+}
+
+func (t *Tracker) snapshotLocked() Progress {
+	p := t.current
+	p.Elapsed = time.Since(t.start)
+	if finished := p.Done + p.Failed; finished > 0 {
+		perJob := p.Elapsed / time.Duration(finished)
+		p.ETA = perJob * time.Duration(p.Total-finished)
+	}
+	return p
+}
+
+// publishLocked replaces any unread snapshot with the latest one
+func (t *Tracker) publishLocked() {
+	p := t.snapshotLocked()
+	select {
+	case <-t.updates:
+	default:
+	}
+	t.updates <- p
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fanout/jobstore"
+)
+
+// durableQueue feeds workers from a write-ahead-log backed store so queued and
+// in-flight jobs survive a crash. Jobs are acked once they produce a Result or
+// a DeadLetter; a job whose lease expires before that is delivered again.
+type durableQueue struct {
+	store *jobstore.Store[Job]
+
+	mu     sync.Mutex
+	leases map[int]uint64 // Job.ID to store ID
+}
+
+// openDurableQueue opens the log at path. If it holds unfinished jobs from an
+// earlier run they are resumed; otherwise fresh is enqueued.
+func openDurableQueue(path string, visibility time.Duration, fresh []Job) (*durableQueue, error) {
+	store, err := jobstore.Open[Job](path, visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := store.Unfinished(); n > 0 {
+		fmt.Printf("Recovered %d unfinished jobs from %s\n", n, path)
+	} else {
+		for _, job := range fresh {
+			if _, err := store.Enqueue(job); err != nil {
+				store.Close()
+				return nil, err
+			}
+		}
+	}
+	return &durableQueue{store: store, leases: make(map[int]uint64)}, nil
+}
+
+// unfinished returns how many jobs still need a Result or DeadLetter
+func (q *durableQueue) unfinished() int {
+	return q.store.Unfinished()
+}
+
+// feed leases jobs onto jobs until every job has been acked, then closes jobs
+func (q *durableQueue) feed(ctx context.Context, jobs chan<- Job) {
+	defer close(jobs)
+	for {
+		lease, err := q.store.Next(ctx)
+		if err != nil {
+			if !errors.Is(err, jobstore.ErrDrained) {
+				log.Println("job queue:", err)
+			}
+			return
+		}
+
+		q.mu.Lock()
+		q.leases[lease.Job.ID] = lease.ID
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- lease.Job:
+		}
+	}
+}
+
+// ack marks the job as finished. Acking a job twice, which happens when a
+// redelivered job finishes on two workers, is harmless.
+func (q *durableQueue) ack(jobID int) {
+	q.mu.Lock()
+	id, ok := q.leases[jobID]
+	delete(q.leases, jobID)
+	q.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := q.store.Ack(id); err != nil && !errors.Is(err, jobstore.ErrUnknownJob) {
+		log.Println("job queue:", err)
+	}
+}
+
+func (q *durableQueue) close() error {
+	return q.store.Close()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurableQueueWithExpiringLeases(t *testing.T) {
+	const numJobs = 6
+	var jobList []Job
+	for j := 1; j <= numJobs; j++ {
+		jobList = append(jobList, Job{ID: j, Retry: &RetryPolicy{MaxAttempts: 1}})
+	}
+	// Workers take longer than the visibility timeout, so leases expire
+	// mid-job and jobs are delivered again
+	queue, err := openDurableQueue(filepath.Join(t.TempDir(), "jobs.log"), 20*time.Millisecond, jobList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.close()
+	slow := ProcessorFunc(func(ctx context.Context, job Job) (Result, error) {
+		time.Sleep(50 * time.Millisecond)
+		return Result{JobID: job.ID}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	jobs := make(chan Job)
+	go queue.feed(ctx, jobs)
+	tracker := NewTracker(numJobs)
+	var maxFinished int
+	processed, failed := run(ctx, slow, jobs, 2, tracker, queue.ack, func(p Progress) {
+		if p.Queued < 0 || p.Running < 0 {
+			t.Errorf("progress went negative: %v", p)
+		}
+		maxFinished = max(maxFinished, p.Done+p.Failed)
+	})
+
+	seen := make(map[int]bool)
+	for _, result := range processed {
+		if seen[result.JobID] {
+			t.Errorf("job %d reported twice", result.JobID)
+		}
+		seen[result.JobID] = true
+	}
+	if len(seen) != numJobs || len(failed) != 0 {
+		t.Errorf("got %d results and %d dead letters, want %d results", len(processed), len(failed), numJobs)
+	}
+	if maxFinished > numJobs {
+		t.Errorf("progress counted %d finished jobs out of %d", maxFinished, numJobs)
+	}
+	if p := tracker.Snapshot(); p.Done != numJobs || p.Queued != 0 || p.Running != 0 {
+		t.Errorf("final progress = %v", p)
+	}
+	if n := queue.unfinished(); n != 0 {
+		t.Errorf("%d jobs left unacked", n)
+	}
+}
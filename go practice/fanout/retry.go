@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how often and how quickly a failed job is retried
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first one
+	BaseDelay   time.Duration // delay before the first retry
+	MaxDelay    time.Duration // upper bound for any single delay
+	Jitter      float64       // fraction of the delay randomised, between 0 and 1
+}
+
+// DefaultRetryPolicy is used for jobs that do not carry their own policy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+	Jitter:      0.2,
+}
+
+// Backoff returns the delay before retry number attempt (1 for the first retry).
+// The delay doubles every attempt and is capped at MaxDelay, when set, before
+// jitter is applied. Without MaxDelay it grows until time.Duration would overflow.
+// Jitter outside [0, 1] is clamped to it.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	limit := p.MaxDelay
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	delay := min(p.BaseDelay, limit)
+	for i := 1; i < attempt && delay > 0; i++ {
+		// Checked before doubling, so delay*2 can't overflow
+		if delay > limit/2 {
+			delay = limit
+			break
+		}
+		delay *= 2
+	}
+
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		// Spread the delay uniformly over [delay*(1-jitter), delay*(1+jitter)],
+		// in floating point so a delay near the limit can't wrap around
+		spread := float64(delay) * (1 + (rand.Float64()*2-1)*jitter)
+		if spread >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+		delay = time.Duration(spread)
+	}
+	return delay
+}
+
+// DeadLetter is a job that failed on every attempt its policy allowed
+type DeadLetter struct {
+	Job      Job
+	Attempts int
+	Err      error
+}
+
+// processWithRetry runs job through p until it succeeds, the policy gives up,
+// or ctx is cancelled. It returns the number of attempts made.
+func processWithRetry(ctx context.Context, p Processor, job Job) (Result, int, error) {
+	policy := DefaultRetryPolicy
+	if job.Retry != nil {
+		policy = *job.Retry
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var result Result
+		result, err = p.Process(ctx, job)
+		if err == nil {
+			return result, attempt, nil
+		}
+		if attempt >= policy.MaxAttempts {
+			return Result{}, attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Result{}, attempt, ctx.Err()
+		case <-time.After(policy.Backoff(attempt)):
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.expected {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.expected)
+		}
+	}
+}
+
+func TestBackoffWithoutMaxDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, 1600 * time.Millisecond},
+		{10, 51200 * time.Millisecond},
+		{100, math.MaxInt64},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.expected {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.expected)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("Backoff(1) = %v, want within 50ms of 100ms", got)
+		}
+	}
+}
+
+func TestBackoffLargeAttempts(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"Capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, time.Minute, time.Minute},
+		{"Uncapped", RetryPolicy{BaseDelay: time.Second}, math.MaxInt64, math.MaxInt64},
+		{"Base above cap", RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Minute}, time.Minute, time.Minute},
+		{"Uncapped with jitter", RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}, math.MaxInt64 / 2, math.MaxInt64},
+		{"Jitter above 1", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 5}, 0, 2 * time.Minute},
+		{"Negative jitter", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: -1}, time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, attempt := range []int{63, 64, 1000, math.MaxInt} {
+				for i := 0; i < 20; i++ {
+					if got := tt.policy.Backoff(attempt); got < tt.min || got > tt.max {
+						t.Fatalf("Backoff(%d) = %v, want between %v and %v", attempt, got, tt.min, tt.max)
+					}
+				}
+			}
+		})
+	}
+}
+
+// flakyProcessor fails the first failures calls and then succeeds
+func flakyProcessor(failures int) (Processor, *int) {
+	calls := 0
+	return ProcessorFunc(func(ctx context.Context, job Job) (Result, error) {
+		calls++
+		if calls <= failures {
+			return Result{}, errEnhanceFailed
+		}
+		return Result{JobID: job.ID}, nil
+	}), &calls
+}
+
+func TestProcessWithRetry(t *testing.T) {
+	fast := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	tests := []struct {
+		name         string
+		failures     int
+		expectCalls  int
+		expectFailed bool
+	}{
+		{name: "Succeeds first time", failures: 0, expectCalls: 1},
+		{name: "Succeeds on retry", failures: 2, expectCalls: 3},
+		{name: "Exhausts retries", failures: 5, expectCalls: 3, expectFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, calls := flakyProcessor(tt.failures)
+			_, attempts, err := processWithRetry(context.Background(), p, Job{ID: 1, Retry: fast})
+			if *calls != tt.expectCalls || attempts != tt.expectCalls {
+				t.Errorf("calls = %d, attempts = %d, want %d", *calls, attempts, tt.expectCalls)
+			}
+			if tt.expectFailed != (err != nil) {
+				t.Errorf("processWithRetry() error = %v, expect failure %v", err, tt.expectFailed)
+			}
+		})
+	}
+}
+
+func TestProcessSendsExhaustedJobsToDeadLetters(t *testing.T) {
+	jobs := []Job{
+		{ID: 1, Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}},
+		{ID: 2, Retry: &RetryPolicy{MaxAttempts: 1}},
+	}
+
+	alwaysFails := ProcessorFunc(func(ctx context.Context, job Job) (Result, error) {
+		return Result{}, errEnhanceFailed
+	})
+	results := make(chan Result, 2)
+	dead := make(chan DeadLetter, 2)
+	tracker := NewTracker(2)
+	for _, job := range jobs {
+		process(context.Background(), alwaysFails, job, results, dead, tracker)
+	}
+	close(dead)
+
+	var attempts []int
+	for letter := range dead {
+		if !errors.Is(letter.Err, errEnhanceFailed) {
+			t.Errorf("dead letter error = %v, want errEnhanceFailed", letter.Err)
+		}
+		attempts = append(attempts, letter.Attempts)
+	}
+	if len(attempts) != 2 || attempts[0] != 2 || attempts[1] != 1 {
+		t.Errorf("dead letter attempts = %v, want [2 1]", attempts)
+	}
+
+	p := tracker.Snapshot()
+	if p.Failed != 2 || p.Queued != 0 || p.Running != 0 {
+		t.Errorf("progress = %+v, want 2 failed and nothing queued or running", p)
+	}
+}
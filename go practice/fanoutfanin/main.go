@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"lifecycle"
 	"sync"
 	"time"
 )
@@ -16,12 +18,17 @@ type Result struct {
 	ProcessedImage string
 }
 
-func worker(id int, jobs <-chan Job) <-chan Result {
+// worker processes jobs until they run out or ctx is done, abandoning the
+// image in progress on cancellation
+func worker(ctx context.Context, id int, jobs <-chan Job) <-chan Result {
 	results := make(chan Result)
 	go func() {
 		for job := range jobs {
 			fmt.Printf("Worker %d starting to process image %d\n", id, job.ID)
-			time.Sleep(2 * time.Second)
+			if err := lifecycle.Sleep(ctx, 2*time.Second); err != nil {
+				fmt.Printf("Worker %d stopped: %v\n", id, err)
+				break
+			}
 			processedImage := fmt.Sprintf("enhanced_%d.jpg", job.ID)
 			fmt.Printf("Worker %d finished processing image %d\n", id, job.ID)
 			results <- Result{JobID: job.ID, ProcessedImage: processedImage}
@@ -56,6 +63,10 @@ func fanIn(channels ...<-chan Result) <-chan Result {
 }
 
 func main() {
+	// Ctrl+C or SIGTERM stops the workers, abandoning the images in progress
+	ctx, stop := lifecycle.WithSignals(context.Background())
+	defer stop()
+
 	const numJobs = 10
 	const numWorkers = 3
 
@@ -71,7 +82,7 @@ func main() {
 	// var resultChannels []<-chan Result
 	resultChannels := make([]<-chan Result, numWorkers)
 	for i := 0; i < numWorkers; i++ {
-		resultChannels[i] = worker(i, jobs)
+		resultChannels[i] = worker(ctx, i, jobs)
 	}
 
 	// Fan-in the result channels
@@ -84,5 +95,9 @@ func main() {
 		fmt.Printf("Result: Image %d processed as %s\n", result.JobID, result.ProcessedImage)
 	}
 
+	if ctx.Err() != nil {
+		fmt.Printf("Stopped after %v: %v\n", time.Since(startTime), context.Cause(ctx))
+		return
+	}
 	fmt.Printf("All images processed in %v\n", time.Since(startTime))
-}
\ No newline at end of file
+}
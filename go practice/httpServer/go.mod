@@ -1,3 +1,7 @@
 module server
 
 go 1.23.2
+
+require shared v0.0.0
+
+replace shared => ../../webserver/shared
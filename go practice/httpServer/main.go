@@ -3,11 +3,22 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"shared/server"
 	"time"
 )
 
+// workDuration is how long mainHandler pretends to work
+var workDuration = 30 * time.Second
+
+// mainHandler simulates slow work. It gives up as soon as the client goes
+// away or the per-request timeout passes instead of sleeping regardless.
 func mainHandler(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(time.Second * 30)
+	select {
+	case <-time.After(workDuration):
+	case <-r.Context().Done():
+		fmt.Println("Request abandoned:", r.Context().Err())
+		return
+	}
 	w.WriteHeader(http.StatusAccepted)
 	fmt.Fprintf(w, "done")
 }
@@ -16,5 +27,16 @@ func main() {
 	fmt.Println("Starting server on port 8080")
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", mainHandler)
-	http.ListenAndServe(":8080", mux)
+
+	// The timeouts leave room for the 30 second request, and SIGTERM gives it
+	// time to finish before the process exits
+	cfg := server.DefaultConfig(":8080")
+	cfg.HandlerTimeout = 40 * time.Second
+	cfg.WriteTimeout = 45 * time.Second
+	cfg.ShutdownTimeout = 35 * time.Second
+	if err := server.ListenAndServe(cfg, mux); err != nil {
+		fmt.Println("Server error:", err)
+		return
+	}
+	fmt.Println("Server stopped")
 }
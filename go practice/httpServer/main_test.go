@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMainHandlerStopsWhenClientCancels(t *testing.T) {
+	workDuration = 10 * time.Second
+	defer func() { workDuration = 30 * time.Second }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		mainHandler(w, req)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("mainHandler kept working after the request was cancelled")
+	}
+	if w.Code == http.StatusAccepted {
+		t.Errorf("abandoned request still answered %d", w.Code)
+	}
+}
+
+func TestMainHandlerFinishes(t *testing.T) {
+	workDuration = 10 * time.Millisecond
+	defer func() { workDuration = 30 * time.Second }()
+
+	w := httptest.NewRecorder()
+	mainHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "done" {
+		t.Errorf("mainHandler = %d %q, want 202 done", w.Code, w.Body)
+	}
+}
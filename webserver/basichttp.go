@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"shared/server"
 )
 
 func handler(w http.ResponseWriter, r *http.Request) {
@@ -11,6 +12,8 @@ func handler(w http.ResponseWriter, r *http.Request) {
 
 func main() {
 	http.HandleFunc("/test", handler)
-	err := http.ListenAndServe(":8000", nil)
-	fmt.Println(err)
+	// Serve with timeouts; SIGINT or SIGTERM drains in-flight requests
+	if err := server.ListenAndServe(server.DefaultConfig(":8000"), http.DefaultServeMux); err != nil {
+		fmt.Println(err)
+	}
 }
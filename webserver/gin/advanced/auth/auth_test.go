@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestHasScope(t *testing.T) {
+	p := Principal{Scopes: []string{"products:read", "files:*"}}
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"products:read", true},
+		{"products:write", false},
+		{"files:write", true},
+		{"files", false},
+	}
+	for _, tt := range tests {
+		if got := p.HasScope(tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	keys := NewKeyStore()
+	keys.now = clk.now
+
+	plaintext, key := keys.Issue("alice", []string{"products:write"}, time.Hour)
+	if !strings.HasPrefix(plaintext, keyPrefix) {
+		t.Errorf("key %q lacks the %q prefix", plaintext, keyPrefix)
+	}
+	for hash := range keys.byHash {
+		if strings.Contains(hash, plaintext) {
+			t.Fatal("plaintext key stored")
+		}
+	}
+
+	p, err := keys.Authenticate(plaintext)
+	if err != nil || p.Subject != "alice" || p.KeyID != key.ID || !p.HasScope("products:write") {
+		t.Fatalf("Authenticate = %+v, %v", p, err)
+	}
+	if _, err := keys.Authenticate(plaintext + "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong key error = %v", err)
+	}
+
+	// Rotation: both keys work during the grace period, then only the new one
+	rotated, newKey, err := keys.Rotate(key.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKey.Subject != "alice" || newKey.ExpiresAt.Sub(newKey.CreatedAt) != time.Hour {
+		t.Errorf("rotated key = %+v, want alice's subject and lifetime", newKey)
+	}
+	if _, err := keys.Authenticate(plaintext); err != nil {
+		t.Errorf("old key rejected during grace: %v", err)
+	}
+	clk.advance(time.Minute)
+	if _, err := keys.Authenticate(plaintext); !errors.Is(err, ErrExpired) {
+		t.Errorf("old key after grace error = %v, want ErrExpired", err)
+	}
+	if _, err := keys.Authenticate(rotated); err != nil {
+		t.Errorf("rotated key rejected: %v", err)
+	}
+
+	clk.advance(time.Hour)
+	if _, err := keys.Authenticate(rotated); !errors.Is(err, ErrExpired) {
+		t.Errorf("rotated key after its lifetime error = %v, want ErrExpired", err)
+	}
+
+	if err := keys.Revoke(newKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(rotated); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("revoked key error = %v", err)
+	}
+	if _, _, err := keys.Rotate("nope", 0); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Rotate(unknown) error = %v", err)
+	}
+}
+
+func TestKeyStorePrunesExpiredKeys(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	keys := NewKeyStore()
+	keys.now = clk.now
+
+	_, key := keys.Issue("alice", nil, 0)
+	for i := 0; i < 3; i++ {
+		_, rotated, err := keys.Rotate(key.ID, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key = rotated
+		clk.advance(time.Minute)
+	}
+	keys.Issue("bob", nil, time.Second)
+	clk.advance(time.Second)
+	if err := keys.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every rotated key is past its grace period and bob's key has expired
+	if len(keys.byHash) != 0 || len(keys.byID) != 0 {
+		t.Errorf("%d hashes and %d IDs left, want none", len(keys.byHash), len(keys.byID))
+	}
+}
+
+func TestSigner(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	signer, err := NewSigner(testSecret, "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.now = clk.now
+
+	token, err := signer.Sign("alice", "key-1", []string{"products:write", "files:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := signer.Verify(token)
+	if err != nil || p.Subject != "alice" || len(p.Scopes) != 2 || p.Method != "bearer" || p.KeyID != "key-1" {
+		t.Fatalf("Verify = %+v, %v", p, err)
+	}
+
+	parts := strings.Split(token, ".")
+	forge := func(header, payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(payload)) + "."
+	}
+	other, _ := NewSigner([]byte(strings.Repeat("x", 32)), "test", time.Hour)
+	otherToken, _ := other.Sign("alice", "", nil)
+	wrongIssuer, _ := NewSigner(testSecret, "elsewhere", time.Hour)
+	wrongIssuerToken, _ := wrongIssuer.Sign("alice", "", nil)
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"Garbage", "not.a.token", ErrInvalidToken},
+		{"Two segments", parts[0] + "." + parts[1], ErrInvalidToken},
+		{"Tampered claims", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"root","scope":"*"}`)) + "." + parts[2], ErrInvalidToken},
+		{"Algorithm none", forge(`{"alg":"none","typ":"JWT"}`, `{"sub":"root","scope":"*"}`), ErrInvalidToken},
+		{"Other secret", otherToken, ErrInvalidToken},
+		{"Other issuer", wrongIssuerToken, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := signer.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("Verify error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	clk.advance(time.Hour + time.Minute)
+	if _, err := signer.Verify(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired token error = %v, want ErrExpired", err)
+	}
+
+	if _, err := NewSigner([]byte("short"), "test", time.Hour); err == nil {
+		t.Error("NewSigner accepted a short secret")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := NewKeyStore()
+	writer, _ := keys.Issue("writer", []string{"products:write", "products:read"}, 0)
+	reader, _ := keys.Issue("reader", []string{"products:read"}, 0)
+	signer, _ := NewSigner(testSecret, "test", time.Hour)
+
+	r := gin.New()
+	api := r.Group("", Authenticate(keys, signer))
+	api.POST("/token", TokenHandler(signer))
+	api.POST("/products", RequireScope("products:write"), func(c *gin.Context) {
+		p, _ := PrincipalFrom(c)
+		c.String(http.StatusCreated, p.Subject)
+	})
+
+	send := func(target, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	token := func(body string) string {
+		t.Helper()
+		w := send("/token", body, "X-API-Key", writer)
+		var resp struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusOK || resp.AccessToken == "" {
+			t.Fatalf("POST /token %s = %d", body, w.Code)
+		}
+		return resp.AccessToken
+	}
+	fullToken := token("")
+	// Narrow the writer's key to a read-only token
+	readToken := token(`{"scopes":["products:read"]}`)
+
+	tests := []struct {
+		name     string
+		headers  []string
+		wantCode int
+	}{
+		{"No credentials", nil, http.StatusUnauthorized},
+		{"Unknown key", []string{"X-API-Key", "your-secret-key"}, http.StatusUnauthorized},
+		{"Key with scope", []string{"X-API-Key", writer}, http.StatusCreated},
+		{"Key without scope", []string{"X-API-Key", reader}, http.StatusForbidden},
+		{"Token with scope", []string{"Authorization", "Bearer " + fullToken}, http.StatusCreated},
+		{"Narrowed token", []string{"Authorization", "Bearer " + readToken}, http.StatusForbidden},
+		{"Basic auth", []string{"Authorization", "Basic d3JpdGVyOg=="}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send("/products", "", tt.headers...)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+
+	// A key cannot mint a token with scopes it doesn't hold
+	if w := send("/token", `{"scopes":["products:write"]}`, "X-API-Key", reader); w.Code != http.StatusForbidden {
+		t.Errorf("escalating token request = %d, want 403", w.Code)
+	}
+	// A token cannot be exchanged for a fresh one
+	if w := send("/token", "", "Authorization", "Bearer "+fullToken); w.Code != http.StatusForbidden {
+		t.Errorf("token refresh with a bearer token = %d, want 403", w.Code)
+	}
+}
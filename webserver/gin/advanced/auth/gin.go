@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey is the Gin context key Authenticate stores the Principal under
+const PrincipalKey = "auth.principal"
+
+// Identify verifies the "Authorization: Bearer <token>" or "X-API-Key" header
+// of r and returns the caller's Principal
+func Identify(keys *KeyStore, signer *Signer, r *http.Request) (Principal, error) {
+	if authz := r.Header.Get("Authorization"); authz != "" {
+		token, ok := strings.CutPrefix(authz, "Bearer ")
+		if !ok {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return signer.Verify(strings.TrimSpace(token))
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return keys.Authenticate(key)
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+// Authenticate accepts either "Authorization: Bearer <token>" or an
+// "X-API-Key" header, stores the Principal on the context and rejects
+// everything else with 401.
+func Authenticate(keys *KeyStore, signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := Identify(keys, signer, c.Request)
+		if err != nil {
+			message := "Invalid API key or token"
+			if errors.Is(err, ErrExpired) {
+				message = "Credentials expired"
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			abort(c, http.StatusUnauthorized, message)
+			return
+		}
+		c.Set(PrincipalKey, p)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose principal lacks scope with 403. It must
+// run after Authenticate.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !p.HasScope(scope) {
+			abort(c, http.StatusForbidden, "Missing scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// PrincipalFrom returns the Principal Authenticate stored on c
+func PrincipalFrom(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(PrincipalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// TokenRequest is the optional body of a token request
+type TokenRequest struct {
+	// Scopes narrows the token to a subset of the caller's scopes
+	Scopes []string `json:"scopes"`
+}
+
+// TokenResponse is an issued bearer token, shaped like an OAuth 2.0 token response
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // seconds
+	Scope       string `json:"scope"`      // space-separated
+}
+
+// TokenHandler exchanges the caller's API key for a bearer token. The
+// optional JSON body {"scopes": [...]} narrows the token to a subset of the
+// key's scopes. Bearer tokens can't be exchanged for fresh ones, so a token
+// dies with its expiry and revoking the key stops new tokens.
+func TokenHandler(signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if p.Method != "api_key" {
+			abort(c, http.StatusForbidden, "Tokens are only issued for API keys")
+			return
+		}
+
+		var req TokenRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				abort(c, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		scopes := p.Scopes
+		if req.Scopes != nil {
+			for _, s := range req.Scopes {
+				if !p.HasScope(s) {
+					abort(c, http.StatusForbidden, "Cannot grant scope "+s)
+					return
+				}
+			}
+			scopes = req.Scopes
+		}
+
+		token, err := signer.Sign(p.Subject, p.KeyID, scopes)
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "Failed to sign token")
+			return
+		}
+		c.JSON(http.StatusOK, TokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   int(signer.ttl.Seconds()),
+			Scope:       strings.Join(scopes, " "),
+		})
+	}
+}
+
+// abort ends the request with the API's {code, message} error body
+func abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": status, "message": message})
+}
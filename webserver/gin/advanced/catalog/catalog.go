@@ -0,0 +1,305 @@
+// Package catalog stores the products served by the Gin advanced API.
+//
+// Repository is the storage abstraction the handlers depend on. Memory keeps
+// products in a map and File persists the same map to a JSON file after every
+// write. Deleting a product is a soft delete: it is hidden from Get and List
+// but kept, with DeletedAt set, until it is restored.
+package catalog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when no live product has the requested ID
+var ErrNotFound = errors.New("catalog: product not found")
+
+// ErrInvalidQuery is returned by List for an unknown sort order or bad bounds
+var ErrInvalidQuery = errors.New("catalog: invalid query")
+
+// Page size limits for List
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Product is a stored product. ID, CreatedAt, UpdatedAt and DeletedAt are
+// managed by the repository; values passed in by callers are ignored.
+type Product struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name" binding:"required,min=3"`
+	Price       float64    `json:"price" binding:"required,gt=0"`
+	Description string     `json:"description" binding:"required"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Query selects, orders and pages the products returned by List
+type Query struct {
+	Name     string  // keep products whose name contains this, ignoring case
+	MinPrice float64 // inclusive lower price bound; 0 means none
+	MaxPrice float64 // inclusive upper price bound; 0 means none
+	// Sort is "created_at" (the default), "name" or "price", optionally
+	// prefixed with "-" for descending
+	Sort           string
+	Limit          int  // page size; 0 means DefaultLimit and anything above MaxLimit is capped
+	Offset         int  // number of matching products to skip
+	IncludeDeleted bool // also return soft-deleted products
+}
+
+// Page is one page of List results
+type Page struct {
+	Products []Product `json:"products"`
+	Total    int       `json:"total"` // matching products across all pages
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// Repository stores products. Implementations are safe for concurrent use.
+type Repository interface {
+	// Create stores p under a newly generated ID and returns the stored product
+	Create(p Product) (Product, error)
+	// Get returns the live product with id, or ErrNotFound
+	Get(id string) (Product, error)
+	// Update replaces the name, price and description of the live product p.ID
+	Update(p Product) (Product, error)
+	// Delete soft-deletes the live product with id
+	Delete(id string) error
+	// Restore undoes the soft delete of the product with id
+	Restore(id string) (Product, error)
+	// List returns one page of the products matching q
+	List(q Query) (Page, error)
+}
+
+// Memory is an in-memory Repository. The zero value is not usable; call NewMemory.
+type Memory struct {
+	mu       sync.RWMutex
+	products map[string]Product
+	// persist, if set, is called with every product after each change and
+	// the change is rolled back if it fails. Called with mu held.
+	persist func([]Product) error
+	now     func() time.Time
+}
+
+// NewMemory creates an empty in-memory repository
+func NewMemory() *Memory {
+	return &Memory{products: make(map[string]Product), now: time.Now}
+}
+
+// newID returns a random 16 character hex ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Create implements Repository
+func (m *Memory) Create(p Product) (Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p.ID = newID()
+	for _, taken := m.products[p.ID]; taken; _, taken = m.products[p.ID] {
+		p.ID = newID()
+	}
+	p.CreatedAt = m.now().UTC()
+	p.UpdatedAt = p.CreatedAt
+	p.DeletedAt = nil
+	if err := m.put(p); err != nil {
+		return Product{}, err
+	}
+	return p, nil
+}
+
+// Get implements Repository
+func (m *Memory) Get(id string) (Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.live(id)
+}
+
+// Update implements Repository
+func (m *Memory) Update(p Product) (Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, err := m.live(p.ID)
+	if err != nil {
+		return Product{}, err
+	}
+	cur.Name, cur.Price, cur.Description = p.Name, p.Price, p.Description
+	cur.UpdatedAt = m.now().UTC()
+	if err := m.put(cur); err != nil {
+		return Product{}, err
+	}
+	return cur, nil
+}
+
+// Delete implements Repository
+func (m *Memory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, err := m.live(id)
+	if err != nil {
+		return err
+	}
+	now := m.now().UTC()
+	cur.DeletedAt = &now
+	cur.UpdatedAt = now
+	return m.put(cur)
+}
+
+// Restore implements Repository
+func (m *Memory) Restore(id string) (Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.products[id]
+	if !ok {
+		return Product{}, ErrNotFound
+	}
+	if cur.DeletedAt == nil {
+		return cur, nil
+	}
+	cur.DeletedAt = nil
+	cur.UpdatedAt = m.now().UTC()
+	if err := m.put(cur); err != nil {
+		return Product{}, err
+	}
+	return cur, nil
+}
+
+// List implements Repository
+func (m *Memory) List(q Query) (Page, error) {
+	m.mu.RLock()
+	products := make([]Product, 0, len(m.products))
+	for _, p := range m.products {
+		products = append(products, p)
+	}
+	m.mu.RUnlock()
+	return list(products, q)
+}
+
+// live returns the product with id unless it is missing or deleted. Callers must hold mu.
+func (m *Memory) live(id string) (Product, error) {
+	p, ok := m.products[id]
+	if !ok || p.DeletedAt != nil {
+		return Product{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// put stores p and persists the result, restoring the previous state if
+// persisting fails. Callers must hold mu.
+func (m *Memory) put(p Product) error {
+	prev, existed := m.products[p.ID]
+	m.products[p.ID] = p
+	if m.persist == nil {
+		return nil
+	}
+	if err := m.persist(m.all()); err != nil {
+		if existed {
+			m.products[p.ID] = prev
+		} else {
+			delete(m.products, p.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// all returns every product, deleted or not, in creation order. Callers must hold mu.
+func (m *Memory) all() []Product {
+	products := make([]Product, 0, len(m.products))
+	for _, p := range m.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool { return lessCreated(products[i], products[j]) })
+	return products
+}
+
+func lessCreated(a, b Product) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// lessFunc returns the ordering for sortBy, with creation order breaking ties
+func lessFunc(sortBy string) (func(a, b Product) bool, error) {
+	desc := strings.HasPrefix(sortBy, "-")
+	var less func(a, b Product) bool
+	switch strings.TrimPrefix(sortBy, "-") {
+	case "created_at":
+		less = lessCreated
+	case "name":
+		less = func(a, b Product) bool {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return lessCreated(a, b)
+		}
+	case "price":
+		less = func(a, b Product) bool {
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+			return lessCreated(a, b)
+		}
+	default:
+		return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, sortBy)
+	}
+	if desc {
+		return func(a, b Product) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+// list applies q to products, which the caller owns
+func list(products []Product, q Query) (Page, error) {
+	if q.Sort == "" {
+		q.Sort = "created_at"
+	}
+	less, err := lessFunc(q.Sort)
+	if err != nil {
+		return Page{}, err
+	}
+	switch {
+	case q.Limit < 0:
+		return Page{}, fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit == 0:
+		q.Limit = DefaultLimit
+	case q.Limit > MaxLimit:
+		q.Limit = MaxLimit
+	}
+	if q.Offset < 0 {
+		return Page{}, fmt.Errorf("%w: negative offset", ErrInvalidQuery)
+	}
+	if q.MinPrice < 0 || q.MaxPrice < 0 || (q.MaxPrice > 0 && q.MinPrice > q.MaxPrice) {
+		return Page{}, fmt.Errorf("%w: bad price range", ErrInvalidQuery)
+	}
+
+	matched := products[:0]
+	needle := strings.ToLower(q.Name)
+	for _, p := range products {
+		switch {
+		case p.DeletedAt != nil && !q.IncludeDeleted:
+		case !strings.Contains(strings.ToLower(p.Name), needle):
+		case p.Price < q.MinPrice:
+		case q.MaxPrice > 0 && p.Price > q.MaxPrice:
+		default:
+			matched = append(matched, p)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	page := Page{Products: []Product{}, Total: len(matched), Limit: q.Limit, Offset: q.Offset}
+	if q.Offset < len(matched) {
+		page.Products = matched[q.Offset:min(q.Offset+q.Limit, len(matched))]
+	}
+	return page, nil
+}
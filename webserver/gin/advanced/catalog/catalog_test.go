@@ -0,0 +1,188 @@
+package catalog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testRepository runs the behaviour every Repository must share
+func testRepository(t *testing.T, repo Repository) {
+	t.Helper()
+
+	lamp, err := repo.Create(Product{ID: "chosen", Name: "Desk lamp", Price: 25, Description: "LED"})
+	if err != nil {
+		t.Fatalf("Create(lamp): %v", err)
+	}
+	if lamp.ID == "" || lamp.ID == "chosen" || lamp.CreatedAt.IsZero() {
+		t.Errorf("new product = %+v, want a generated ID and timestamps", lamp)
+	}
+	chair, err := repo.Create(Product{Name: "Office chair", Price: 150, Description: "Mesh"})
+	if err != nil {
+		t.Fatalf("Create(chair): %v", err)
+	}
+	if chair.ID == lamp.ID {
+		t.Fatal("two products share an ID")
+	}
+
+	if got, err := repo.Get(lamp.ID); err != nil || !reflect.DeepEqual(got, lamp) {
+		t.Errorf("Get(%s) = %+v, %v, want %+v", lamp.ID, got, err, lamp)
+	}
+
+	changed := lamp
+	changed.Price = 30
+	changed.CreatedAt = time.Time{}
+	changed, err = repo.Update(changed)
+	if err != nil || changed.Price != 30 || !changed.CreatedAt.Equal(lamp.CreatedAt) {
+		t.Fatalf("Update = %+v, %v, want the new price and the original creation time", changed, err)
+	}
+	if _, err := repo.Update(Product{ID: "missing", Name: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// Soft delete hides the product but keeps it for restoring
+	if err := repo.Delete(chair.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Get(chair.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(chair.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Update(chair); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of a deleted product error = %v, want ErrNotFound", err)
+	}
+	if page, _ := repo.List(Query{}); page.Total != 1 {
+		t.Errorf("List total = %d, want 1 live product", page.Total)
+	}
+	page, _ := repo.List(Query{IncludeDeleted: true})
+	if page.Total != 2 {
+		t.Errorf("List(IncludeDeleted) total = %d, want 2", page.Total)
+	}
+	for _, p := range page.Products {
+		if (p.DeletedAt != nil) != (p.ID == chair.ID) {
+			t.Errorf("listed %s with DeletedAt %v", p.Name, p.DeletedAt)
+		}
+	}
+	restored, err := repo.Restore(chair.ID)
+	if err != nil || restored.DeletedAt != nil {
+		t.Fatalf("Restore = %+v, %v", restored, err)
+	}
+	if _, err := repo.Get(chair.ID); err != nil {
+		t.Errorf("Get after Restore: %v", err)
+	}
+	if _, err := repo.Restore("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testRepository(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRepository(t, f)
+
+	want, _ := f.List(Query{IncludeDeleted: true})
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := reopened.List(Query{IncludeDeleted: true})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after reopening List = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileRollsBackFailedWrites(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenFile(filepath.Join(dir, "products.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.Create(Product{Name: "Kettle", Price: 20, Description: "Steel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the directory the temporary file cannot be created
+	os.RemoveAll(dir)
+	if _, err := f.Create(Product{Name: "Toaster", Price: 30, Description: "2 slots"}); err == nil {
+		t.Fatal("Create succeeded without a directory")
+	}
+	if err := f.Delete(p.ID); err == nil {
+		t.Fatal("Delete succeeded without a directory")
+	}
+	if page, _ := f.List(Query{}); page.Total != 1 || page.Products[0].ID != p.ID {
+		t.Errorf("after failed writes List = %+v, want only the kettle", page)
+	}
+}
+
+func TestList(t *testing.T) {
+	m := NewMemory()
+	clock := time.Unix(1_700_000_000, 0)
+	m.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	for _, p := range []Product{
+		{Name: "Red pen", Price: 2},
+		{Name: "Blue pen", Price: 3},
+		{Name: "Notebook", Price: 8},
+		{Name: "Pencil case", Price: 12},
+		{Name: "Fountain pen", Price: 40},
+	} {
+		if _, err := m.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(page Page) []string {
+		var names []string
+		for _, p := range page.Products {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name      string
+		q         Query
+		want      []string
+		wantTotal int
+	}{
+		{"Default order", Query{Limit: 2}, []string{"Red pen", "Blue pen"}, 5},
+		{"Second page", Query{Limit: 2, Offset: 2}, []string{"Notebook", "Pencil case"}, 5},
+		{"Past the end", Query{Offset: 10}, nil, 5},
+		{"Name search", Query{Name: "PEN", Sort: "name"}, []string{"Blue pen", "Fountain pen", "Pencil case", "Red pen"}, 4},
+		{"Price range", Query{MinPrice: 3, MaxPrice: 12, Sort: "-price"}, []string{"Pencil case", "Notebook", "Blue pen"}, 3},
+		{"Minimum only", Query{MinPrice: 10}, []string{"Pencil case", "Fountain pen"}, 2},
+		{"Search and range", Query{Name: "pen", MaxPrice: 3}, []string{"Red pen", "Blue pen"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := m.List(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(page); !reflect.DeepEqual(got, tt.want) || page.Total != tt.wantTotal {
+				t.Errorf("List = %q (total %d), want %q (total %d)", got, page.Total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	for _, q := range []Query{{Sort: "colour"}, {Limit: -1}, {Offset: -1}, {MinPrice: 5, MaxPrice: 1}, {MinPrice: -1}} {
+		if _, err := m.List(q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("List(%+v) error = %v, want ErrInvalidQuery", q, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File is a Repository persisted to a JSON file. Every change rewrites the
+// whole file through a temporary file and a rename, so a crash leaves either
+// the old or the new catalog on disk and never a partial one. That is cheap
+// enough for a catalog of a few thousand products.
+type File struct {
+	*Memory
+	path string
+}
+
+// OpenFile loads the catalog at path, which is created on the first write
+func OpenFile(path string) (*File, error) {
+	f := &File{Memory: NewMemory(), path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var products []Product
+		if err := json.Unmarshal(data, &products); err != nil {
+			return nil, fmt.Errorf("catalog: %s: %w", path, err)
+		}
+		for _, p := range products {
+			f.products[p.ID] = p
+		}
+	}
+	f.persist = f.save
+	return f, nil
+}
+
+// save atomically replaces the file with products
+func (f *File) save(products []Product) error {
+	data, err := json.MarshalIndent(products, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
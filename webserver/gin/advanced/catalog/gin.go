@@ -0,0 +1,156 @@
+package catalog
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register adds the /products routes that change the catalog to write and
+// the ones that read it to read, so each group can carry its own scope check.
+//
+//	GET    /products             list; see listProducts for the parameters
+//	POST   /products             create
+//	GET    /products/:id         get
+//	PUT    /products/:id         replace name, price and description
+//	DELETE /products/:id         soft delete
+//	POST   /products/:id/restore undo a soft delete
+func Register(write, read gin.IRoutes, repo Repository) {
+	h := &handlers{repo: repo}
+	read.GET("/products", h.listProducts)
+	read.GET("/products/:id", h.getProduct)
+	write.POST("/products", h.createProduct)
+	write.PUT("/products/:id", h.updateProduct)
+	write.DELETE("/products/:id", h.deleteProduct)
+	write.POST("/products/:id/restore", h.restoreProduct)
+}
+
+type handlers struct {
+	repo Repository
+}
+
+// listProducts returns a page of products. Query parameters: name (contains,
+// ignoring case), min_price and max_price (inclusive), sort (created_at, name,
+// price, or any of them prefixed with "-"), limit, offset and
+// include_deleted=true.
+func (h *handlers) listProducts(c *gin.Context) {
+	q := Query{Name: c.Query("name"), Sort: c.Query("sort")}
+	var err error
+	if q.MinPrice, err = floatParam(c, "min_price"); err != nil {
+		abort(c, http.StatusBadRequest, "min_price must be a number")
+		return
+	}
+	if q.MaxPrice, err = floatParam(c, "max_price"); err != nil {
+		abort(c, http.StatusBadRequest, "max_price must be a number")
+		return
+	}
+	if q.Limit, err = intParam(c, "limit"); err != nil {
+		abort(c, http.StatusBadRequest, "limit must be an integer")
+		return
+	}
+	if q.Offset, err = intParam(c, "offset"); err != nil {
+		abort(c, http.StatusBadRequest, "offset must be an integer")
+		return
+	}
+	q.IncludeDeleted = c.Query("include_deleted") == "true"
+
+	page, err := h.repo.List(q)
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *handlers) createProduct(c *gin.Context) {
+	var p Product
+	if err := c.ShouldBindJSON(&p); err != nil {
+		abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, err := h.repo.Create(p)
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+p.ID)
+	c.JSON(http.StatusCreated, p)
+}
+
+func (h *handlers) getProduct(c *gin.Context) {
+	p, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *handlers) updateProduct(c *gin.Context) {
+	var p Product
+	if err := c.ShouldBindJSON(&p); err != nil {
+		abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	p.ID = c.Param("id")
+	p, err := h.repo.Update(p)
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *handlers) deleteProduct(c *gin.Context) {
+	if err := h.repo.Delete(c.Param("id")); err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handlers) restoreProduct(c *gin.Context) {
+	p, err := h.repo.Restore(c.Param("id"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// floatParam parses the query parameter name, which defaults to 0
+func floatParam(c *gin.Context, name string) (float64, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// intParam parses the query parameter name, which defaults to 0
+func intParam(c *gin.Context, name string) (int, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// abortStoreError maps repository errors to statuses
+func abortStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		abort(c, http.StatusNotFound, "Product not found")
+	case errors.Is(err, ErrInvalidQuery):
+		abort(c, http.StatusBadRequest, err.Error())
+	default:
+		abort(c, http.StatusInternalServerError, "Failed to access the catalog")
+	}
+}
+
+// abort ends the request with the API's {code, message} error body
+func abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": status, "message": message})
+}
@@ -0,0 +1,151 @@
+package catalog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// Stand-in for a scope check: writes need the X-Writer header
+	canWrite := func(c *gin.Context) {
+		if c.GetHeader("X-Writer") == "" {
+			abort(c, http.StatusForbidden, "read only")
+		}
+	}
+	api := r.Group("/api")
+	Register(api.Group("", canWrite), api, NewMemory())
+	return r
+}
+
+type client struct {
+	t *testing.T
+	r *gin.Engine
+}
+
+// do sends a request as a writer and decodes a JSON response into out
+func (c client) do(method, target, body string, out any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Writer", "yes")
+	w := httptest.NewRecorder()
+	c.r.ServeHTTP(w, req)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("%s %s: decoding %q: %v", method, target, w.Body, err)
+		}
+	}
+	return w
+}
+
+func TestRoutes(t *testing.T) {
+	c := client{t, newTestRouter()}
+
+	// POST /products
+	var lamp Product
+	w := c.do(http.MethodPost, "/api/products", `{"name":"Desk lamp","price":25,"description":"LED"}`, &lamp)
+	if w.Code != http.StatusCreated || lamp.ID == "" {
+		t.Fatalf("create = %d %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Location"); got != "/api/products/"+lamp.ID {
+		t.Errorf("Location = %q", got)
+	}
+	c.do(http.MethodPost, "/api/products", `{"name":"Office chair","price":150,"description":"Mesh"}`, nil)
+
+	// GET /products/:id
+	var got Product
+	if w := c.do(http.MethodGet, "/api/products/"+lamp.ID, "", &got); w.Code != http.StatusOK || got.Name != "Desk lamp" {
+		t.Errorf("get = %d %s", w.Code, w.Body)
+	}
+
+	// PUT /products/:id
+	if w := c.do(http.MethodPut, "/api/products/"+lamp.ID, `{"name":"Desk lamp","price":30,"description":"LED"}`, &got); w.Code != http.StatusOK || got.Price != 30 {
+		t.Errorf("update = %d %s", w.Code, w.Body)
+	}
+
+	// GET /products
+	var page Page
+	if w := c.do(http.MethodGet, "/api/products?name=lamp&min_price=20&max_price=40&limit=5", "", &page); w.Code != http.StatusOK || page.Total != 1 || page.Limit != 5 {
+		t.Errorf("search = %d %s", w.Code, w.Body)
+	}
+	if w := c.do(http.MethodGet, "/api/products?sort=-price&limit=1&offset=1", "", &page); w.Code != http.StatusOK || len(page.Products) != 1 || page.Products[0].ID != lamp.ID {
+		t.Errorf("second page by price = %d %s", w.Code, w.Body)
+	}
+
+	// DELETE /products/:id
+	if w := c.do(http.MethodDelete, "/api/products/"+lamp.ID, "", nil); w.Code != http.StatusNoContent {
+		t.Errorf("delete = %d %s", w.Code, w.Body)
+	}
+	if w := c.do(http.MethodGet, "/api/products/"+lamp.ID, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete = %d", w.Code)
+	}
+	if w := c.do(http.MethodGet, "/api/products?include_deleted=true", "", &page); w.Code != http.StatusOK || page.Total != 2 {
+		t.Errorf("list with deleted = %d %s", w.Code, w.Body)
+	}
+
+	// POST /products/:id/restore
+	if w := c.do(http.MethodPost, "/api/products/"+lamp.ID+"/restore", "", &got); w.Code != http.StatusOK || got.DeletedAt != nil {
+		t.Errorf("restore = %d %s", w.Code, w.Body)
+	}
+	if w := c.do(http.MethodGet, "/api/products/"+lamp.ID, "", nil); w.Code != http.StatusOK {
+		t.Errorf("get after restore = %d", w.Code)
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	r := newTestRouter()
+	c := client{t, r}
+
+	tests := []struct {
+		name, method, target, body string
+		wantCode                   int
+	}{
+		{"Name too short", http.MethodPost, "/api/products", `{"name":"ab","price":1,"description":"x"}`, http.StatusBadRequest},
+		{"Zero price", http.MethodPost, "/api/products", `{"name":"Lamp","price":0,"description":"x"}`, http.StatusBadRequest},
+		{"Malformed JSON", http.MethodPost, "/api/products", `{"name":`, http.StatusBadRequest},
+		{"Get missing", http.MethodGet, "/api/products/missing", "", http.StatusNotFound},
+		{"Update missing", http.MethodPut, "/api/products/missing", `{"name":"Lamp","price":1,"description":"x"}`, http.StatusNotFound},
+		{"Delete missing", http.MethodDelete, "/api/products/missing", "", http.StatusNotFound},
+		{"Restore missing", http.MethodPost, "/api/products/missing/restore", "", http.StatusNotFound},
+		{"Bad limit", http.MethodGet, "/api/products?limit=ten", "", http.StatusBadRequest},
+		{"Bad offset", http.MethodGet, "/api/products?offset=-1", "", http.StatusBadRequest},
+		{"Bad price", http.MethodGet, "/api/products?min_price=cheap", "", http.StatusBadRequest},
+		{"Inverted range", http.MethodGet, "/api/products?min_price=10&max_price=1", "", http.StatusBadRequest},
+		{"Unknown sort", http.MethodGet, "/api/products?sort=colour", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := c.do(tt.method, tt.target, tt.body, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+			var body struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != tt.wantCode || body.Message == "" {
+				t.Errorf("error body = %s, want {code, message}", w.Body)
+			}
+		})
+	}
+
+	// Reads are open, writes go through the extra handlers
+	req := httptest.NewRequest(http.MethodPost, "/api/products", strings.NewReader(`{"name":"Lamp","price":1,"description":"x"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("create without write access = %d, want 403", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("list without write access = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"ginadvanced/ratelimit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config is the server's configuration, read from -config (see
+// config.example.yaml), the environment and flags. CORS origins and rate
+// limits are reloaded from the same sources on SIGHUP; other changes need a
+// restart.
+type Config struct {
+	Addr         string   `yaml:"addr" default:":8080" env:"ADDR" flag:"addr" usage:"listen address"`
+	APIKey       string   `yaml:"api_key" env:"API_KEY" validate:"required"`
+	JWTSecret    string   `yaml:"jwt_secret" env:"JWT_SECRET"`
+	ProductsFile string   `yaml:"products_file" env:"PRODUCTS_FILE" flag:"products" usage:"file to persist the product catalog in; empty keeps it in memory"`
+	UploadsDir   string   `yaml:"uploads_dir" default:"uploads" env:"UPLOADS_DIR" flag:"uploads" usage:"directory uploaded files are stored in"`
+	CORSOrigins  []string `yaml:"cors_origins" default:"http://localhost:3000" env:"CORS_ORIGINS" reload:"true"`
+	RateLimits   struct {
+		// Requests per minute per client IP, with the burst allowed on top.
+		// A rate of 0 is rejected rather than turning the limit off.
+		Default      int `yaml:"default" default:"60" validate:"min=1"`
+		DefaultBurst int `yaml:"default_burst" default:"10" validate:"min=0"`
+		// Upload requests per minute per client IP, counting each request
+		// of a chunked upload
+		Upload int `yaml:"upload" default:"10" validate:"min=1"`
+		// Requests per minute for the API key, shared by every route
+		APIKey      int `yaml:"api_key" default:"600" validate:"min=1"`
+		APIKeyBurst int `yaml:"api_key_burst" default:"50" validate:"min=0"`
+	} `yaml:"rate_limits" reload:"true"`
+}
+
+// uploadRoutes are the routes the upload rate limit applies to
+var uploadRoutes = []string{
+	"POST /api/v1/upload",
+	"POST /api/v1/uploads",
+	"PATCH /api/v1/uploads/:id",
+	"POST /api/v1/uploads/:id/complete",
+}
+
+// rateLimits builds the rate limiter's policies from cfg: a default one, a
+// stricter one for uploads and a larger quota for the configured API key,
+// which keyID identifies so the plaintext key stays out of the limiter. Bearer
+// tokens issued for the key share its quota. identify resolves the caller's
+// key ID.
+func rateLimits(limiter *ratelimit.Limiter, cfg *Config, keyID string, identify func(*gin.Context) (string, bool)) (ratelimit.Config, error) {
+	rl := cfg.RateLimits
+	upload := ratelimit.Policy{
+		Name:      "upload",
+		Algorithm: ratelimit.SlidingWindowLog,
+		Limit:     ratelimit.Limit{Rate: rl.Upload, Period: time.Minute},
+	}
+	routes := make(map[string]ratelimit.Policy, len(uploadRoutes))
+	for _, route := range uploadRoutes {
+		routes[route] = upload
+	}
+	policies := ratelimit.Config{
+		Limiter: limiter,
+		Default: ratelimit.Policy{
+			Name:      "default",
+			Algorithm: ratelimit.GCRA,
+			Limit:     ratelimit.Limit{Rate: rl.Default, Period: time.Minute, Burst: rl.DefaultBurst},
+		},
+		Routes: routes,
+		Principals: map[string]ratelimit.Policy{
+			keyID: {
+				Name:      "api-key",
+				Algorithm: ratelimit.TokenBucket,
+				Limit:     ratelimit.Limit{Rate: rl.APIKey, Period: time.Minute, Burst: rl.APIKeyBurst},
+			},
+		},
+		Identify: identify,
+	}
+	return policies, policies.Validate()
+}
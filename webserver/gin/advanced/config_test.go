@@ -0,0 +1,69 @@
+package main
+
+import (
+	"ginadvanced/ratelimit"
+	"os"
+	"path/filepath"
+	"shared/config"
+	"strings"
+	"testing"
+)
+
+func TestConfigRejectsZeroRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"Defaults", "api_key: k\n", ""},
+		{"Zero burst", "api_key: k\nrate_limits:\n  default_burst: 0\n", ""},
+		{"Zero default rate", "api_key: k\nrate_limits:\n  default: 0\n", "RateLimits.Default:"},
+		{"Zero upload rate", "api_key: k\nrate_limits:\n  upload: 0\n", "RateLimits.Upload:"},
+		{"Zero API key rate", "api_key: k\nrate_limits:\n  api_key: 0\n", "RateLimits.APIKey:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var cfg Config
+			loader := &config.Loader{File: path, Args: []string{}, LookupEnv: func(string) (string, bool) { return "", false }}
+			err := loader.Load(&cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimitsKeyedByKeyID(t *testing.T) {
+	var cfg Config
+	loader := &config.Loader{Args: []string{}, LookupEnv: func(name string) (string, bool) {
+		return "secret-key", name == "API_KEY"
+	}}
+	if err := loader.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := rateLimits(ratelimit.NewLimiter(ratelimit.NewMemoryStore(0)), &cfg, "key-id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := policies.Principals[cfg.APIKey]; ok {
+		t.Error("the plaintext API key is a rate limit key")
+	}
+	if _, ok := policies.Principals["key-id"]; !ok {
+		t.Error("no policy for the API key's ID")
+	}
+	for _, route := range []string{"POST /api/v1/upload", "POST /api/v1/uploads", "PATCH /api/v1/uploads/:id"} {
+		if got := policies.Routes[route].Name; got != "upload" {
+			t.Errorf("%s policy = %q, want upload", route, got)
+		}
+	}
+}
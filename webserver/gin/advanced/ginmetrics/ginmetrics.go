@@ -0,0 +1,21 @@
+// Package ginmetrics records Gin requests in the shared metrics registry
+package ginmetrics
+
+import (
+	"shared/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware records each request's count, latency and in-flight state,
+// labelled by the route template Gin matched, such as /api/v1/products/:id.
+// Add it before the recovery middleware so panics are counted as 500s.
+func Middleware(m *metrics.HTTP) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := m.Begin()
+		defer func() {
+			done(c.Request.Method, c.FullPath(), c.Writer.Status())
+		}()
+		c.Next()
+	}
+}
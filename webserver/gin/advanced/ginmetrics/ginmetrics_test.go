@@ -0,0 +1,40 @@
+package ginmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shared/metrics"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := metrics.NewRegistry()
+	r := gin.New()
+	r.Use(Middleware(metrics.NewHTTP(reg)), gin.Recovery())
+	r.GET("/products/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/metrics", gin.WrapH(reg.Handler()))
+
+	for _, target := range []string{"/products/1", "/products/2", "/panic", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/products/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/products/:id"} 2`,
+		// The scrape itself is in flight while it renders
+		"http_requests_in_flight 1",
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, w.Body)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register adds the job routes under path to rg:
+//
+//	POST   path      submit the task build returns; 202 with the job and a Location to poll
+//	GET    path/:id  the job's status, progress and result
+//	DELETE path/:id  cancel the job
+//
+// build may abort the request itself, for example after a bad body, and
+// return nil.
+func Register(rg gin.IRoutes, path string, e *Executor, build func(c *gin.Context) Task) {
+	rg.POST(path, func(c *gin.Context) {
+		task := build(c)
+		if task == nil {
+			if !c.IsAborted() {
+				abort(c, http.StatusBadRequest, "Invalid job")
+			}
+			return
+		}
+		j, err := e.Submit(task)
+		if err != nil {
+			abortExecutorError(c, err)
+			return
+		}
+		c.Header("Location", c.FullPath()+"/"+j.ID)
+		c.JSON(http.StatusAccepted, j)
+	})
+
+	rg.GET(path+"/:id", func(c *gin.Context) {
+		j, err := e.Get(c.Param("id"))
+		if err != nil {
+			abortExecutorError(c, err)
+			return
+		}
+		if !j.Status.Finished() {
+			c.Header("Retry-After", "1")
+		}
+		c.JSON(http.StatusOK, j)
+	})
+
+	rg.DELETE(path+"/:id", func(c *gin.Context) {
+		j, err := e.Cancel(c.Param("id"))
+		if err != nil {
+			abortExecutorError(c, err)
+			return
+		}
+		// A running job only stops once its task notices the cancellation
+		status := http.StatusOK
+		if j.Status == Running {
+			status = http.StatusAccepted
+		}
+		c.JSON(status, j)
+	})
+}
+
+// abortExecutorError maps executor errors to statuses
+func abortExecutorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		abort(c, http.StatusNotFound, "Job not found")
+	case errors.Is(err, ErrFinished):
+		abort(c, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrClosed):
+		c.Header("Retry-After", "5")
+		abort(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		abort(c, http.StatusInternalServerError, "Failed to run the job")
+	}
+}
+
+// abort ends the request with the API's {code, message} error body
+func abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": status, "message": message})
+}
@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := NewExecutor(1, 1)
+	defer e.Shutdown(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	r := gin.New()
+	Register(r.Group("/api"), "/async", e, func(c *gin.Context) Task {
+		if c.Query("bad") != "" {
+			abort(c, http.StatusBadRequest, "bad job")
+			return nil
+		}
+		return blockingTask(release)
+	})
+	send := func(method, target string) (*httptest.ResponseRecorder, Job) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var j Job
+		json.Unmarshal(w.Body.Bytes(), &j)
+		return w, j
+	}
+
+	w, j := send(http.MethodPost, "/api/async")
+	if w.Code != http.StatusAccepted || j.Status != Pending {
+		t.Fatalf("submit = %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if location != "/api/async/"+j.ID {
+		t.Errorf("Location = %q", location)
+	}
+	waitFor(t, e, j.ID, Running)
+
+	w, polled := send(http.MethodGet, location)
+	if w.Code != http.StatusOK || polled.Status != Running || w.Header().Get("Retry-After") == "" {
+		t.Errorf("poll = %d %s", w.Code, w.Body)
+	}
+
+	// One worker busy and one queued: the next submission is turned away
+	send(http.MethodPost, "/api/async")
+	if w, _ := send(http.MethodPost, "/api/async"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("submit to a full queue = %d, want 503", w.Code)
+	}
+	if w, _ := send(http.MethodPost, "/api/async?bad=1"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad submit = %d, want 400", w.Code)
+	}
+
+	if w, j := send(http.MethodDelete, location); w.Code != http.StatusAccepted || j.Status != Running {
+		t.Errorf("cancel running = %d %s", w.Code, w.Body)
+	}
+	waitFor(t, e, j.ID, Cancelled)
+	if w, _ := send(http.MethodDelete, location); w.Code != http.StatusConflict {
+		t.Errorf("cancel finished = %d, want 409", w.Code)
+	}
+	if w, _ := send(http.MethodGet, "/api/async/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("poll missing = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,295 @@
+// Package jobs runs background tasks on a bounded pool of workers and keeps
+// track of them so clients can poll for progress and results.
+//
+// A job is pending while it waits in the queue, running while a worker
+// executes it, and then succeeded, failed or cancelled. Finished jobs are
+// kept for Executor.Retain so their results can still be fetched.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors returned by Executor
+var (
+	ErrNotFound  = errors.New("jobs: job not found")
+	ErrQueueFull = errors.New("jobs: queue is full")
+	ErrClosed    = errors.New("jobs: executor is shutting down")
+	ErrFinished  = errors.New("jobs: job already finished")
+)
+
+// Status is the state of a job
+type Status string
+
+// Job states. Pending and Running are the only non-terminal ones.
+const (
+	Pending   Status = "pending"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+// Finished reports whether s is a terminal state
+func (s Status) Finished() bool {
+	return s != Pending && s != Running
+}
+
+// Task is the work behind a job. It should return promptly once ctx is
+// cancelled and may call progress with values from 0 to 100. The result
+// must marshal to JSON.
+type Task func(ctx context.Context, progress func(percent int)) (result any, err error)
+
+// Job is a snapshot of a submitted task
+type Job struct {
+	ID         string     `json:"id"`
+	Status     Status     `json:"status"`
+	Progress   int        `json:"progress"` // percent
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// job is the executor's mutable record of a Job. Its fields are guarded by Executor.mu.
+type job struct {
+	Job
+	task   Task
+	cancel context.CancelFunc // set while running
+	// cancelled is set by Cancel so the outcome is reported as Cancelled
+	cancelled bool
+}
+
+// pruneInterval is how often the executor forgets jobs finished more than Retain ago
+const pruneInterval = time.Minute
+
+// Executor runs tasks on a fixed number of workers fed by a bounded queue.
+// It is safe for concurrent use.
+type Executor struct {
+	// Retain is how long finished jobs stay available; zero keeps them for an hour
+	Retain time.Duration
+
+	queue chan *job
+	wg    sync.WaitGroup
+	// base is the parent of every task context; stop cancels it when a
+	// shutdown runs out of time
+	base context.Context
+	stop context.CancelFunc
+	// closing is closed by Shutdown to stop the pruning loop
+	closing chan struct{}
+	now     func() time.Time
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+}
+
+// NewExecutor starts workers goroutines that take tasks from a queue of
+// queueSize. Submit fails with ErrQueueFull once the queue is full.
+func NewExecutor(workers, queueSize int) *Executor {
+	base, stop := context.WithCancel(context.Background())
+	e := &Executor{
+		queue:   make(chan *job, queueSize),
+		base:    base,
+		stop:    stop,
+		closing: make(chan struct{}),
+		now:     time.Now,
+		jobs:    make(map[string]*job),
+	}
+	for i := 0; i < workers; i++ {
+		e.wg.Add(1)
+		go e.work()
+	}
+	go e.pruneLoop()
+	return e
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Submit queues task and returns its pending job
+func (e *Executor) Submit(task Task) (Job, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return Job{}, ErrClosed
+	}
+
+	j := &job{Job: Job{ID: newID(), Status: Pending, CreatedAt: e.now().UTC()}, task: task}
+	select {
+	case e.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	e.jobs[j.ID] = j
+	return j.Job, nil
+}
+
+// Get returns the job with id
+func (e *Executor) Get(id string) (Job, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j, ok := e.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.Job, nil
+}
+
+// Cancel stops the job with id. A pending job is cancelled straight away; a
+// running one has its context cancelled and becomes Cancelled when its task
+// returns. Cancelling a finished job fails with ErrFinished.
+func (e *Executor) Cancel(id string) (Job, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	j, ok := e.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	switch j.Status {
+	case Pending:
+		j.cancelled = true
+		e.finish(j, Cancelled, nil, "cancelled before it started")
+	case Running:
+		j.cancelled = true
+		j.cancel()
+	default:
+		return j.Job, fmt.Errorf("%w: %s", ErrFinished, j.Status)
+	}
+	return j.Job, nil
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones to
+// finish. When ctx is done first, running tasks are cancelled, jobs still in
+// the queue are cancelled without running, and Shutdown returns ctx's error
+// straight away, without waiting for tasks that ignore their cancellation.
+func (e *Executor) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+		close(e.closing)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		e.stop()
+		return ctx.Err()
+	}
+}
+
+// work runs queued jobs until the queue is closed and drained
+func (e *Executor) work() {
+	defer e.wg.Done()
+	for j := range e.queue {
+		e.run(j)
+	}
+}
+
+func (e *Executor) run(j *job) {
+	e.mu.Lock()
+	if j.Status != Pending {
+		// Cancelled while queued
+		e.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(e.base)
+	defer cancel()
+	if ctx.Err() != nil {
+		// The shutdown deadline passed before this job got a worker
+		e.finish(j, Cancelled, nil, "cancelled by shutdown")
+		e.mu.Unlock()
+		return
+	}
+	now := e.now().UTC()
+	j.Status, j.StartedAt, j.cancel = Running, &now, cancel
+	e.mu.Unlock()
+
+	result, err := runTask(ctx, j.task, func(percent int) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if j.Status == Running {
+			j.Progress = min(max(percent, 0), 100)
+		}
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case j.cancelled:
+		e.finish(j, Cancelled, nil, "cancelled")
+	case err != nil && ctx.Err() != nil:
+		e.finish(j, Cancelled, nil, "cancelled by shutdown")
+	case err != nil:
+		e.finish(j, Failed, nil, err.Error())
+	default:
+		j.Progress = 100
+		e.finish(j, Succeeded, result, "")
+	}
+}
+
+// runTask calls task, turning a panic into an error so one bad task cannot
+// take the worker down with it
+func runTask(ctx context.Context, task Task, progress func(int)) (result any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task panicked: %v", p)
+		}
+	}()
+	return task(ctx, progress)
+}
+
+// finish moves j to a terminal state. Callers must hold mu.
+func (e *Executor) finish(j *job, status Status, result any, msg string) {
+	now := e.now().UTC()
+	j.Status, j.Result, j.Error, j.FinishedAt = status, result, msg, &now
+	j.task, j.cancel = nil, nil
+}
+
+// pruneLoop prunes every pruneInterval until Shutdown
+func (e *Executor) pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.mu.Lock()
+			e.prune()
+			e.mu.Unlock()
+		case <-e.closing:
+			return
+		}
+	}
+}
+
+// prune forgets jobs that finished more than Retain ago. Callers must hold mu.
+func (e *Executor) prune() {
+	retain := e.Retain
+	if retain <= 0 {
+		retain = time.Hour
+	}
+	cutoff := e.now().Add(-retain)
+	for id, j := range e.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			delete(e.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls the job until it reaches status
+func waitFor(t *testing.T, e *Executor, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		j, err := e.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status == status {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, j.Status, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingTask reports 50% and waits for release or cancellation
+func blockingTask(release <-chan struct{}) Task {
+	return func(ctx context.Context, progress func(int)) (any, error) {
+		progress(50)
+		select {
+		case <-release:
+			return map[string]string{"answer": "42"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	e := NewExecutor(1, 10)
+	defer e.Shutdown(context.Background())
+
+	release := make(chan struct{})
+	j, err := e.Submit(blockingTask(release))
+	if err != nil || j.Status != Pending || j.ID == "" {
+		t.Fatalf("Submit = %+v, %v", j, err)
+	}
+	j = waitFor(t, e, j.ID, Running)
+	for j.Progress != 50 {
+		j, _ = e.Get(j.ID)
+	}
+	if j.StartedAt == nil {
+		t.Error("running job has no StartedAt")
+	}
+
+	close(release)
+	j = waitFor(t, e, j.ID, Succeeded)
+	if j.Progress != 100 || j.Result.(map[string]string)["answer"] != "42" || j.FinishedAt == nil {
+		t.Errorf("succeeded job = %+v", j)
+	}
+	if _, err := e.Cancel(j.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Cancel(finished) error = %v, want ErrFinished", err)
+	}
+
+	failing, _ := e.Submit(func(context.Context, func(int)) (any, error) { return nil, errors.New("disk full") })
+	if j := waitFor(t, e, failing.ID, Failed); j.Error != "disk full" {
+		t.Errorf("failed job error = %q", j.Error)
+	}
+	panicking, _ := e.Submit(func(context.Context, func(int)) (any, error) { panic("boom") })
+	if j := waitFor(t, e, panicking.ID, Failed); j.Error != "task panicked: boom" {
+		t.Errorf("panicked job error = %q", j.Error)
+	}
+
+	if _, err := e.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	e := NewExecutor(1, 10)
+	defer e.Shutdown(context.Background())
+
+	running, _ := e.Submit(blockingTask(nil))
+	waitFor(t, e, running.ID, Running)
+	queued, _ := e.Submit(blockingTask(nil))
+
+	if j, err := e.Cancel(queued.ID); err != nil || j.Status != Cancelled {
+		t.Errorf("Cancel(pending) = %+v, %v, want cancelled at once", j, err)
+	}
+	if j, err := e.Cancel(running.ID); err != nil || j.Status != Running {
+		t.Errorf("Cancel(running) = %+v, %v, want still running", j, err)
+	}
+	waitFor(t, e, running.ID, Cancelled)
+
+	// The cancelled queued job is skipped, not run
+	if j, _ := e.Get(queued.ID); j.StartedAt != nil {
+		t.Errorf("cancelled queued job was started: %+v", j)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	e := NewExecutor(1, 1)
+	release := make(chan struct{})
+	defer e.Shutdown(context.Background())
+	defer close(release)
+
+	first, _ := e.Submit(blockingTask(release))
+	waitFor(t, e, first.ID, Running)
+	if _, err := e.Submit(blockingTask(release)); err != nil {
+		t.Fatalf("queued Submit: %v", err)
+	}
+	if _, err := e.Submit(blockingTask(release)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit to a full queue error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	e := NewExecutor(1, 10)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		j, _ := e.Submit(func(ctx context.Context, _ func(int)) (any, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		})
+		ids = append(ids, j.ID)
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	for _, id := range ids {
+		if j, _ := e.Get(id); j.Status != Succeeded {
+			t.Errorf("job %s is %s after a drain, want succeeded", id, j.Status)
+		}
+	}
+	if _, err := e.Submit(blockingTask(nil)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Submit after Shutdown error = %v, want ErrClosed", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	e := NewExecutor(1, 10)
+	running, _ := e.Submit(blockingTask(nil))
+	waitFor(t, e, running.ID, Running)
+	queued, _ := e.Submit(blockingTask(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown error = %v, want DeadlineExceeded", err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		if j := waitFor(t, e, id, Cancelled); j.Error != "cancelled by shutdown" {
+			t.Errorf("job after the deadline = %+v, want cancelled by shutdown", j)
+		}
+	}
+}
+
+func TestShutdownDoesNotWaitForStuckTasks(t *testing.T) {
+	e := NewExecutor(1, 10)
+	release := make(chan struct{})
+	defer close(release)
+	stuck, _ := e.Submit(func(context.Context, func(int)) (any, error) {
+		<-release
+		return nil, nil
+	})
+	waitFor(t, e, stuck.ID, Running)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- e.Shutdown(ctx) }()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown error = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown is waiting for a task that ignores its context")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	e := NewExecutor(1, 10)
+	defer e.Shutdown(context.Background())
+	e.Retain = time.Minute
+
+	old, _ := e.Submit(func(context.Context, func(int)) (any, error) { return nil, nil })
+	waitFor(t, e, old.ID, Succeeded)
+
+	kept, _ := e.Submit(func(context.Context, func(int)) (any, error) { return nil, nil })
+	waitFor(t, e, kept.ID, Succeeded)
+
+	// Backdate the first job past Retain
+	e.mu.Lock()
+	e.jobs[old.ID].FinishedAt = new(time.Time)
+	e.prune()
+	e.mu.Unlock()
+	if _, err := e.Get(kept.ID); err != nil {
+		t.Errorf("job finished within Retain was pruned: %v", err)
+	}
+	if _, err := e.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("job finished past Retain is still kept: %v", err)
+	}
+}
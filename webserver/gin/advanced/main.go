@@ -1,211 +1,280 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"failuredetection/phi"
+	"flag"
+	"ginadvanced/auth"
+	"ginadvanced/catalog"
+	"ginadvanced/ginmetrics"
+	"ginadvanced/jobs"
+	"ginadvanced/ratelimit"
+	"ginadvanced/requestlog"
+	"ginadvanced/uploads"
+	"ginadvanced/userapi"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"shared/config"
+	"shared/health"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/users"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
 )
 
-// Product represents a product model with validation
-type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name" binding:"required,min=3"`
-	Price       float64   `json:"price" binding:"required,gt=0"`
-	Description string    `json:"description" binding:"required"`
-	CreatedAt   time.Time `json:"created_at"`
-}
-
 // CustomError represents a custom error response
 type CustomError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
 
-// AuthMiddleware checks for a simple API key
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey != "your-secret-key" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, CustomError{
-				Code:    http.StatusUnauthorized,
-				Message: "Invalid API key",
+// taskRequest is the optional body of POST /async
+type taskRequest struct {
+	Steps int `json:"steps" binding:"omitempty,min=1,max=60"`
+}
+
+// processTask builds the demo job behind POST /async: it works through
+// "steps" one-second steps (5 by default, at most 60), reporting progress
+// as it goes
+func processTask(c *gin.Context) jobs.Task {
+	var req taskRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, CustomError{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
 			})
-			return
+			return nil
 		}
-		c.Next()
 	}
+	if req.Steps == 0 {
+		req.Steps = 5
+	}
+	clientIP := c.ClientIP()
+	logger := requestlog.FromContext(c.Request.Context())
+
+	return func(ctx context.Context, progress func(int)) (any, error) {
+		for i := 1; i <= req.Steps; i++ {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			progress(i * 100 / req.Steps)
+		}
+		logger.Info("Done processing", "client_ip", clientIP, "steps", req.Steps)
+		return gin.H{"client_ip": clientIP, "steps": req.Steps}, nil
+	}
+}
+
+// api holds what the /api/v1 routes are served from
+type api struct {
+	keys     *auth.KeyStore
+	signer   *auth.Signer
+	checks   *health.Registry
+	files    *uploads.Store
+	executor *jobs.Executor
+	products catalog.Repository
+	users    users.Repository
 }
 
-// LoggerMiddleware logs request details
-func LoggerMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Start timer
-		start := time.Now()
-
-		// Process request
-		c.Next()
-
-		// Log request details
-		duration := time.Since(start)
-		log.Printf(
-			"[%s] %s %s %d %v",
-			c.Request.Method,
-			c.Request.URL.Path,
-			c.ClientIP(),
-			c.Writer.Status(),
-			duration,
-		)
+// asyncStarted is the body of GET /async, the route that started a
+// fire-and-forget job before jobs could be polled. It is kept for its old
+// clients; new ones POST to /async.
+type asyncStarted struct {
+	Message string `json:"message"` // "Processing started"
+	ID      string `json:"id"`      // the job to poll at /async/:id
+}
+
+// startJob submits the default demo job and answers in the original GET /async format
+func (a *api) startJob(c *gin.Context) {
+	j, err := a.executor.Submit(processTask(c))
+	if err != nil {
+		c.Header("Retry-After", "5")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, CustomError{
+			Code:    http.StatusServiceUnavailable,
+			Message: err.Error(),
+		})
+		return
 	}
+	c.Header("Location", c.FullPath()+"/"+j.ID)
+	c.JSON(http.StatusOK, asyncStarted{Message: "Processing started", ID: j.ID})
 }
 
-// ErrorHandler middleware handles panics
-func ErrorHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				c.JSON(http.StatusInternalServerError, CustomError{
-					Code:    http.StatusInternalServerError,
-					Message: "Internal server error",
-				})
-			}
-		}()
-		c.Next()
+// healthResponse is the body of GET /api/v1/health, unchanged since the route
+// answered with a fixed "healthy"; /readyz has the per-check report
+type healthResponse struct {
+	Status string    `json:"status"` // "healthy" or "unhealthy"
+	Time   time.Time `json:"time"`
+}
+
+// health reports readiness in the original /api/v1/health format, with 503
+// when a check fails
+func (a *api) health(c *gin.Context) {
+	if report := a.checks.Readiness(c.Request.Context()); report.Status != "ok" {
+		c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "unhealthy", Time: time.Now()})
+		return
 	}
+	c.JSON(http.StatusOK, healthResponse{Status: "healthy", Time: time.Now()})
 }
 
-// RateLimiter implements a simple rate limiting middleware
-func RateLimiter() gin.HandlerFunc {
-	// Simple map to store client request counts
-	// In production, use Redis or similar
-	clients := make(map[string]int64)
-
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		now := time.Now().Unix()
-
-		// Clean old entries (simple cleanup)
-		if now%10 == 0 { // Cleanup every 10 seconds
-			for ip, timestamp := range clients {
-				if now-timestamp > 60 { // Remove after 60 seconds
-					delete(clients, ip)
-				}
-			}
-		}
+// register adds the /api/v1 routes to v1. Document changes in apiSpec.
+func (a *api) register(v1 *gin.RouterGroup) {
+	// Public routes
+	public := v1.Group("")
+	{
+		public.GET("/health", a.health)
+	}
 
-		// Check if client has made a request in the last second
-		if lastRequest, exists := clients[ip]; exists && now-lastRequest < 1 {
-			c.JSON(http.StatusTooManyRequests, CustomError{
-				Code:    http.StatusTooManyRequests,
-				Message: "Rate limit exceeded",
-			})
-			c.Abort()
-			return
-		}
+	// Protected routes accept an API key or a bearer token from /token,
+	// and each route requires its own scope
+	protected := v1.Group("")
+	protected.Use(auth.Authenticate(a.keys, a.signer))
+	scoped := func(scope string) *gin.RouterGroup {
+		return protected.Group("", auth.RequireScope(scope))
+	}
+	{
+		// Exchange an API key for a bearer token
+		scoped("tokens:create").POST("/token", auth.TokenHandler(a.signer))
+
+		// Uploads, chunked uploads and downloads
+		uploads.Register(scoped("files:write"), scoped("files:read"), a.files)
+
+		// Background jobs: POST submits, GET /async/:id polls and DELETE cancels
+		jobs.Register(scoped("jobs:write"), "/async", a.executor, processTask)
+		scoped("jobs:write").GET("/async", a.startJob)
 
-		clients[ip] = now
-		c.Next()
+		// Product catalog
+		catalog.Register(scoped("products:write"), scoped("products:read"), a.products)
+
+		// Users, served by the same handlers as the other servers
+		userapi.Register(scoped("users:write"), scoped("users:read"), a.users)
 	}
 }
 
 func main() {
-	// Create gin router with default middleware
-	r := gin.Default()
-
-	// Add custom middleware
-	r.Use(ErrorHandler())
-	r.Use(LoggerMiddleware())
-	r.Use(RateLimiter())
-
-	// Configure CORS
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "X-API-Key"}
-	r.Use(cors.New(config))
-
-	// API versioning
-	v1 := r.Group("/api/v1")
-	{
-		// Public routes
-		public := v1.Group("")
-		{
-			public.GET("/health", func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{
-					"status": "healthy",
-					"time":   time.Now(),
-				})
-			})
+	// Log JSON lines; the standard log package goes through the same handler
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
+	live, err := config.NewLive[Config](&config.Loader{})
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	cfg := live.Get()
+
+	var products catalog.Repository = catalog.NewMemory()
+	if cfg.ProductsFile != "" {
+		f, err := catalog.OpenFile(cfg.ProductsFile)
+		if err != nil {
+			log.Fatal("Failed to open product catalog: ", err)
 		}
+		products = f
+	}
 
-		// Protected routes
-		protected := v1.Group("")
-		protected.Use(AuthMiddleware())
-		{
-			// File upload
-			protected.POST("/upload", func(c *gin.Context) {
-				file, err := c.FormFile("file")
-				if err != nil {
-					c.JSON(http.StatusBadRequest, CustomError{
-						Code:    http.StatusBadRequest,
-						Message: "No file uploaded",
-					})
-					return
-				}
-
-				// Save file with timestamp
-				filename := time.Now().Format("20060102150405") + "-" + file.Filename
-				if err := c.SaveUploadedFile(file, "uploads/"+filename); err != nil {
-					c.JSON(http.StatusInternalServerError, CustomError{
-						Code:    http.StatusInternalServerError,
-						Message: "Failed to save file",
-					})
-					return
-				}
-
-				c.JSON(http.StatusOK, gin.H{
-					"filename": filename,
-				})
-			})
+	files, err := uploads.NewStore(cfg.UploadsDir, 0)
+	if err != nil {
+		log.Fatal("Failed to open upload store: ", err)
+	}
 
-			// Async handler example
-			protected.GET("/async", func(c *gin.Context) {
-				// Copy context to use in goroutine
-				cCp := c.Copy()
+	executor := jobs.NewExecutor(4, 100)
 
-				// Process async
-				go func() {
-					time.Sleep(5 * time.Second)
-					log.Printf("Done processing for %s", cCp.ClientIP())
-				}()
+	// Every request gets an ID and a logger carrying it. Probes are polled
+	// constantly, so only one in a hundred successful ones is logged.
+	r := gin.New()
+	r.Use(requestlog.RequestID())
+	r.Use(requestlog.Middleware(requestlog.Config{
+		Sample: map[string]int{
+			"GET /healthz":       100,
+			"GET /readyz":        100,
+			"GET /api/v1/health": 100,
+			"GET /metrics":       100,
+		},
+	}))
+	reg := metrics.NewRegistry()
+	r.Use(ginmetrics.Middleware(metrics.NewHTTP(reg)))
+	r.Use(requestlog.Recovery())
 
-				c.JSON(http.StatusOK, gin.H{
-					"message": "Processing started",
-				})
-			})
+	// Configure CORS. Origins are checked against the live config so a
+	// reload takes effect immediately. It runs before the rate limiter, so
+	// 429s carry the CORS headers browsers need to read Retry-After, and
+	// preflights are answered without using up quota.
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		return slices.Contains(live.Get().CORSOrigins, origin)
+	}
+	corsConfig.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "X-API-Key", "Authorization", "Upload-Offset", "Range", requestlog.Header}
+	corsConfig.ExposeHeaders = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+		"Location", "Upload-Offset", "Upload-Length", "Content-Range", "Content-Disposition", requestlog.Header}
+	r.Use(cors.New(corsConfig))
 
-			// Custom validation example
-			protected.POST("/products", func(c *gin.Context) {
-				var product Product
-				if err := c.ShouldBindJSON(&product); err != nil {
-					c.JSON(http.StatusBadRequest, CustomError{
-						Code:    http.StatusBadRequest,
-						Message: err.Error(),
-					})
-					return
-				}
-
-				// Set creation time
-				product.CreatedAt = time.Now()
-
-				// In real app, save to database
-				c.JSON(http.StatusCreated, product)
-			})
-		}
+	// Credentials: API keys are stored hashed, and bearer tokens are signed
+	// with JWT_SECRET (a random secret when unset, so tokens end with the process)
+	keys := auth.NewKeyStore()
+	demoKey := keys.Add(cfg.APIKey, "demo", []string{
+		"tokens:create", "files:read", "files:write", "jobs:write", "products:read", "products:write", "users:read", "users:write",
+	}, 0)
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
 	}
+	signer, err := auth.NewSigner(secret, "gin-advanced", time.Hour)
+	if err != nil {
+		log.Fatal("Invalid JWT_SECRET: ", err)
+	}
+
+	// Rate limits per client IP, a stricter one for uploads and a larger
+	// quota for the API key and tokens issued for it. Swap the memory store
+	// for a shared one to enforce the limits across instances.
+	identify := func(c *gin.Context) (string, bool) {
+		p, err := auth.Identify(keys, signer, c.Request)
+		return p.KeyID, err == nil && p.KeyID != ""
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(0))
+	var limits atomic.Pointer[ratelimit.Config]
+	policies, err := rateLimits(limiter, cfg, demoKey.ID, identify)
+	if err != nil {
+		log.Fatal("Invalid rate limits: ", err)
+	}
+	limits.Store(&policies)
+	r.Use(ratelimit.MiddlewareFunc(func() ratelimit.Config { return *limits.Load() }))
+
+	// Health and readiness probes, also summarised at /api/v1/health. The
+	// process heartbeats into a phi-accrual failure detector, so the probes
+	// report a server that has stalled, e.g. starved of CPU.
+	detector := phi.New(phi.Config{FirstHeartbeatEstimate: 500 * time.Millisecond, AcceptablePause: 500 * time.Millisecond})
+	go phi.Beat(context.Background(), detector, 500*time.Millisecond)
+	checks := health.NewRegistry()
+	checks.Register("heartbeat", health.Heartbeat(detector.LastHeartbeat, 5*time.Second))
+	checks.Register("phi", health.Detector(detector), health.ReadinessOnly())
+	checks.Register("uploads-dir", health.CheckFunc(func(ctx context.Context) error {
+		_, err := os.Stat(cfg.UploadsDir)
+		return err
+	}), health.ReadinessOnly(), health.WithCache(5*time.Second))
+	r.GET("/healthz", gin.WrapH(checks.LivenessHandler()))
+	r.GET("/readyz", gin.WrapH(checks.ReadinessHandler()))
+	r.GET("/metrics", gin.WrapH(reg.Handler()))
+
+	// API versioning, with the OpenAPI document for the /api/v1 routes
+	a := &api{keys: keys, signer: signer, checks: checks, files: files, executor: executor, products: products, users: users.NewMemory()}
+	a.register(r.Group("/api/v1"))
+	r.GET("/openapi.json", gin.WrapH(apiSpec().Handler(func() []openapi.Route {
+		return apiRoutes(r.Routes())
+	})))
 
 	// Custom 404 handler
 	r.NoRoute(func(c *gin.Context) {
@@ -215,8 +284,36 @@ func main() {
 		})
 	})
 
-	// Run server
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Reload CORS origins and rate limits on SIGHUP
+	live.WatchSIGHUP(context.Background(), func(applied, ignored []string, err error) {
+		if err != nil {
+			slog.Error("Config reload failed", "error", err)
+			return
+		}
+		policies, err := rateLimits(limiter, live.Get(), demoKey.ID, identify)
+		if err != nil {
+			slog.Error("Config reload failed; keeping the previous rate limits", "error", err)
+			return
+		}
+		limits.Store(&policies)
+		slog.Info("Config reloaded", "applied", applied, "ignored_until_restart", ignored)
+	})
+
+	// Run server until SIGINT or SIGTERM. The handler timeout is disabled
+	// because it buffers whole responses, which large downloads can't afford;
+	// the read and write timeouts still bound every request.
+	srv := server.DefaultConfig(cfg.Addr)
+	srv.HandlerTimeout = -1
+	srv.ReadTimeout = 5 * time.Minute
+	srv.WriteTimeout = 5 * time.Minute
+	if err := server.ListenAndServe(srv, r); err != nil {
+		log.Print("Server error: ", err)
+	}
+
+	// Let queued and running jobs finish before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := executor.Shutdown(ctx); err != nil {
+		log.Print("Cancelled unfinished jobs: ", err)
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ginadvanced/jobs"
+	"net/http"
+	"net/http/httptest"
+	"shared/health"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthKeepsItsFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   int
+		wantStatus string
+	}{
+		{"Ready", nil, http.StatusOK, "healthy"},
+		{"Check failing", errors.New("down"), http.StatusServiceUnavailable, "unhealthy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := health.NewRegistry()
+			checks.Register("dependency", health.CheckFunc(func(context.Context) error { return tt.err }))
+			r := gin.New()
+			r.GET("/api/v1/health", (&api{checks: checks}).health)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+			var body healthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantCode || body.Status != tt.wantStatus || body.Time.IsZero() {
+				t.Errorf("GET /api/v1/health = %d %s, want %d with status %q and a time", w.Code, w.Body, tt.wantCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGetAsyncStillStartsAJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := &api{executor: jobs.NewExecutor(1, 1)}
+	defer a.executor.Shutdown(context.Background())
+	r := gin.New()
+	r.GET("/api/v1/async", a.startJob)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/async", nil))
+	var body asyncStarted
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || body.Message != "Processing started" {
+		t.Fatalf("GET /api/v1/async = %d %s, want 200 with the original message", w.Code, w.Body)
+	}
+	if _, err := a.executor.Cancel(body.ID); err != nil {
+		t.Errorf("job %q: %v", body.ID, err)
+	}
+	if got, want := w.Header().Get("Location"), "/api/v1/async/"+body.ID; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
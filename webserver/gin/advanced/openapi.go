@@ -0,0 +1,358 @@
+package main
+
+import (
+	"ginadvanced/auth"
+	"ginadvanced/catalog"
+	"ginadvanced/jobs"
+	"ginadvanced/uploads"
+	"net/http"
+	"shared/openapi"
+	"shared/users"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiRoutes converts Gin's routes under /api/v1, the ones apiSpec documents
+func apiRoutes(routes gin.RoutesInfo) []openapi.Route {
+	var api []openapi.Route
+	for _, r := range routes {
+		if strings.HasPrefix(r.Path, "/api/v1/") {
+			api = append(api, openapi.Route{Method: r.Method, Path: r.Path})
+		}
+	}
+	return api
+}
+
+// apiSpec documents the /api/v1 routes served at /openapi.json. Update it,
+// and regenerate testdata/openapi.json with go test -update, when they change.
+func apiSpec() *openapi.Spec {
+	spec := openapi.New("Gin advanced API", "1.0.0")
+	spec.Description = "Protected routes take an X-API-Key header or a bearer token from POST /api/v1/token, " +
+		"and the token or key must grant the scope each operation lists. " +
+		"Every response carries RateLimit-* headers; 429 means the rate limit was exceeded, " +
+		"and 503 that it could not be checked."
+	spec.SecuritySchemes = map[string]openapi.Schema{
+		"apiKey":     {"type": "apiKey", "in": "header", "name": "X-API-Key"},
+		"bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+	}
+	secured := func(op openapi.Operation, scopes ...string) openapi.Operation {
+		op.Security, op.Scopes = []string{"apiKey", "bearerAuth"}, scopes
+		if op.Responses == nil {
+			op.Responses = map[int]openapi.Response{}
+		}
+		op.Responses[http.StatusUnauthorized] = errorResponse("Missing, invalid or expired credentials")
+		if len(scopes) > 0 {
+			op.Responses[http.StatusForbidden] = errorResponse("The credentials lack the scope")
+		}
+		return op
+	}
+
+	spec.Describe(http.MethodGet, "/api/v1/health", openapi.Operation{
+		ID:          "health",
+		Summary:     "Readiness of the server and its dependencies",
+		Description: "GET /readyz?verbose has the result of each check.",
+		Tags:        []string{"health"},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:                 {Description: "Ready", Body: healthResponse{}},
+			http.StatusServiceUnavailable: {Description: "A check failed", Body: healthResponse{}},
+		},
+	})
+	token := secured(openapi.Operation{
+		ID:          "createToken",
+		Summary:     "Exchange an API key for a bearer token",
+		Description: "The optional body narrows the token to a subset of the key's scopes. Bearer tokens can't be exchanged.",
+		Tags:        []string{"auth"},
+		Body:        auth.TokenRequest{},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:         {Body: auth.TokenResponse{}},
+			http.StatusBadRequest: errorResponse("Malformed body"),
+			http.StatusForbidden:  errorResponse("A bearer token, a key without tokens:create, or scopes the key lacks"),
+		},
+	})
+	// Only an API key can be exchanged
+	token.Security, token.Scopes = []string{"apiKey"}, []string{"tokens:create"}
+	spec.Describe(http.MethodPost, "/api/v1/token", token)
+
+	describeUploads(spec, secured)
+	describeJobs(spec, secured)
+	describeCatalog(spec, secured)
+	users.Describe(spec, "/api/v1", func(method string, op openapi.Operation) openapi.Operation {
+		if method == http.MethodGet {
+			return secured(op, "users:read")
+		}
+		return secured(op, "users:write")
+	})
+	return spec
+}
+
+// errorResponse is the API's {code, message} error body
+func errorResponse(desc string) openapi.Response {
+	return openapi.Response{Description: desc, Body: CustomError{}}
+}
+
+func describeUploads(spec *openapi.Spec, secured func(openapi.Operation, ...string) openapi.Operation) {
+	id := openapi.Parameter{Name: "id", In: "path", Description: "Upload or file ID"}
+	progress := map[string]string{
+		"Upload-Offset": "Bytes received so far",
+		"Upload-Length": "Declared total size",
+	}
+	notFound := errorResponse("No such upload")
+	conflict := errorResponse("Offset mismatch, incomplete upload or another chunk in progress")
+
+	spec.Describe(http.MethodPost, "/api/v1/upload", secured(openapi.Operation{
+		ID:      "uploadFile",
+		Summary: "Upload a file in one request",
+		Tags:    []string{"files"},
+		Body: openapi.Schema{
+			"type":     "object",
+			"required": []string{"file"},
+			"properties": map[string]openapi.Schema{
+				"file":   {"type": "string", "contentMediaType": "application/octet-stream"},
+				"sha256": {"type": "string", "description": "Optional hex checksum to verify"},
+			},
+		},
+		BodyType: "multipart/form-data",
+		Responses: map[int]openapi.Response{
+			http.StatusCreated:               {Body: uploads.File{}},
+			http.StatusBadRequest:            errorResponse("No file, or an empty one"),
+			http.StatusRequestEntityTooLarge: errorResponse("The file exceeds the size limit"),
+			http.StatusUnsupportedMediaType:  errorResponse("The file's sniffed type isn't allowed"),
+			http.StatusUnprocessableEntity:   errorResponse("Checksum mismatch"),
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodPost, "/api/v1/uploads", secured(openapi.Operation{
+		ID:      "startUpload",
+		Summary: "Start a chunked upload",
+		Tags:    []string{"files"},
+		Body:    uploads.InitRequest{},
+		Responses: map[int]openapi.Response{
+			http.StatusCreated: {Body: uploads.Upload{}, Headers: map[string]string{
+				"Location": "URL of the upload", "Upload-Offset": progress["Upload-Offset"], "Upload-Length": progress["Upload-Length"],
+			}},
+			http.StatusBadRequest:            errorResponse("Malformed body"),
+			http.StatusRequestEntityTooLarge: errorResponse("The declared size exceeds the limit"),
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodGet, "/api/v1/uploads/:id", secured(openapi.Operation{
+		ID:         "getUpload",
+		Summary:    "Progress of a chunked upload",
+		Tags:       []string{"files"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Body: uploads.Upload{}, Headers: progress},
+			http.StatusNotFound: notFound,
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodHead, "/api/v1/uploads/:id", secured(openapi.Operation{
+		ID:         "headUpload",
+		Summary:    "Progress of a chunked upload, in headers only",
+		Tags:       []string{"files"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Headers: progress},
+			http.StatusNotFound: {Description: "No such upload"},
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodPatch, "/api/v1/uploads/:id", secured(openapi.Operation{
+		ID:      "appendUpload",
+		Summary: "Append a chunk at Upload-Offset",
+		Tags:    []string{"files"},
+		Parameters: []openapi.Parameter{id, {
+			Name: "Upload-Offset", In: "header", Required: true, Schema: int64(0),
+			Description: "Where the chunk starts; must equal the bytes received so far",
+		}},
+		Body:     openapi.Schema{"type": "string", "contentMediaType": "application/octet-stream"},
+		BodyType: "application/octet-stream",
+		Responses: map[int]openapi.Response{
+			http.StatusOK:                    {Body: uploads.Upload{}, Headers: progress},
+			http.StatusBadRequest:            errorResponse("Missing or invalid Upload-Offset"),
+			http.StatusNotFound:              notFound,
+			http.StatusConflict:              conflict,
+			http.StatusRequestEntityTooLarge: errorResponse("The chunk runs past the declared size"),
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodPost, "/api/v1/uploads/:id/complete", secured(openapi.Operation{
+		ID:         "completeUpload",
+		Summary:    "Finish a chunked upload and store the file",
+		Tags:       []string{"files"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusCreated:              {Body: uploads.File{}},
+			http.StatusNotFound:             notFound,
+			http.StatusConflict:             conflict,
+			http.StatusUnsupportedMediaType: errorResponse("The file's sniffed type isn't allowed"),
+			http.StatusUnprocessableEntity:  errorResponse("Checksum mismatch"),
+		},
+	}, "files:write"))
+	spec.Describe(http.MethodDelete, "/api/v1/uploads/:id", secured(openapi.Operation{
+		ID:         "abortUpload",
+		Summary:    "Abandon a chunked upload",
+		Tags:       []string{"files"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusNoContent: {Description: "Abandoned"},
+			http.StatusNotFound:  notFound,
+			http.StatusConflict:  conflict,
+		},
+	}, "files:write"))
+
+	download := map[string]string{
+		"ETag":                "SHA-256 of the file",
+		"Content-Disposition": "attachment with the file name",
+		"Accept-Ranges":       "bytes",
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		op := openapi.Operation{
+			ID:      strings.ToLower(method) + "File",
+			Summary: "Download a file; Range and conditional requests are supported",
+			Tags:    []string{"files"},
+			Parameters: []openapi.Parameter{id,
+				{Name: "Range", In: "header", Description: "Byte range, such as bytes=0-1023"},
+				{Name: "If-None-Match", In: "header", Description: "Answer 304 if the ETag matches"},
+			},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                           {Headers: download},
+				http.StatusPartialContent:               {Headers: download},
+				http.StatusNotModified:                  {Description: "The client's copy is current"},
+				http.StatusNotFound:                     errorResponse("No such file"),
+				http.StatusRequestedRangeNotSatisfiable: {Description: "The range is outside the file"},
+			},
+		}
+		if method == http.MethodGet {
+			ok, partial := op.Responses[http.StatusOK], op.Responses[http.StatusPartialContent]
+			ok.ContentType, partial.ContentType = "application/octet-stream", "application/octet-stream"
+			op.Responses[http.StatusOK], op.Responses[http.StatusPartialContent] = ok, partial
+		}
+		spec.Describe(method, "/api/v1/files/:id", secured(op, "files:read"))
+	}
+}
+
+func describeJobs(spec *openapi.Spec, secured func(openapi.Operation, ...string) openapi.Operation) {
+	id := openapi.Parameter{Name: "id", In: "path", Description: "Job ID"}
+	notFound := errorResponse("No such job")
+
+	spec.Describe(http.MethodPost, "/api/v1/async", secured(openapi.Operation{
+		ID:          "submitJob",
+		Summary:     "Start a background job",
+		Description: "The job works through steps one-second steps. The body is optional.",
+		Tags:        []string{"jobs"},
+		Body:        taskRequest{},
+		Responses: map[int]openapi.Response{
+			http.StatusAccepted:           {Body: jobs.Job{}, Headers: map[string]string{"Location": "URL to poll"}},
+			http.StatusBadRequest:         errorResponse("Malformed body"),
+			http.StatusServiceUnavailable: errorResponse("The queue is full or the server is shutting down"),
+		},
+	}, "jobs:write"))
+	spec.Describe(http.MethodGet, "/api/v1/async", secured(openapi.Operation{
+		ID:          "startJob",
+		Summary:     "Start a five-step background job",
+		Description: "Deprecated: POST /api/v1/async instead. Kept for clients of the original fire-and-forget route.",
+		Tags:        []string{"jobs"},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:                 {Body: asyncStarted{}, Headers: map[string]string{"Location": "URL to poll"}},
+			http.StatusServiceUnavailable: errorResponse("The queue is full or the server is shutting down"),
+		},
+	}, "jobs:write"))
+	spec.Describe(http.MethodGet, "/api/v1/async/:id", secured(openapi.Operation{
+		ID:         "getJob",
+		Summary:    "Poll a job's status, progress and result",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Body: jobs.Job{}, Headers: map[string]string{"Retry-After": "Seconds to wait before polling again, while the job is unfinished"}},
+			http.StatusNotFound: notFound,
+		},
+	}, "jobs:write"))
+	spec.Describe(http.MethodDelete, "/api/v1/async/:id", secured(openapi.Operation{
+		ID:         "cancelJob",
+		Summary:    "Cancel a job",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Description: "Cancelled before it started", Body: jobs.Job{}},
+			http.StatusAccepted: {Description: "Cancellation requested; the running job stops shortly", Body: jobs.Job{}},
+			http.StatusNotFound: notFound,
+			http.StatusConflict: errorResponse("The job already finished"),
+		},
+	}, "jobs:write"))
+}
+
+func describeCatalog(spec *openapi.Spec, secured func(openapi.Operation, ...string) openapi.Operation) {
+	id := openapi.Parameter{Name: "id", In: "path", Description: "Product ID"}
+	notFound := errorResponse("No such product")
+
+	spec.Describe(http.MethodGet, "/api/v1/products", secured(openapi.Operation{
+		ID:      "listProducts",
+		Summary: "Search products a page at a time",
+		Tags:    []string{"products"},
+		Parameters: []openapi.Parameter{
+			{Name: "name", In: "query", Description: "Keep products whose name contains this, ignoring case"},
+			{Name: "min_price", In: "query", Description: "Inclusive lower price bound", Schema: 0.0},
+			{Name: "max_price", In: "query", Description: "Inclusive upper price bound", Schema: 0.0},
+			{Name: "sort", In: "query", Description: "created_at (the default), name or price, prefixed with - for descending",
+				Schema: openapi.Schema{"type": "string", "enum": []string{"created_at", "-created_at", "name", "-name", "price", "-price"}}},
+			{Name: "limit", In: "query", Description: "Page size",
+				Schema: openapi.Schema{"type": "integer", "minimum": 1, "maximum": catalog.MaxLimit, "default": catalog.DefaultLimit}},
+			{Name: "offset", In: "query", Description: "Matching products to skip", Schema: openapi.Schema{"type": "integer", "minimum": 0}},
+			{Name: "include_deleted", In: "query", Description: "Also return soft-deleted products", Schema: false},
+		},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:         {Body: catalog.Page{}},
+			http.StatusBadRequest: errorResponse("Invalid query"),
+		},
+	}, "products:read"))
+	spec.Describe(http.MethodPost, "/api/v1/products", secured(openapi.Operation{
+		ID:      "createProduct",
+		Summary: "Create a product",
+		Tags:    []string{"products"},
+		Body:    catalog.Product{},
+		Responses: map[int]openapi.Response{
+			http.StatusCreated:    {Body: catalog.Product{}, Headers: map[string]string{"Location": "URL of the new product"}},
+			http.StatusBadRequest: errorResponse("Malformed or invalid product"),
+		},
+	}, "products:write"))
+	spec.Describe(http.MethodGet, "/api/v1/products/:id", secured(openapi.Operation{
+		ID:         "getProduct",
+		Summary:    "Get a product",
+		Tags:       []string{"products"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Body: catalog.Product{}},
+			http.StatusNotFound: notFound,
+		},
+	}, "products:read"))
+	spec.Describe(http.MethodPut, "/api/v1/products/:id", secured(openapi.Operation{
+		ID:         "updateProduct",
+		Summary:    "Replace a product",
+		Tags:       []string{"products"},
+		Parameters: []openapi.Parameter{id},
+		Body:       catalog.Product{},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:         {Body: catalog.Product{}},
+			http.StatusBadRequest: errorResponse("Malformed or invalid product"),
+			http.StatusNotFound:   notFound,
+		},
+	}, "products:write"))
+	spec.Describe(http.MethodDelete, "/api/v1/products/:id", secured(openapi.Operation{
+		ID:          "deleteProduct",
+		Summary:     "Soft-delete a product",
+		Description: "The product is hidden until restored.",
+		Tags:        []string{"products"},
+		Parameters:  []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusNoContent: {Description: "Deleted"},
+			http.StatusNotFound:  notFound,
+		},
+	}, "products:write"))
+	spec.Describe(http.MethodPost, "/api/v1/products/:id/restore", secured(openapi.Operation{
+		ID:         "restoreProduct",
+		Summary:    "Restore a soft-deleted product",
+		Tags:       []string{"products"},
+		Parameters: []openapi.Parameter{id},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:       {Body: catalog.Product{}},
+			http.StatusNotFound: notFound,
+		},
+	}, "products:write"))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"ginadvanced/auth"
+	"ginadvanced/catalog"
+	"ginadvanced/jobs"
+	"ginadvanced/uploads"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"shared/health"
+	"shared/openapi"
+	"shared/users"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json from the current routes")
+
+// TestOpenAPIGolden fails when the served spec differs from
+// testdata/openapi.json, so API changes come with a reviewed spec change
+func TestOpenAPIGolden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	files, err := uploads.NewStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := auth.NewSigner(bytes.Repeat([]byte("k"), 32), "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := jobs.NewExecutor(1, 1)
+	t.Cleanup(func() { executor.Shutdown(context.Background()) })
+
+	r := gin.New()
+	a := &api{
+		keys:     auth.NewKeyStore(),
+		signer:   signer,
+		checks:   health.NewRegistry(),
+		files:    files,
+		executor: executor,
+		products: catalog.NewMemory(),
+		users:    users.NewMemory(),
+	}
+	a.register(r.Group("/api/v1"))
+	r.GET("/openapi.json", gin.WrapH(apiSpec().Handler(func() []openapi.Route {
+		return apiRoutes(r.Routes())
+	})))
+
+	if missing := apiSpec().Undocumented(apiRoutes(r.Routes())); len(missing) > 0 {
+		t.Errorf("routes missing from apiSpec: %v", missing)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", w.Code)
+	}
+
+	const golden = "testdata/openapi.json"
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, w.Body.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Errorf("/openapi.json differs from %s; if the change is intended, run go test -run OpenAPI -update and review the diff", golden)
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The built-in algorithms
+var (
+	// TokenBucket refills Rate tokens per Period up to Burst and spends one per request
+	TokenBucket Algorithm = tokenBucket{}
+	// SlidingWindowLog remembers every request of the last Period and allows
+	// Rate of them. It is exact at the cost of storing one timestamp per request.
+	SlidingWindowLog Algorithm = slidingWindowLog{}
+	// GCRA (generic cell rate algorithm) behaves like a token bucket but stores
+	// a single timestamp, the theoretical arrival time of the next request
+	GCRA Algorithm = gcra{}
+)
+
+// decode unmarshals state into v, leaving v untouched for a new or unreadable key
+func decode(state []byte, v any) bool {
+	return state != nil && json.Unmarshal(state, v) == nil
+}
+
+func encode(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+type tokenBucket struct{}
+
+// bucketState measures tokens in time: a full token is one interval of credit,
+// which keeps the arithmetic exact
+type bucketState struct {
+	Credit time.Duration `json:"c"`
+	Last   int64         `json:"l"` // unix nanoseconds of the last refill
+}
+
+func (tokenBucket) Allow(state []byte, limit Limit, now time.Time) (Decision, []byte, time.Duration) {
+	interval := limit.interval()
+	capacity := interval * time.Duration(limit.burst())
+
+	s := bucketState{Credit: capacity, Last: now.UnixNano()}
+	if decode(state, &s) {
+		if elapsed := time.Duration(now.UnixNano() - s.Last); elapsed > 0 {
+			s.Credit = min(capacity, s.Credit+elapsed)
+		}
+		s.Last = now.UnixNano()
+	}
+
+	d := Decision{Limit: limit.burst()}
+	if s.Credit >= interval {
+		d.Allowed = true
+		s.Credit -= interval
+	} else {
+		d.RetryAfter = interval - s.Credit
+	}
+	d.Remaining = int(s.Credit / interval)
+	d.Reset = capacity - s.Credit
+	// Once the bucket is full again the state is no different from a new key
+	return d, encode(s), d.Reset
+}
+
+type slidingWindowLog struct{}
+
+func (slidingWindowLog) Allow(state []byte, limit Limit, now time.Time) (Decision, []byte, time.Duration) {
+	var log []int64 // unix nanoseconds, oldest first
+	decode(state, &log)
+
+	cutoff := now.Add(-limit.Period).UnixNano()
+	kept := log[:0]
+	for _, ts := range log {
+		if ts > cutoff {
+			kept = append(kept, ts)
+		}
+	}
+	log = kept
+
+	d := Decision{Limit: limit.Rate}
+	if len(log) < limit.Rate {
+		d.Allowed = true
+		log = append(log, now.UnixNano())
+	} else {
+		d.RetryAfter = time.Duration(log[0] - cutoff)
+	}
+	d.Remaining = limit.Rate - len(log)
+	d.Reset = time.Duration(log[len(log)-1] - cutoff)
+	return d, encode(log), d.Reset
+}
+
+type gcra struct{}
+
+func (gcra) Allow(state []byte, limit Limit, now time.Time) (Decision, []byte, time.Duration) {
+	interval := limit.interval()
+	tolerance := interval * time.Duration(limit.burst())
+
+	var tatNanos int64
+	tat := now
+	if decode(state, &tatNanos) {
+		if stored := time.Unix(0, tatNanos); stored.After(now) {
+			tat = stored
+		}
+	}
+
+	d := Decision{Limit: limit.burst()}
+	next := tat.Add(interval)
+	if allowAt := next.Add(-tolerance); now.Before(allowAt) {
+		d.RetryAfter = allowAt.Sub(now)
+		d.Reset = tat.Sub(now)
+		return d, encode(tat.UnixNano()), d.Reset
+	}
+
+	d.Allowed = true
+	d.Remaining = int((tolerance - next.Sub(now)) / interval)
+	d.Reset = next.Sub(now)
+	return d, encode(next.UnixNano()), d.Reset
+}
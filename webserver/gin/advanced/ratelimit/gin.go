@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config selects the policy for each request
+type Config struct {
+	Limiter *Limiter
+	// Default applies to requests no other policy matches
+	Default Policy
+	// Routes overrides Default per route, keyed by method and route template
+	// as registered with Gin, e.g. "POST /api/v1/upload"
+	Routes map[string]Policy
+	// Principals gives each listed caller its own policy and quota, shared by
+	// every route, keyed by what Identify returns. Unlisted principals are
+	// limited by client IP like anonymous requests.
+	Principals map[string]Policy
+	// Identify returns a verified identifier for the caller, such as the ID
+	// of the API key it authenticated with, or false for anonymous requests.
+	// It must check credentials, so inventing one doesn't buy a fresh quota.
+	Identify func(c *gin.Context) (string, bool)
+}
+
+// Validate reports the first policy in cfg that can't be enforced
+func (cfg Config) Validate() error {
+	if cfg.Limiter == nil {
+		return fmt.Errorf("ratelimit: no limiter")
+	}
+	if err := cfg.Default.Validate(); err != nil {
+		return err
+	}
+	for _, p := range cfg.Routes {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, p := range cfg.Principals {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Middleware enforces cfg. Every response carries RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers; rejected
+// requests get 429 with Retry-After. If a policy can't be enforced, because it
+// is invalid or the store fails, the request is rejected with 503; check
+// policies with Config.Validate before serving them.
+func Middleware(cfg Config) gin.HandlerFunc {
+	return MiddlewareFunc(func() Config { return cfg })
+}
+
+// MiddlewareFunc is Middleware with the config read by load on every
+// request, so policies can change while the server runs
+func MiddlewareFunc(load func() Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := load()
+		policy, identity := cfg.resolve(c)
+		d, err := cfg.Limiter.Allow(c.Request.Context(), policy, identity)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"code":    http.StatusServiceUnavailable,
+				"message": "Rate limiter unavailable",
+			})
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", seconds(d.Reset))
+		h.Set("RateLimit-Policy", strconv.Itoa(d.Limit)+";w="+seconds(policy.Limit.Period))
+		if !d.Allowed {
+			h.Set("Retry-After", seconds(d.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"code":    http.StatusTooManyRequests,
+				"message": "Rate limit exceeded",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// resolve picks the policy for c and the identity its quota is counted under
+func (cfg Config) resolve(c *gin.Context) (Policy, string) {
+	if cfg.Identify != nil {
+		if id, ok := cfg.Identify(c); ok {
+			if p, ok := cfg.Principals[id]; ok {
+				return p, "principal:" + id
+			}
+		}
+	}
+	identity := "ip:" + c.ClientIP()
+	if p, ok := cfg.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+		return p, identity
+	}
+	return cfg.Default, identity
+}
+
+// seconds renders d as whole seconds, rounded up so clients never retry early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
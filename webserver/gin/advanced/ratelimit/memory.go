@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxKeys bounds a MemoryStore created with a non-positive maxKeys
+const DefaultMaxKeys = 100_000
+
+// MemoryStore is an in-process Store. Keys expire after the TTL their
+// algorithm gives them, and when more than maxKeys are live the least recently
+// used key is evicted, so a flood of distinct clients cannot exhaust memory.
+type MemoryStore struct {
+	maxKeys int
+	now     func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // of *memoryItem, most recently used at the front
+}
+
+type memoryItem struct {
+	key     string
+	state   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a MemoryStore holding at most maxKeys keys
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	return &MemoryStore{
+		maxKeys: maxKeys,
+		now:     time.Now,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Update implements Store
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(state []byte) ([]byte, time.Duration)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	var state []byte
+	el, ok := m.items[key]
+	if ok {
+		item := el.Value.(*memoryItem)
+		if now.Before(item.expires) {
+			state = item.state
+		}
+	}
+
+	next, ttl := fn(state)
+	if ttl <= 0 {
+		if ok {
+			m.remove(el)
+		}
+		return nil
+	}
+
+	if ok {
+		item := el.Value.(*memoryItem)
+		item.state, item.expires = next, now.Add(ttl)
+		m.lru.MoveToFront(el)
+	} else {
+		m.items[key] = m.lru.PushFront(&memoryItem{key: key, state: next, expires: now.Add(ttl)})
+	}
+	m.evict(now)
+	return nil
+}
+
+// evict drops expired keys from the cold end of the list, then the least
+// recently used keys while over capacity. Callers must hold mu.
+func (m *MemoryStore) evict(now time.Time) {
+	for el := m.lru.Back(); el != nil; el = m.lru.Back() {
+		item := el.Value.(*memoryItem)
+		if len(m.items) <= m.maxKeys && now.Before(item.expires) {
+			return
+		}
+		m.remove(el)
+	}
+}
+
+func (m *MemoryStore) remove(el *list.Element) {
+	delete(m.items, el.Value.(*memoryItem).key)
+	m.lru.Remove(el)
+}
+
+// Len returns the number of keys currently held, including expired ones not yet evicted
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
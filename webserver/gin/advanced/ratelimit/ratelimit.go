@@ -0,0 +1,106 @@
+// Package ratelimit limits request rates with a choice of algorithms: token
+// bucket, sliding-window log and GCRA.
+//
+// Algorithms are pure functions from the stored state of a key to a decision
+// and the next state. The Store applies them atomically per key, so a shared
+// backend (Redis with a script, a database row lock, ...) makes the limits hold
+// across several instances. MemoryStore is the single-process backend.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limit allows Rate requests per Period, with bursts of up to Burst requests.
+// Burst defaults to Rate.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// burst returns Burst, or Rate when Burst is unset
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// interval is the time between requests at the sustained rate
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// Decision is the outcome of one rate limit check
+type Decision struct {
+	Allowed    bool
+	Limit      int           // requests allowed in a full burst
+	Remaining  int           // requests left right now
+	Reset      time.Duration // until the quota is fully restored
+	RetryAfter time.Duration // until the next request would be allowed; zero when Allowed
+}
+
+// Algorithm decides a request against the state stored for its key. state is
+// nil for a key seen for the first time. It returns the state to store and
+// how long that state matters; after ttl the key may be forgotten.
+type Algorithm interface {
+	Allow(state []byte, limit Limit, now time.Time) (d Decision, next []byte, ttl time.Duration)
+}
+
+// Policy is a named limit enforced with an algorithm. Keys are namespaced by
+// Name, so different policies never share state.
+type Policy struct {
+	Name      string
+	Algorithm Algorithm
+	Limit     Limit
+}
+
+// Validate reports why p can't be enforced, if it can't
+func (p Policy) Validate() error {
+	switch {
+	case p.Algorithm == nil:
+		return fmt.Errorf("ratelimit: policy %q has no algorithm", p.Name)
+	case p.Limit.Rate <= 0 || p.Limit.Period <= 0:
+		return fmt.Errorf("ratelimit: policy %q has no rate", p.Name)
+	case p.Limit.Burst < 0:
+		return fmt.Errorf("ratelimit: policy %q has a negative burst", p.Name)
+	}
+	return nil
+}
+
+// Store holds limiter state per key
+type Store interface {
+	// Update atomically replaces the state of key with the result of fn. fn
+	// gets nil for a missing or expired key and may be called more than once
+	// by stores that retry on contention.
+	Update(ctx context.Context, key string, fn func(state []byte) (next []byte, ttl time.Duration)) error
+}
+
+// Limiter checks requests against policies using a Store
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a Limiter backed by store
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow counts one request by key against p
+func (l *Limiter) Allow(ctx context.Context, p Policy, key string) (Decision, error) {
+	if err := p.Validate(); err != nil {
+		return Decision{}, err
+	}
+	var d Decision
+	err := l.store.Update(ctx, p.Name+":"+key, func(state []byte) ([]byte, time.Duration) {
+		var next []byte
+		var ttl time.Duration
+		d, next, ttl = p.Algorithm.Allow(state, p.Limit, l.now())
+		return next, ttl
+	})
+	return d, err
+}
@@ -0,0 +1,350 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clock is a manually advanced time source
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Unix(1_700_000_000, 0)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(clk *clock) *Limiter {
+	store := NewMemoryStore(0)
+	store.now = clk.Now
+	l := NewLimiter(store)
+	l.now = clk.Now
+	return l
+}
+
+func TestAlgorithms(t *testing.T) {
+	limit := Limit{Rate: 2, Period: time.Second}
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		// wait after the burst until one more request is allowed
+		wantRetry time.Duration
+	}{
+		{"Token bucket", TokenBucket, 500 * time.Millisecond},
+		{"Sliding window log", SlidingWindowLog, time.Second},
+		{"GCRA", GCRA, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := newClock()
+			l := newTestLimiter(clk)
+			p := Policy{Name: "test", Algorithm: tt.algorithm, Limit: limit}
+			allow := func() Decision {
+				t.Helper()
+				d, err := l.Allow(context.Background(), p, "client")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return d
+			}
+
+			for i, wantRemaining := range []int{1, 0} {
+				if d := allow(); !d.Allowed || d.Remaining != wantRemaining || d.Limit != 2 {
+					t.Errorf("request %d = %+v, want allowed with %d remaining", i+1, d, wantRemaining)
+				}
+			}
+			d := allow()
+			if d.Allowed || d.Remaining != 0 {
+				t.Errorf("request over the burst = %+v, want rejected", d)
+			}
+			if d.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", d.RetryAfter, tt.wantRetry)
+			}
+
+			// Rejected requests don't push the next slot further away
+			clk.Advance(tt.wantRetry - time.Millisecond)
+			if d := allow(); d.Allowed {
+				t.Errorf("allowed %v early", time.Millisecond)
+			}
+			clk.Advance(time.Millisecond)
+			if d := allow(); !d.Allowed {
+				t.Errorf("rejected after RetryAfter: %+v", d)
+			}
+
+			// Another key has its own quota
+			if d, _ := l.Allow(context.Background(), p, "other"); !d.Allowed {
+				t.Errorf("other key rejected: %+v", d)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowLogHasNoBoundaryBurst(t *testing.T) {
+	// A fixed window would allow 2 at the end of one window and 2 more at the
+	// start of the next; the log keeps counting the last full period
+	clk := newClock()
+	l := newTestLimiter(clk)
+	p := Policy{Name: "log", Algorithm: SlidingWindowLog, Limit: Limit{Rate: 2, Period: time.Second}}
+
+	allowed := 0
+	for i := 0; i < 8; i++ {
+		if d, _ := l.Allow(context.Background(), p, "k"); d.Allowed {
+			allowed++
+		}
+		clk.Advance(200 * time.Millisecond)
+	}
+	// 1.6s of traffic: requests at 0 and 0.2s, then at 1.0s and 1.2s
+	if allowed != 4 {
+		t.Errorf("allowed %d of 8 requests spread over 1.6s, want 4", allowed)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	for _, algorithm := range []Algorithm{TokenBucket, SlidingWindowLog, GCRA} {
+		clk := newClock() // frozen, so nothing refills
+		l := newTestLimiter(clk)
+		p := Policy{Name: "c", Algorithm: algorithm, Limit: Limit{Rate: 10, Period: time.Hour}}
+
+		var allowed atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if d, _ := l.Allow(context.Background(), p, "shared"); d.Allowed {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := allowed.Load(); n != 10 {
+			t.Errorf("%T allowed %d concurrent requests, want exactly 10", algorithm, n)
+		}
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	clk := newClock()
+	store := NewMemoryStore(3)
+	store.now = clk.Now
+	set := func(key string, ttl time.Duration) {
+		store.Update(context.Background(), key, func([]byte) ([]byte, time.Duration) { return []byte(key), ttl })
+	}
+	get := func(key string) []byte {
+		var got []byte
+		store.Update(context.Background(), key, func(state []byte) ([]byte, time.Duration) {
+			got = state
+			return state, time.Minute
+		})
+		return got
+	}
+
+	set("a", time.Minute)
+	set("b", time.Minute)
+	set("c", time.Minute)
+	get("a") // a becomes the most recently used
+	set("d", time.Minute)
+	if store.Len() != 3 {
+		t.Errorf("Len() = %d, want the capacity of 3", store.Len())
+	}
+	if get("b") != nil {
+		t.Error("least recently used key b survived")
+	}
+
+	// Expired keys read as new and are evicted
+	set("short", time.Second)
+	clk.Advance(2 * time.Second)
+	if got := get("short"); got != nil {
+		t.Errorf("expired key returned %q", got)
+	}
+	set("gone", 0)
+	if store.Len() > 3 {
+		t.Errorf("Len() = %d after zero-TTL update", store.Len())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := newClock()
+	l := newTestLimiter(clk)
+
+	r := gin.New()
+	r.Use(Middleware(Config{
+		Limiter: l,
+		Default: Policy{Name: "default", Algorithm: TokenBucket, Limit: Limit{Rate: 3, Period: time.Minute}},
+		Routes: map[string]Policy{
+			"POST /upload": {Name: "upload", Algorithm: SlidingWindowLog, Limit: Limit{Rate: 1, Period: time.Minute}},
+		},
+		Principals: map[string]Policy{
+			"partner-id": {Name: "partner", Algorithm: GCRA, Limit: Limit{Rate: 100, Period: time.Second, Burst: 5}},
+		},
+		// Stands in for checking the key against a key store
+		Identify: func(c *gin.Context) (string, bool) {
+			if c.GetHeader("X-API-Key") == "partner-secret" {
+				return "partner-id", true
+			}
+			return "", false
+		},
+	}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/items/:id", ok)
+	r.POST("/upload", ok)
+
+	send := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The route template, not the concrete path, selects the policy
+	for i, target := range []string{"/items/1", "/items/2", "/items/3"} {
+		w := send(http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d = %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != []string{"2", "1", "0"}[i] {
+			t.Errorf("request %d RateLimit-Remaining = %q", i+1, got)
+		}
+	}
+	w := send(http.MethodGet, "/items/4", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("fourth request = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "3;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 3;w=60", got)
+	}
+
+	// The upload route has its own, stricter quota
+	if w := send(http.MethodPost, "/upload", ""); w.Code != http.StatusOK {
+		t.Errorf("first upload = %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/upload", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second upload = %d, want 429", w.Code)
+	}
+
+	// A known API key gets its own quota; an unknown one shares the IP's
+	if w := send(http.MethodGet, "/items/5", "partner-secret"); w.Code != http.StatusOK {
+		t.Errorf("partner key = %d, want 200", w.Code)
+	} else if got := w.Header().Get("RateLimit-Limit"); got != "5" {
+		t.Errorf("partner RateLimit-Limit = %q, want 5", got)
+	}
+	if w := send(http.MethodGet, "/items/5", "made-up"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("unknown key = %d, want 429 from the IP quota", w.Code)
+	}
+}
+
+// failingStore is a Store whose backend is down
+type failingStore struct{}
+
+func (failingStore) Update(context.Context, string, func([]byte) ([]byte, time.Duration)) error {
+	return errors.New("store unavailable")
+}
+
+func TestMiddlewareFailsClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	valid := Policy{Name: "default", Algorithm: TokenBucket, Limit: Limit{Rate: 3, Period: time.Minute}}
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"Store fails", Config{Limiter: NewLimiter(failingStore{}), Default: valid}},
+		{"Invalid policy", Config{Limiter: newTestLimiter(newClock()), Default: Policy{Name: "broken", Algorithm: TokenBucket}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(Middleware(tt.cfg))
+			r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 503", w.Code)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	l := newTestLimiter(newClock())
+	valid := Policy{Name: "default", Algorithm: GCRA, Limit: Limit{Rate: 1, Period: time.Second}}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"Valid", Config{Limiter: l, Default: valid, Routes: map[string]Policy{"POST /upload": valid}}, false},
+		{"No limiter", Config{Default: valid}, true},
+		{"Zero default rate", Config{Limiter: l, Default: Policy{Name: "default", Algorithm: GCRA, Limit: Limit{Period: time.Second}}}, true},
+		{"Route without algorithm", Config{Limiter: l, Default: valid, Routes: map[string]Policy{"POST /upload": {Name: "upload", Limit: valid.Limit}}}, true},
+		{"Negative principal burst", Config{Limiter: l, Default: valid, Principals: map[string]Policy{
+			"id": {Name: "key", Algorithm: GCRA, Limit: Limit{Rate: 1, Period: time.Second, Burst: -1}},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddlewareFuncReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := newTestLimiter(newClock())
+	var rate atomic.Int64
+	rate.Store(1)
+
+	r := gin.New()
+	r.Use(MiddlewareFunc(func() Config {
+		return Config{
+			Limiter: l,
+			Default: Policy{Name: "default", Algorithm: SlidingWindowLog, Limit: Limit{Rate: int(rate.Load()), Period: time.Minute}},
+		}
+	}))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	if send() != http.StatusOK || send() != http.StatusTooManyRequests {
+		t.Fatal("limit of 1 not enforced")
+	}
+	// Raising the limit takes effect on the next request
+	rate.Store(3)
+	if got := send(); got != http.StatusOK {
+		t.Errorf("after raising the limit = %d, want 200", got)
+	}
+}
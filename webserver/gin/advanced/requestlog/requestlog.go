@@ -0,0 +1,180 @@
+// Package requestlog gives every request an ID and a structured logger.
+//
+// RequestID reads or generates the X-Request-ID header, Middleware derives a
+// per-request *slog.Logger carrying that ID and logs one line per request,
+// and Recovery logs panics with their stack trace. Handlers get the logger
+// with FromContext(c.Request.Context()), so their own lines share the ID.
+package requestlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the request ID in requests and responses
+const Header = "X-Request-ID"
+
+type contextKey int
+
+const (
+	idKey contextKey = iota
+	loggerKey
+)
+
+// IDFromContext returns the request ID RequestID stored in ctx, or ""
+func IDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
+
+// FromContext returns the request's logger, or slog.Default() outside a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// validID accepts IDs of up to 128 characters drawn from a conservative set,
+// so a client cannot inject anything odd into logs or headers
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestID keeps a valid incoming X-Request-ID or generates one, echoes it
+// in the response and stores it in the request context
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !validID(id) {
+			id = newID()
+		}
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), idKey, id))
+		c.Next()
+	}
+}
+
+// Config controls Middleware
+type Config struct {
+	// Logger is the parent of every request logger; nil means slog.Default()
+	Logger *slog.Logger
+	// Sample logs only one in every N successful (1xx to 3xx) requests to a
+	// route, keyed by "METHOD /template" such as "GET /healthz". Zero or one
+	// logs them all; errors and slow requests are always logged.
+	Sample map[string]int
+	// Slow requests are always logged, at warning level; zero means a second
+	Slow time.Duration
+}
+
+// Middleware attaches a logger with the request ID, method and route to the
+// request context, then logs the outcome of the request at a level chosen by
+// its status: errors for 5xx, warnings for 4xx and info otherwise.
+func Middleware(cfg Config) gin.HandlerFunc {
+	base := cfg.Logger
+	if base == nil {
+		base = slog.Default()
+	}
+	slow := cfg.Slow
+	if slow <= 0 {
+		slow = time.Second
+	}
+	var counters sync.Map // route -> *atomic.Uint64
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		route := c.Request.Method + " " + c.FullPath()
+		logger := base.With(
+			slog.String("request_id", IDFromContext(c.Request.Context())),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+		)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), loggerKey, logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		duration := time.Since(start)
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400, duration >= slow:
+			level = slog.LevelWarn
+		default:
+			if n := cfg.Sample[route]; n > 1 {
+				v, _ := counters.LoadOrStore(route, new(atomic.Uint64))
+				if v.(*atomic.Uint64).Add(1)%uint64(n) != 1 {
+					return
+				}
+			}
+		}
+
+		attrs := []slog.Attr{
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", duration),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500 response and logs it, with the stack
+// trace, on the request's logger. Use it after Middleware so the request
+// line records the 500.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Deliberate aborts are net/http's business, and not worth a stack trace
+				panic(p)
+			}
+			FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), slog.LevelError, "panic recovered",
+				slog.String("panic", fmt.Sprint(p)),
+				slog.String("stack", string(debug.Stack())),
+			)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"code":       http.StatusInternalServerError,
+				"message":    "Internal server error",
+				"request_id": IDFromContext(c.Request.Context()),
+			})
+		}()
+		c.Next()
+	}
+}
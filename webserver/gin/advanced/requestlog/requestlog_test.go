@@ -0,0 +1,139 @@
+package requestlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns a router logging JSON into buf
+func newTestRouter(buf *bytes.Buffer, sample map[string]int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := gin.New()
+	r.Use(RequestID(), Middleware(Config{Logger: logger, Sample: sample}), Recovery())
+	r.GET("/items/:id", func(c *gin.Context) {
+		FromContext(c.Request.Context()).Info("loading item", "id", c.Param("id"))
+		c.String(http.StatusOK, "item")
+	})
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return r
+}
+
+// entries decodes every JSON log line in buf
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	sc := bufio.NewScanner(buf)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("log line %q: %v", sc.Text(), err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func get(r *gin.Engine, target, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if requestID != "" {
+		req.Header.Set(Header, requestID)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestRouter(&buf, nil)
+
+	if got := get(r, "/items/1", "abc-123").Header().Get(Header); got != "abc-123" {
+		t.Errorf("echoed request ID = %q, want abc-123", got)
+	}
+	for _, bad := range []string{"", "has space", "line\nbreak", strings.Repeat("a", 129)} {
+		got := get(r, "/items/1", bad).Header().Get(Header)
+		if got == bad || !validID(got) {
+			t.Errorf("request ID for %q = %q, want a generated one", bad, got)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestRouter(&buf, nil)
+
+	get(r, "/items/7", "req-1")
+	logs := entries(t, &buf)
+	if len(logs) != 2 {
+		t.Fatalf("got %d log lines, want the handler's and the request's", len(logs))
+	}
+	handler, request := logs[0], logs[1]
+	if handler["msg"] != "loading item" || handler["request_id"] != "req-1" || handler["route"] != "/items/:id" {
+		t.Errorf("handler line = %v, want it to carry the request ID and route", handler)
+	}
+	if request["msg"] != "request" || request["request_id"] != "req-1" || request["status"] != 200.0 ||
+		request["path"] != "/items/7" || request["level"] != "INFO" {
+		t.Errorf("request line = %v", request)
+	}
+
+	get(r, "/missing", "")
+	if e := entries(t, &buf); e[0]["level"] != "WARN" || e[0]["status"] != 404.0 {
+		t.Errorf("404 line = %v, want WARN", e[0])
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestRouter(&buf, nil)
+
+	w := get(r, "/panic", "req-panic")
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"request_id":"req-panic"`) {
+		t.Errorf("panic response = %d %s", w.Code, w.Body)
+	}
+	logs := entries(t, &buf)
+	if len(logs) != 2 {
+		t.Fatalf("got %d log lines, want the panic and the request", len(logs))
+	}
+	panicLine := logs[0]
+	if panicLine["level"] != "ERROR" || panicLine["panic"] != "boom" || panicLine["request_id"] != "req-panic" {
+		t.Errorf("panic line = %v", panicLine)
+	}
+	if stack, _ := panicLine["stack"].(string); !strings.Contains(stack, "requestlog.newTestRouter") {
+		t.Errorf("stack does not reach the panicking handler:\n%s", stack)
+	}
+	if logs[1]["level"] != "ERROR" || logs[1]["status"] != 500.0 {
+		t.Errorf("request line after a panic = %v", logs[1])
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	r := newTestRouter(&buf, map[string]int{"GET /healthz": 5})
+
+	for i := 0; i < 12; i++ {
+		get(r, "/healthz", "")
+	}
+	if n := len(entries(t, &buf)); n != 3 {
+		t.Errorf("logged %d of 12 sampled requests, want 1st, 6th and 11th", n)
+	}
+
+	// Other routes and errors are not sampled
+	for i := 0; i < 3; i++ {
+		get(r, "/missing", "")
+	}
+	if n := len(entries(t, &buf)); n != 3 {
+		t.Errorf("logged %d of 3 unsampled requests", n)
+	}
+}
@@ -0,0 +1,188 @@
+package uploads
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is allowed on top of the store's size limit for the
+// multipart framing and form fields of POST /upload
+const multipartOverhead = 1 << 20
+
+// Register adds the upload routes to write and the download routes to read.
+// Pass groups carrying the access checks each side needs.
+//
+//	POST   write/upload               single request multipart upload ("file", optional "sha256")
+//	POST   write/uploads              open a chunked upload: {"name", "size", "sha256"}
+//	GET    write/uploads/:id          session status; HEAD returns only the headers
+//	PATCH  write/uploads/:id          append the body at the Upload-Offset header
+//	POST   write/uploads/:id/complete verify and publish the file
+//	DELETE write/uploads/:id          abort the session
+//	GET    read/files/:id             download, with Range support
+func Register(write, read gin.IRoutes, s *Store) {
+	h := &handlers{store: s}
+	write.POST("/upload", h.upload)
+	write.POST("/uploads", h.initUpload)
+	write.GET("/uploads/:id", h.uploadStatus)
+	write.HEAD("/uploads/:id", h.uploadStatus)
+	write.PATCH("/uploads/:id", h.appendUpload)
+	write.POST("/uploads/:id/complete", h.completeUpload)
+	write.DELETE("/uploads/:id", h.abortUpload)
+	read.GET("/files/:id", h.download)
+	read.HEAD("/files/:id", h.download)
+}
+
+type handlers struct {
+	store *Store
+}
+
+func (h *handlers) upload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.store.MaxSize()+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortStoreError(c, ErrTooLarge)
+			return
+		}
+		abort(c, http.StatusBadRequest, "No file uploaded")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		abort(c, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+	defer file.Close()
+
+	f, err := h.store.Save(header.Filename, file, c.PostForm("sha256"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, f)
+}
+
+// InitRequest starts a chunked upload
+type InitRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Size   int64  `json:"size" binding:"required,gt=0"` // total size in bytes
+	SHA256 string `json:"sha256"`                       // optional hex checksum, verified on completion
+}
+
+func (h *handlers) initUpload(c *gin.Context) {
+	var req InitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := h.store.Init(req.Name, req.Size, req.SHA256)
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+u.ID)
+	setUploadHeaders(c, u)
+	c.JSON(http.StatusCreated, u)
+}
+
+func (h *handlers) uploadStatus(c *gin.Context) {
+	u, err := h.store.Status(c.Param("id"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	setUploadHeaders(c, u)
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *handlers) appendUpload(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		abort(c, http.StatusBadRequest, "Upload-Offset header must be a non-negative integer")
+		return
+	}
+	u, err := h.store.Append(c.Param("id"), offset, c.Request.Body)
+	if u.ID != "" {
+		// Tell the client where to resume, whether or not the chunk made it
+		setUploadHeaders(c, u)
+	}
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *handlers) completeUpload(c *gin.Context) {
+	f, err := h.store.Complete(c.Param("id"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, f)
+}
+
+func (h *handlers) abortUpload(c *gin.Context) {
+	if err := h.store.Abort(c.Param("id")); err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// download serves a file with http.ServeContent, which answers Range and
+// conditional requests. The ETag is the file's SHA-256.
+func (h *handlers) download(c *gin.Context) {
+	file, meta, err := h.store.Open(c.Param("id"))
+	if err != nil {
+		abortStoreError(c, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", meta.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": meta.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+meta.SHA256+`"`)
+	http.ServeContent(c.Writer, c.Request, meta.Name, meta.CreatedAt, file)
+}
+
+func setUploadHeaders(c *gin.Context, u Upload) {
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.Size, 10))
+	c.Header("Cache-Control", "no-store")
+}
+
+// abortStoreError maps store errors to statuses
+func abortStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		abort(c, http.StatusNotFound, "Upload not found")
+	case errors.Is(err, ErrTooLarge):
+		abort(c, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrUnsupportedType):
+		abort(c, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, ErrChecksumMismatch):
+		abort(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, ErrEmpty):
+		abort(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrOffsetMismatch), errors.Is(err, ErrIncomplete), errors.Is(err, ErrBusy):
+		abort(c, http.StatusConflict, err.Error())
+	default:
+		abort(c, http.StatusInternalServerError, "Failed to store the upload")
+	}
+}
+
+// abort ends the request with the API's {code, message} error body
+func abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": status, "message": message})
+}
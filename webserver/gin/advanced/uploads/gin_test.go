@@ -0,0 +1,188 @@
+package uploads
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api")
+	Register(api, api, newTestStore(t))
+	return r
+}
+
+func serve(r *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func multipartRequest(t *testing.T, name string, data []byte, sum string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", name)
+	part.Write(data)
+	if sum != "" {
+		mw.WriteField("sha256", sum)
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := serve(r, multipartRequest(t, "../../secret.png", pngData, checksum(pngData)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload = %d %s", w.Code, w.Body)
+	}
+	var f File
+	json.Unmarshal(w.Body.Bytes(), &f)
+	if f.Name != "secret.png" || f.SHA256 != checksum(pngData) {
+		t.Errorf("upload = %+v", f)
+	}
+
+	w = serve(r, httptest.NewRequest(http.MethodGet, "/api/files/"+f.ID, nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), pngData) {
+		t.Fatalf("download = %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=secret.png` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files/"+f.ID, nil)
+	req.Header.Set("Range", "bytes=8-15")
+	w = serve(r, req)
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), pngData[8:16]) {
+		t.Errorf("range = %d %q", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 8-15/"+strconv.Itoa(len(pngData)) {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/files/"+f.ID, nil)
+	req.Header.Set("If-None-Match", `"`+f.SHA256+`"`)
+	if w := serve(r, req); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match = %d, want 304", w.Code)
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+	}{
+		{"Wrong type", multipartRequest(t, "a.png", []byte("<html></html>"), ""), http.StatusUnsupportedMediaType},
+		{"Too large", multipartRequest(t, "a.txt", bytes.Repeat([]byte("a"), 4096), ""), http.StatusRequestEntityTooLarge},
+		{"Checksum mismatch", multipartRequest(t, "a.txt", []byte("hello"), checksum([]byte("bye"))), http.StatusUnprocessableEntity},
+		{"No file", httptest.NewRequest(http.MethodPost, "/api/upload", nil), http.StatusBadRequest},
+		{"Unknown file", httptest.NewRequest(http.MethodGet, "/api/files/0123456789abcdef0123456789abcdef", nil), http.StatusNotFound},
+		{"Traversal", httptest.NewRequest(http.MethodGet, "/api/files/..%2Fpartial", nil), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, tt.req); w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
+
+func TestChunkedRoutes(t *testing.T) {
+	r := newTestRouter(t)
+	jsonRequest := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	chunk := func(target string, offset int, data []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, target, bytes.NewReader(data))
+		req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+		return serve(r, req)
+	}
+
+	w := serve(r, jsonRequest(http.MethodPost, "/api/uploads",
+		`{"name":"pic.png","size":`+strconv.Itoa(len(pngData))+`,"sha256":"`+checksum(pngData)+`"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("init = %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if w.Header().Get("Upload-Offset") != "0" || location == "" {
+		t.Errorf("init headers = %v", w.Header())
+	}
+
+	if w := chunk(location, 0, pngData[:500]); w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "500" {
+		t.Fatalf("first chunk = %d %s", w.Code, w.Body)
+	}
+	w = chunk(location, 0, pngData[:500])
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "500" {
+		t.Errorf("stale chunk = %d, Upload-Offset %q, want 409 at 500", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if w := serve(r, httptest.NewRequest(http.MethodPost, location+"/complete", nil)); w.Code != http.StatusConflict {
+		t.Errorf("early complete = %d, want 409", w.Code)
+	}
+
+	// Resume from the offset HEAD reports
+	w = serve(r, httptest.NewRequest(http.MethodHead, location, nil))
+	offset, _ := strconv.Atoi(w.Header().Get("Upload-Offset"))
+	if w.Code != http.StatusOK || offset != 500 || w.Header().Get("Upload-Length") != strconv.Itoa(len(pngData)) {
+		t.Fatalf("HEAD = %d %v", w.Code, w.Header())
+	}
+	if w := chunk(location, offset, pngData[offset:]); w.Code != http.StatusOK {
+		t.Fatalf("last chunk = %d %s", w.Code, w.Body)
+	}
+	w = serve(r, httptest.NewRequest(http.MethodPost, location+"/complete", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("complete = %d %s", w.Code, w.Body)
+	}
+	var f File
+	json.Unmarshal(w.Body.Bytes(), &f)
+
+	w = serve(r, httptest.NewRequest(http.MethodGet, "/api/files/"+f.ID, nil))
+	if body, _ := io.ReadAll(w.Body); !bytes.Equal(body, pngData) {
+		t.Errorf("downloaded %d bytes, want the %d uploaded", len(body), len(pngData))
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		wantCode int
+	}{
+		{"Init without size", jsonRequest(http.MethodPost, "/api/uploads", `{"name":"a.txt"}`), http.StatusBadRequest},
+		{"Init too large", jsonRequest(http.MethodPost, "/api/uploads", `{"name":"a.txt","size":4096}`), http.StatusRequestEntityTooLarge},
+		{"Missing offset", httptest.NewRequest(http.MethodPatch, location, nil), http.StatusBadRequest},
+		{"Status of unknown", httptest.NewRequest(http.MethodGet, "/api/uploads/nope", nil), http.StatusNotFound},
+		{"Abort unknown", httptest.NewRequest(http.MethodDelete, "/api/uploads/nope", nil), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, tt.req); w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+
+	w = serve(r, jsonRequest(http.MethodPost, "/api/uploads", `{"name":"a.txt","size":10}`))
+	aborted := w.Header().Get("Location")
+	if w := serve(r, httptest.NewRequest(http.MethodDelete, aborted, nil)); w.Code != http.StatusNoContent {
+		t.Errorf("abort = %d", w.Code)
+	}
+	if w := serve(r, httptest.NewRequest(http.MethodGet, aborted, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("status after abort = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,440 @@
+// Package uploads stores files uploaded to the Gin advanced API.
+//
+// Files are checked against a size limit and an allow-list of MIME types
+// sniffed from their content, never from the client's Content-Type or file
+// name. They are stored under random IDs; the client's file name is sanitized
+// and kept only as metadata for downloads. Every file's SHA-256 is recorded
+// and, when the client supplies one, verified.
+//
+// Large files can be uploaded in chunks: Init opens a session for a file of a
+// known size, Append writes chunks at the offset the session has reached, and
+// Complete verifies and publishes the file. The offset is the size of the
+// partial file on disk, so an interrupted upload resumes from where it
+// stopped, even across restarts.
+package uploads
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Errors returned by Store. Wrapped errors carry details for the client.
+var (
+	ErrNotFound         = errors.New("uploads: not found")
+	ErrEmpty            = errors.New("uploads: file is empty")
+	ErrTooLarge         = errors.New("uploads: file too large")
+	ErrUnsupportedType  = errors.New("uploads: file type not allowed")
+	ErrChecksumMismatch = errors.New("uploads: checksum mismatch")
+	ErrOffsetMismatch   = errors.New("uploads: offset mismatch")
+	ErrIncomplete       = errors.New("uploads: upload incomplete")
+	ErrBusy             = errors.New("uploads: upload in progress")
+)
+
+// DefaultMaxSize is the size limit used when NewStore is given zero
+const DefaultMaxSize = 32 << 20
+
+// DefaultAllowed are the MIME types accepted when NewStore is given none
+var DefaultAllowed = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+// File describes a stored file
+type File struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"` // sanitized client file name
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Upload is a chunked upload session
+type Upload struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`             // declared total size
+	SHA256 string `json:"sha256,omitempty"` // expected checksum, if the client sent one
+	// Offset is how many bytes have been received
+	Offset    int64     `json:"offset"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps files in a directory. It is safe for concurrent use.
+type Store struct {
+	dir     string
+	maxSize int64
+	allowed []string
+	now     func() time.Time
+
+	mu     sync.Mutex
+	active map[string]bool // upload sessions being appended to or completed
+}
+
+// NewStore creates a Store in dir, accepting files up to maxSize bytes whose
+// sniffed type is one of allowed
+func NewStore(dir string, maxSize int64, allowed ...string) (*Store, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if len(allowed) == 0 {
+		allowed = DefaultAllowed
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "partial"), 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, maxSize: maxSize, allowed: allowed, now: time.Now, active: make(map[string]bool)}, nil
+}
+
+// MaxSize returns the largest file the store accepts
+func (s *Store) MaxSize() int64 {
+	return s.maxSize
+}
+
+// idPattern matches the IDs newID generates. IDs come from URLs, so anything
+// else is rejected before it gets near a file path.
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Store) filePath(id string) string    { return filepath.Join(s.dir, id) }
+func (s *Store) partialPath(id string) string { return filepath.Join(s.dir, "partial", id) }
+func metaPath(dataPath string) string         { return dataPath + ".json" }
+
+// validChecksum reports whether sum is empty or a hex SHA-256
+func validChecksum(sum string) bool {
+	if sum == "" {
+		return true
+	}
+	b, err := hex.DecodeString(sum)
+	return err == nil && len(b) == sha256.Size
+}
+
+func checksumOf(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SanitizeName reduces a client-supplied file name to a safe base name: path
+// components are dropped, anything but letters, digits, '.', '-' and '_'
+// becomes '_', leading dots are removed and the result is at most 100 bytes.
+func SanitizeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	for len(name) > 100 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// sniff returns the media type of a file starting with head, without
+// parameters, or ErrUnsupportedType if the store doesn't accept it
+func (s *Store) sniff(head []byte) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !slices.Contains(s.allowed, mediaType) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, mediaType)
+	}
+	return mediaType, nil
+}
+
+// Save stores the whole file r in one go. If sha256Hex is not empty the
+// content must hash to it.
+func (s *Store) Save(name string, r io.Reader, sha256Hex string) (File, error) {
+	sha256Hex = strings.ToLower(sha256Hex)
+	if !validChecksum(sha256Hex) {
+		return File{}, fmt.Errorf("%w: malformed sha256", ErrChecksumMismatch)
+	}
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return File{}, err
+	}
+	contentType, err := s.sniff(head)
+	if err != nil {
+		return File{}, err
+	}
+
+	id := newID()
+	tmp := s.partialPath(id)
+	out, err := os.Create(tmp)
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(tmp) // fails harmlessly once published
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(br, s.maxSize+1))
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return File{}, err
+	}
+	if n > s.maxSize {
+		return File{}, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, s.maxSize)
+	}
+	if n == 0 {
+		return File{}, ErrEmpty
+	}
+	sum := checksumOf(h)
+	if sha256Hex != "" && sum != sha256Hex {
+		return File{}, fmt.Errorf("%w: got %s", ErrChecksumMismatch, sum)
+	}
+
+	f := File{ID: id, Name: SanitizeName(name), Size: n, ContentType: contentType, SHA256: sum, CreatedAt: s.now().UTC()}
+	if err := s.publish(tmp, f); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// publish moves the data at tmp into place and writes f's metadata
+func (s *Store) publish(tmp string, f File) error {
+	if err := writeJSON(metaPath(s.filePath(f.ID)), f); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filePath(f.ID))
+}
+
+// Stat returns the metadata of the file with id
+func (s *Store) Stat(id string) (File, error) {
+	var f File
+	if !idPattern.MatchString(id) {
+		return f, ErrNotFound
+	}
+	if err := readJSON(metaPath(s.filePath(id)), &f); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// Open returns the file with id and its metadata. The caller closes it.
+func (s *Store) Open(id string) (*os.File, File, error) {
+	meta, err := s.Stat(id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	f, err := os.Open(s.filePath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, File{}, ErrNotFound
+	}
+	return f, meta, err
+}
+
+// Init opens an upload session for a file of size bytes
+func (s *Store) Init(name string, size int64, sha256Hex string) (Upload, error) {
+	sha256Hex = strings.ToLower(sha256Hex)
+	switch {
+	case size <= 0:
+		return Upload{}, ErrEmpty
+	case size > s.maxSize:
+		return Upload{}, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, s.maxSize)
+	case !validChecksum(sha256Hex):
+		return Upload{}, fmt.Errorf("%w: malformed sha256", ErrChecksumMismatch)
+	}
+	u := Upload{ID: newID(), Name: SanitizeName(name), Size: size, SHA256: sha256Hex, CreatedAt: s.now().UTC()}
+	if err := writeJSON(metaPath(s.partialPath(u.ID)), u); err != nil {
+		return Upload{}, err
+	}
+	if err := os.WriteFile(s.partialPath(u.ID), nil, 0o644); err != nil {
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+// Status returns the upload session id with its current offset
+func (s *Store) Status(id string) (Upload, error) {
+	var u Upload
+	if !idPattern.MatchString(id) {
+		return u, ErrNotFound
+	}
+	if err := readJSON(metaPath(s.partialPath(id)), &u); err != nil {
+		return Upload{}, err
+	}
+	info, err := os.Stat(s.partialPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Upload{}, ErrNotFound
+	}
+	if err != nil {
+		return Upload{}, err
+	}
+	u.Offset = info.Size()
+	return u, nil
+}
+
+// acquire marks session id as busy, failing if another request holds it
+func (s *Store) acquire(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[id] {
+		return ErrBusy
+	}
+	s.active[id] = true
+	return nil
+}
+
+func (s *Store) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
+
+// Append writes the chunk r to session id. offset must equal the session's
+// current offset, and the chunk must not take the upload past its declared
+// size. Bytes received before r fails are kept, so the client can resume
+// from the returned session's offset.
+func (s *Store) Append(id string, offset int64, r io.Reader) (Upload, error) {
+	if err := s.acquire(id); err != nil {
+		return Upload{}, err
+	}
+	defer s.release(id)
+
+	u, err := s.Status(id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if offset != u.Offset {
+		return u, fmt.Errorf("%w: upload is at offset %d", ErrOffsetMismatch, u.Offset)
+	}
+
+	out, err := os.OpenFile(s.partialPath(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return u, err
+	}
+	remaining := u.Size - u.Offset
+	n, err := io.Copy(out, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		// Drop the whole chunk rather than keep a prefix the client didn't intend
+		out.Truncate(u.Offset)
+		out.Close()
+		return u, fmt.Errorf("%w: chunk goes past the declared size of %d bytes", ErrTooLarge, u.Size)
+	}
+	if serr := out.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	u.Offset += n
+	return u, err
+}
+
+// Complete verifies session id has received every byte, has an allowed type
+// and matches its checksum, then publishes it as a File. A session that fails
+// verification is discarded.
+func (s *Store) Complete(id string) (File, error) {
+	if err := s.acquire(id); err != nil {
+		return File{}, err
+	}
+	defer s.release(id)
+
+	u, err := s.Status(id)
+	if err != nil {
+		return File{}, err
+	}
+	if u.Offset != u.Size {
+		return File{}, fmt.Errorf("%w: received %d of %d bytes", ErrIncomplete, u.Offset, u.Size)
+	}
+
+	in, err := os.Open(s.partialPath(id))
+	if err != nil {
+		return File{}, err
+	}
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(in, head)
+	h := sha256.New()
+	h.Write(head[:n])
+	_, err = io.Copy(h, in)
+	in.Close()
+	if err != nil {
+		return File{}, err
+	}
+
+	contentType, err := s.sniff(head[:n])
+	if err == nil && u.SHA256 != "" && checksumOf(h) != u.SHA256 {
+		err = fmt.Errorf("%w: got %s", ErrChecksumMismatch, checksumOf(h))
+	}
+	if err != nil {
+		s.discard(id)
+		return File{}, err
+	}
+
+	f := File{ID: id, Name: u.Name, Size: u.Size, ContentType: contentType, SHA256: checksumOf(h), CreatedAt: s.now().UTC()}
+	if err := s.publish(s.partialPath(id), f); err != nil {
+		return File{}, err
+	}
+	os.Remove(metaPath(s.partialPath(id)))
+	return f, nil
+}
+
+// Abort discards upload session id
+func (s *Store) Abort(id string) error {
+	if err := s.acquire(id); err != nil {
+		return err
+	}
+	defer s.release(id)
+	if _, err := s.Status(id); err != nil {
+		return err
+	}
+	s.discard(id)
+	return nil
+}
+
+func (s *Store) discard(id string) {
+	os.Remove(s.partialPath(id))
+	os.Remove(metaPath(s.partialPath(id)))
+}
+
+func writeJSON(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+func readJSON(name string, v any) error {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,169 @@
+package uploads
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngData is a PNG signature followed by filler, enough for sniffing
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 1000)...)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewStore(t.TempDir(), 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"photo.png", "photo.png"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\windows\win.ini`, "win.ini"},
+		{"my holiday (1).jpg", "my_holiday__1_.jpg"},
+		{".htaccess", "htaccess"},
+		{"..", "file"},
+		{"", "file"},
+		{"résumé.pdf", "résumé.pdf"},
+		{strings.Repeat("é", 60), strings.Repeat("é", 50)},
+	}
+	for _, tt := range tests {
+		if got := SanitizeName(tt.in); got != tt.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	s := newTestStore(t)
+
+	f, err := s.Save("../pic.png", bytes.NewReader(pngData), strings.ToUpper(checksum(pngData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "pic.png" || f.ContentType != "image/png" || f.Size != int64(len(pngData)) || f.SHA256 != checksum(pngData) {
+		t.Errorf("Save = %+v", f)
+	}
+	file, meta, err := s.Open(f.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if !bytes.Equal(data, pngData) || meta != f {
+		t.Errorf("Open returned %d bytes and %+v", len(data), meta)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		sum     string
+		wantErr error
+	}{
+		{"HTML", []byte("<html><script>alert(1)</script></html>"), "", ErrUnsupportedType},
+		{"Too large", bytes.Repeat([]byte("a"), 2049), "", ErrTooLarge},
+		{"Wrong checksum", []byte("hello"), checksum([]byte("bye")), ErrChecksumMismatch},
+		{"Malformed checksum", []byte("hello"), "abc", ErrChecksumMismatch},
+		{"Empty", nil, "", ErrEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Save("x.txt", bytes.NewReader(tt.data), tt.sum); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Save error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Rejected uploads leave nothing behind
+	entries, _ := os.ReadDir(filepath.Join(s.dir, "partial"))
+	if len(entries) != 0 {
+		t.Errorf("partial directory holds %d entries", len(entries))
+	}
+	for _, id := range []string{"../partial", f.ID[:10], strings.ToUpper(f.ID)} {
+		if _, err := s.Stat(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(%q) error = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestChunkedUpload(t *testing.T) {
+	s := newTestStore(t)
+
+	u, err := s.Init("pic.png", int64(len(pngData)), checksum(pngData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err = s.Append(u.ID, 0, bytes.NewReader(pngData[:300])); err != nil || u.Offset != 300 {
+		t.Fatalf("first chunk = %+v, %v", u, err)
+	}
+
+	// A retried chunk at a stale offset is refused and reports where to resume
+	if u, err := s.Append(u.ID, 0, bytes.NewReader(pngData[:300])); !errors.Is(err, ErrOffsetMismatch) || u.Offset != 300 {
+		t.Errorf("stale chunk = %+v, %v, want ErrOffsetMismatch at 300", u, err)
+	}
+	if _, err := s.Complete(u.ID); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("early Complete error = %v, want ErrIncomplete", err)
+	}
+	// A chunk that overshoots the declared size is dropped whole
+	if _, err := s.Append(u.ID, 300, bytes.NewReader(append(pngData[300:], 'x'))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("overlong chunk error = %v, want ErrTooLarge", err)
+	}
+
+	// Resume from the offset the store reports, as a client would after a restart
+	reopened, err := NewStore(s.dir, s.maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := reopened.Status(u.ID)
+	if err != nil || status.Offset != 300 {
+		t.Fatalf("Status after reopening = %+v, %v", status, err)
+	}
+	if _, err := reopened.Append(u.ID, status.Offset, bytes.NewReader(pngData[300:])); err != nil {
+		t.Fatal(err)
+	}
+	f, err := reopened.Complete(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != u.ID || f.ContentType != "image/png" || f.SHA256 != checksum(pngData) {
+		t.Errorf("Complete = %+v", f)
+	}
+	if _, err := reopened.Status(u.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Status after Complete error = %v, want ErrNotFound", err)
+	}
+
+	// Verification failures discard the session
+	bad, _ := s.Init("evil.png", 6, "")
+	s.Append(bad.ID, 0, strings.NewReader("<html>"))
+	if _, err := s.Complete(bad.ID); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Complete(html) error = %v, want ErrUnsupportedType", err)
+	}
+	if _, err := s.Status(bad.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rejected session survived: %v", err)
+	}
+
+	if _, err := s.Init("big.bin", 4096, ""); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Init over the limit error = %v, want ErrTooLarge", err)
+	}
+	aborted, _ := s.Init("a.txt", 10, "")
+	if err := s.Abort(aborted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Append(aborted.ID, 0, strings.NewReader("x")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Append after Abort error = %v, want ErrNotFound", err)
+	}
+}
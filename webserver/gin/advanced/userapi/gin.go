@@ -0,0 +1,26 @@
+// Package userapi serves the shared users API from Gin, so the Gin advanced
+// API answers exactly as the other servers do.
+package userapi
+
+import (
+	"net/http"
+	"shared/users"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register adds the /users routes of users.Endpoints that change users to
+// write and the ones that read them to read, so each group can carry its own
+// scope check.
+func Register(write, read gin.IRoutes, repo users.Repository) {
+	for _, e := range users.Endpoints(repo) {
+		rg := write
+		if e.Method == http.MethodGet {
+			rg = read
+		}
+		rg.Handle(e.Method, strings.Replace(e.Path, "{id}", ":id", 1), func(c *gin.Context) {
+			e.Serve(c.Writer, c.Request, c.Param("id"))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package userapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shared/users"
+	"shared/users/userstest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUsersAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userstest.Run(t, func(repo users.Repository) http.Handler {
+		r := gin.New()
+		Register(r, r, repo)
+		return r
+	})
+}
+
+func TestWriteMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	readOnly := func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+	api := r.Group("/api")
+	Register(api.Group("", readOnly), api, users.NewMemory())
+
+	tests := []struct {
+		method   string
+		target   string
+		wantCode int
+	}{
+		{http.MethodGet, "/api/users", http.StatusOK},
+		{http.MethodGet, "/api/users/1", http.StatusNotFound},
+		{http.MethodPost, "/api/users", http.StatusForbidden},
+		{http.MethodPut, "/api/users/1", http.StatusForbidden},
+		{http.MethodPatch, "/api/users/1", http.StatusForbidden},
+		{http.MethodDelete, "/api/users/1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.wantCode)
+		}
+	}
+}
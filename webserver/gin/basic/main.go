@@ -1,21 +1,25 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"shared/server"
+	"shared/users"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// User represents the user model
-type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name" binding:"required"`
-	Email string `json:"email" binding:"required,email"`
+// registerUsers serves the shared users API from repo under rg, passing
+// Gin's :id parameter to the shared handlers
+func registerUsers(rg gin.IRoutes, repo users.Repository) {
+	for _, e := range users.Endpoints(repo) {
+		rg.Handle(e.Method, strings.Replace(e.Path, "{id}", ":id", 1), func(c *gin.Context) {
+			e.Serve(c.Writer, c.Request, c.Param("id"))
+		})
+	}
 }
 
-// users is our mock database
-var users = make(map[string]User)
-
 func main() {
 	// Create default gin router
 	r := gin.Default()
@@ -27,76 +31,8 @@ func main() {
 		})
 	})
 
-	// Group user routes
-	userRoutes := r.Group("/users")
-	{
-		// Get all users
-		userRoutes.GET("", func(c *gin.Context) {
-			// Convert map to slice for response
-			userList := make([]User, 0, len(users))
-			for _, user := range users {
-				userList = append(userList, user)
-			}
-			c.JSON(http.StatusOK, userList)
-		})
-
-		// Get user by ID
-		userRoutes.GET("/:id", func(c *gin.Context) {
-			id := c.Param("id")
-			if user, exists := users[id]; exists {
-				c.JSON(http.StatusOK, user)
-				return
-			}
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		})
-
-		// Create new user
-		userRoutes.POST("", func(c *gin.Context) {
-			var newUser User
-			if err := c.ShouldBindJSON(&newUser); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-
-			// Generate simple ID (in practice, use UUID)
-			newUser.ID = string(len(users) + 1)
-			users[newUser.ID] = newUser
-
-			c.JSON(http.StatusCreated, newUser)
-		})
-
-		// Update user
-		userRoutes.PUT("/:id", func(c *gin.Context) {
-			id := c.Param("id")
-			if _, exists := users[id]; !exists {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-				return
-			}
-
-			var updatedUser User
-			if err := c.ShouldBindJSON(&updatedUser); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-
-			updatedUser.ID = id
-			users[id] = updatedUser
-
-			c.JSON(http.StatusOK, updatedUser)
-		})
-
-		// Delete user
-		userRoutes.DELETE("/:id", func(c *gin.Context) {
-			id := c.Param("id")
-			if _, exists := users[id]; !exists {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-				return
-			}
-
-			delete(users, id)
-			c.Status(http.StatusNoContent)
-		})
-	}
+	// User routes, answered by the same handlers as the other servers
+	registerUsers(r, users.NewMemory())
 
 	// Query parameter example
 	r.GET("/search", func(c *gin.Context) {
@@ -117,6 +53,8 @@ func main() {
 		})
 	})
 
-	// Run the server
-	r.Run(":8080")
+	// Run the server with timeouts; SIGINT or SIGTERM drains in-flight requests
+	if err := server.ListenAndServe(server.DefaultConfig(":8080"), r); err != nil {
+		fmt.Println("Server error:", err)
+	}
 }
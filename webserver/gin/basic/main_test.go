@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+	"shared/users"
+	"shared/users/userstest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUsersAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userstest.Run(t, func(repo users.Repository) http.Handler {
+		r := gin.New()
+		registerUsers(r, repo)
+		return r
+	})
+}
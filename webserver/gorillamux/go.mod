@@ -2,4 +2,11 @@ module gorillamux
 
 go 1.23.2
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	shared v0.0.0
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace shared => ../shared
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"shared/config"
+	"shared/metrics"
+	"shared/server"
+	"shared/users"
 
 	"github.com/gorilla/mux"
 )
@@ -11,16 +18,56 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Main page")
 }
 
-func userHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fmt.Fprintf(w, "User id is: %s", vars["id"])
+// registerUsers serves the users API from repo, passing gorilla's {id}
+// variable to the shared handlers
+func registerUsers(r *mux.Router, repo users.Repository) {
+	for _, e := range users.Endpoints(repo) {
+		r.HandleFunc(e.Path, func(w http.ResponseWriter, req *http.Request) {
+			e.Serve(w, req, mux.Vars(req)["id"])
+		}).Methods(e.Method)
+	}
+}
+
+// routeTemplate returns the path template of the route req matches, or ""
+// when none does
+func routeTemplate(r *mux.Router, req *http.Request) string {
+	var match mux.RouteMatch
+	if !r.Match(req, &match) || match.Route == nil {
+		return ""
+	}
+	tpl, _ := match.Route.GetPathTemplate()
+	return tpl
+}
+
+// Config is read from -config, the environment and flags
+type Config struct {
+	Addr string `yaml:"addr" default:":8000" env:"ADDR" flag:"addr" usage:"listen address"`
 }
 
 func main() {
+	var cfg Config
+	if err := (&config.Loader{}).Load(&cfg); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+
 	r := mux.NewRouter()
 	r.HandleFunc("/", homeHandler)
-	r.HandleFunc("/user/{id}", userHandler)
+	registerUsers(r, users.NewMemory())
+
+	// Request metrics labelled by route template, served at /metrics
+	reg := metrics.NewRegistry()
+	r.Handle("/metrics", reg.Handler()).Methods(http.MethodGet)
+	handler := metrics.NewHTTP(reg).Middleware(func(req *http.Request) string {
+		return routeTemplate(r, req)
+	})(r)
+
 	// Remove this line: http.Handle("/", r)
-	fmt.Println("Server is running on http://localhost:8000")
-	http.ListenAndServe(":8000", r)
+	fmt.Println("Server is running on", cfg.Addr)
+	if err := server.ListenAndServe(server.DefaultConfig(cfg.Addr), handler); err != nil {
+		fmt.Println("Server error:", err)
+	}
 }
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+	"shared/users"
+	"shared/users/userstest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUsersAPI(t *testing.T) {
+	userstest.Run(t, func(repo users.Repository) http.Handler {
+		r := mux.NewRouter()
+		registerUsers(r, repo)
+		return r
+	})
+}
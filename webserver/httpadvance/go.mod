@@ -1,3 +1,16 @@
 module httpserver
 
 go 1.23.2
+
+require (
+	failuredetection v0.0.0
+	shared v0.0.0
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace (
+	failuredetection => "../../go practice/failuredetection"
+	lifecycle => ../../concurrencypatterns/lifecycle
+	shared => ../shared
+)
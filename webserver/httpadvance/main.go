@@ -1,117 +1,105 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"failuredetection/phi"
+	"flag"
 	"fmt"
 	"net/http"
-	"strconv"
-	"sync"
+	"os"
+	"shared/config"
+	"shared/health"
+	"shared/metrics"
+	"shared/openapi"
+	"shared/server"
+	"shared/users"
+	"time"
 )
 
-// User struct represents the structure of a user object
-type User struct {
-	User string `json:"user"`
-}
-
-// mutex for safe concurrent access to the userCache
-var mutex sync.RWMutex
-
-// userCache stores users with their IDs as keys
-var userCache = make(map[int]User)
-
 // mainHandler responds with a simple "Hello" message
 func mainHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello")
 }
 
-// createUserHandler creates a new user and adds it to the userCache
-func createUserHandler(w http.ResponseWriter, r *http.Request) {
-	var user User
-	// Decode the JSON request body into a User struct
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		fmt.Println("Error here:", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return // Added return statement to exit the function on error
-	}
-	
-	// Lock the mutex before modifying the userCache
-	mutex.Lock()
-	defer mutex.Unlock()
-	
-	// Add the new user to the cache with an auto-incremented ID
-	userCache[len(userCache)+1] = user
-	fmt.Println(userCache)
-	w.WriteHeader(http.StatusAccepted)
-}
+// newMux registers every route, with the users API backed by repo and the
+// probes reporting checks plus a check of the store
+func newMux(repo users.Repository, checks *health.Registry) *openapi.Mux {
+	// Create a new ServeMux for routing; it records the routes for the spec
+	mux := openapi.NewMux()
 
-// getUserHandler retrieves a user by ID from the userCache
-func getUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract the ID from the URL path
-	id := r.PathValue("id")
-	intId, err := strconv.Atoi(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	fmt.Println(intId)
-	
-	// Lock the mutex for reading from the userCache
-	mutex.RLock()
-	defer mutex.RUnlock()
-	
-	// Retrieve the user from the cache
-	user, ok := userCache[intId]
-	if !ok {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
+	// Register handlers for different routes
+	mux.HandleFunc("/", mainHandler)
+	for _, e := range users.Endpoints(repo) {
+		mux.HandleFunc(e.Method+" "+e.Path, func(w http.ResponseWriter, r *http.Request) {
+			e.Serve(w, r, r.PathValue("id"))
+		})
 	}
-	// Encode and send the user as JSON response
-	json.NewEncoder(w).Encode(user)
-	// using json marshal
-	// userJson, err := json.Marshal(user)
-	// if err != nil {
-	// 	http.Error(w, err.Error(), http.StatusInternalServerError)
-	// 	return
-	// }
-	// w.Write(userJson)
 
+	// Health and readiness probes at /healthz and /readyz
+	checks.Register("user-store", health.CheckFunc(func(ctx context.Context) error {
+		// A lookup that doesn't return within the check timeout means the store is wedged
+		if _, err := repo.Get(0); err != nil && !errors.Is(err, users.ErrNotFound) {
+			return err
+		}
+		return nil
+	}), health.WithTimeout(time.Second))
+	checks.Mount(mux.ServeMux)
+
+	// OpenAPI document for the /users routes
+	mux.Handle("GET /openapi.json", apiSpec().Handler(func() []openapi.Route {
+		return apiRoutes(mux.Routes())
+	}))
+	return mux
 }
 
-// deleteUserHandler removes a user from the userCache by ID
-func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract the ID from the URL path
-	id := r.PathValue("id")
-	intId, err := strconv.Atoi(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	
-	// Lock the mutex before modifying the userCache
-	mutex.Lock()
-	defer mutex.Unlock()
-	
-	// Check if the user exists before deleting
-	if _, ok := userCache[intId]; !ok {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-	delete(userCache, intId)
-	w.WriteHeader(http.StatusAccepted)
+// Config is read from -config, the environment and flags
+type Config struct {
+	Addr  string `yaml:"addr" default:":8080" env:"ADDR" flag:"addr" usage:"listen address"`
+	Store string `yaml:"store" env:"STORE" flag:"store" usage:"persist users to this log file; empty keeps them in memory"`
 }
 
 func main() {
-	// Create a new ServeMux for routing
-	mux := http.NewServeMux()
-	
-	// Register handlers for different routes
-	mux.HandleFunc("/", mainHandler)
-	mux.HandleFunc("POST /users", createUserHandler)
-	mux.HandleFunc("GET /users/{id}", getUserHandler)
-	mux.HandleFunc("DELETE /users/{id}", deleteUserHandler)
-	
-	fmt.Println("Listening to port: 8080")
-	// Start the HTTP server
-	http.ListenAndServe(":8080", mux)
+	var cfg Config
+	if err := (&config.Loader{}).Load(&cfg); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+
+	var repo users.Repository = users.NewMemory()
+	if cfg.Store != "" {
+		store, err := users.OpenFile(cfg.Store)
+		if err != nil {
+			fmt.Println("Error opening user store:", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		repo = store
+	}
+
+	// Request metrics for every route, served in Prometheus format at /metrics
+	reg := metrics.NewRegistry()
+	// The process heartbeats into a phi-accrual failure detector, so the
+	// probes report a server that has stalled, e.g. starved of CPU
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	detector := phi.New(phi.Config{FirstHeartbeatEstimate: 500 * time.Millisecond, AcceptablePause: 500 * time.Millisecond})
+	go phi.Beat(ctx, detector, 500*time.Millisecond)
+	checks := health.NewRegistry()
+	checks.Register("heartbeat", health.Heartbeat(detector.LastHeartbeat, 5*time.Second))
+	checks.Register("phi", health.Detector(detector), health.ReadinessOnly())
+
+	mux := newMux(repo, checks)
+	mux.Handle("GET /metrics", reg.Handler())
+	handler := metrics.NewHTTP(reg).Middleware(nil)(mux)
+
+	fmt.Println("Listening on", cfg.Addr)
+	// Start the HTTP server; on SIGINT or SIGTERM it drains in-flight requests
+	// and returns, so the deferred Close compacts the store
+	if err := server.ListenAndServe(server.DefaultConfig(cfg.Addr), handler); err != nil {
+		fmt.Println("Server error:", err)
+	}
 }
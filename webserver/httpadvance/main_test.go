@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/http"
+	"shared/health"
+	"shared/users"
+	"shared/users/userstest"
+	"testing"
+)
+
+func TestUsersAPI(t *testing.T) {
+	userstest.Run(t, func(repo users.Repository) http.Handler {
+		return newMux(repo, health.NewRegistry())
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"shared/openapi"
+	"shared/users"
+	"strings"
+)
+
+// apiRoutes keeps the routes clients use, leaving out probes, metrics and
+// the spec itself
+func apiRoutes(routes []openapi.Route) []openapi.Route {
+	var api []openapi.Route
+	for _, r := range routes {
+		if r.Path == "/users" || strings.HasPrefix(r.Path, "/users/") {
+			api = append(api, r)
+		}
+	}
+	return api
+}
+
+// apiSpec documents the /users routes served at /openapi.json. Update it,
+// and regenerate testdata/openapi.json with go test -update, when they change.
+func apiSpec() *openapi.Spec {
+	spec := openapi.New("Users API", "1.0.0")
+	spec.Description = "Users with optimistic concurrency: every user has an ETag, and writes honour If-Match."
+
+	users.Describe(spec, "", nil)
+	return spec
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"shared/health"
+	"shared/users"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json from the current routes")
+
+// TestOpenAPIGolden fails when the served spec differs from
+// testdata/openapi.json, so API changes come with a reviewed spec change
+func TestOpenAPIGolden(t *testing.T) {
+	mux := newMux(users.NewMemory(), health.NewRegistry())
+	if missing := apiSpec().Undocumented(apiRoutes(mux.Routes())); len(missing) > 0 {
+		t.Errorf("routes missing from apiSpec: %v", missing)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", w.Code)
+	}
+	const golden = "testdata/openapi.json"
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, w.Body.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Errorf("/openapi.json differs from %s; if the change is intended, run go test -run OpenAPI -update and review the diff", golden)
+	}
+}
@@ -0,0 +1,272 @@
+// Package config loads a configuration struct from, in increasing order of
+// precedence, defaults, a YAML or JSON file, environment variables and
+// command-line flags. Each field says where it may come from with struct tags:
+//
+//	default:":8080"      value used when no source sets the field
+//	yaml:"addr"          key in the config file
+//	env:"ADDR"           environment variable
+//	flag:"addr"          command-line flag, described by usage:"..."
+//	validate:"required"  rules checked with shared/validate once everything is loaded
+//	reload:"true"        the field may change when a Live config is reloaded
+//
+// Defaults, variables and flags are parsed from text. Strings, booleans,
+// integers, floats, time.Duration values such as "1m30s" and comma-separated
+// []string values are supported. Nested structs are walked; their tags work
+// the same way and their file keys nest.
+//
+// The file is named by Loader.File or the -config flag. ".json" files are read
+// with the YAML decoder, which accepts JSON, so both formats use the yaml tags
+// and both spell durations as strings. Unknown keys are an error, to catch typos.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"shared/validate"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Loader reads configuration. The zero value reads os.Args and the process
+// environment and no file unless -config names one.
+type Loader struct {
+	// File is the config file, .yaml, .yml or .json; the -config flag overrides it
+	File string
+	// Args are the command-line arguments without the program name; nil means os.Args[1:]
+	Args []string
+	// LookupEnv reads environment variables; nil means os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+	// Output receives flag usage and errors; nil means os.Stderr
+	Output io.Writer
+}
+
+// field is a leaf of the configuration struct
+type field struct {
+	path   string // file keys joined with dots, e.g. "rate_limits.default.rate"
+	value  reflect.Value
+	tag    reflect.StructTag
+	reload bool // tagged reload:"true", itself or through a parent struct
+}
+
+// fields lists the settable leaves of v, a struct value
+func fields(v reflect.Value, prefix string, reload bool) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		path := prefix + name
+		r := reload || sf.Tag.Get("reload") == "true"
+		if sf.Type.Kind() == reflect.Struct {
+			out = append(out, fields(v.Field(i), path+".", r)...)
+			continue
+		}
+		out = append(out, field{path: path, value: v.Field(i), tag: sf.Tag, reload: r})
+	}
+	return out
+}
+
+// structValue returns the struct dst points to
+func structValue(dst any) (reflect.Value, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("config: %T is not a pointer to a struct", dst)
+	}
+	return v.Elem(), nil
+}
+
+// flagValue records what a flag was set to without interpreting it, so
+// flags can be applied after the file and environment
+type flagValue struct {
+	raw string
+	set bool
+}
+
+func (f *flagValue) String() string { return f.raw }
+func (f *flagValue) Set(s string) error {
+	f.raw, f.set = s, true
+	return nil
+}
+
+// boolFlag lets boolean fields be set with a bare -name
+type boolFlag struct{ flagValue }
+
+func (*boolFlag) IsBoolFlag() bool { return true }
+
+// Load fills dst, a pointer to a struct, and validates it. It returns
+// flag.ErrHelp when the arguments ask for usage, which has been printed.
+func (l *Loader) Load(dst any) error {
+	v, err := structValue(dst)
+	if err != nil {
+		return err
+	}
+	all := fields(v, "", false)
+
+	// Parse flags first, since -config names the file, but apply them last
+	args, lookup, output := l.Args, l.LookupEnv, l.Output
+	if args == nil {
+		args = os.Args[1:]
+	}
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	if output == nil {
+		output = os.Stderr
+	}
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(output)
+	file := fs.String("config", l.File, "YAML or JSON config file")
+	flags := make(map[string]*flagValue)
+	for _, f := range all {
+		name := f.tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		fv := &flagValue{}
+		var value flag.Value = fv
+		if f.value.Kind() == reflect.Bool {
+			bf := &boolFlag{}
+			value, fv = bf, &bf.flagValue
+		}
+		fv.raw = f.tag.Get("default")
+		usage := f.tag.Get("usage")
+		if env := f.tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+		fs.Var(value, name, strings.TrimSpace(usage))
+		flags[f.path] = fv
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for _, f := range all {
+		if def, ok := f.tag.Lookup("default"); ok {
+			if err := setString(f.value, def); err != nil {
+				return fmt.Errorf("config: default of %s: %w", f.path, err)
+			}
+		}
+	}
+	if *file != "" {
+		if err := decodeFile(*file, dst); err != nil {
+			return err
+		}
+	}
+	for _, f := range all {
+		env := f.tag.Get("env")
+		if env == "" {
+			continue
+		}
+		if s, ok := lookup(env); ok {
+			if err := setString(f.value, s); err != nil {
+				return fmt.Errorf("config: %s from $%s: %w", f.path, env, err)
+			}
+		}
+	}
+	for _, f := range all {
+		if fv, ok := flags[f.path]; ok && fv.set {
+			if err := setString(f.value, fv.raw); err != nil {
+				return fmt.Errorf("config: %s from -%s: %w", f.path, f.tag.Get("flag"), err)
+			}
+		}
+	}
+
+	if err := validate.Struct(dst); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// decodeFile overlays the YAML or JSON file at path onto dst
+func decodeFile(path string, dst any) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("config: %s: unsupported file type %q", path, ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setString parses s into v according to v's type
+func setString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
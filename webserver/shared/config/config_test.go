@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type limits struct {
+	Rate  float64 `yaml:"rate" default:"10" env:"RATE"`
+	Burst int     `yaml:"burst" default:"20"`
+}
+
+type testConfig struct {
+	Addr    string        `yaml:"addr" default:":8080" env:"ADDR" flag:"addr" usage:"listen address"`
+	Origins []string      `yaml:"origins" default:"http://localhost:3000" env:"ORIGINS" reload:"true"`
+	APIKey  string        `yaml:"api_key" env:"API_KEY" validate:"required"`
+	Timeout time.Duration `yaml:"timeout" default:"5s" flag:"timeout"`
+	Debug   bool          `yaml:"debug" flag:"debug"`
+	Limits  limits        `yaml:"limits" reload:"true"`
+}
+
+// writeFile writes content to a file named name in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "app.yaml", `
+addr: ":9000"
+api_key: from-file
+timeout: 1m
+limits:
+  rate: 2.5
+`)
+	l := &Loader{
+		Args:      []string{"-config", file, "-addr", ":7000", "-debug"},
+		LookupEnv: env(map[string]string{"ADDR": ":6000", "ORIGINS": "https://a.example, https://b.example", "RATE": "4"}),
+	}
+	var cfg testConfig
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := testConfig{
+		Addr:    ":7000",                                            // flag beats env and file
+		Origins: []string{"https://a.example", "https://b.example"}, // env beats default
+		APIKey:  "from-file",
+		Timeout: time.Minute,
+		Debug:   true,
+		Limits:  limits{Rate: 4, Burst: 20}, // env beats file, default fills the rest
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	file := writeFile(t, "app.json", `{"api_key": "k", "timeout": "2s", "origins": ["https://c.example"]}`)
+	var cfg testConfig
+	if err := (&Loader{File: file, Args: []string{}, LookupEnv: env(nil)}).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "k" || cfg.Timeout != 2*time.Second || cfg.Addr != ":8080" || len(cfg.Origins) != 1 {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		l    Loader
+		want string
+	}{
+		{"missing required", Loader{}, "APIKey"},
+		{"bad env", Loader{LookupEnv: env(map[string]string{"API_KEY": "k", "RATE": "fast"})}, "limits.rate from $RATE"},
+		{"bad flag", Loader{Args: []string{"-timeout", "soon"}, LookupEnv: env(map[string]string{"API_KEY": "k"})}, "timeout from -timeout"},
+		{"unknown key", Loader{File: writeFile(t, "a.yaml", "api_key: k\nadress: x\n")}, "adress"},
+		{"file type", Loader{File: writeFile(t, "a.toml", "")}, "unsupported file type"},
+		{"missing file", Loader{File: "/nonexistent/app.yaml"}, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.l
+			if l.Args == nil {
+				l.Args = []string{}
+			}
+			if l.LookupEnv == nil {
+				l.LookupEnv = env(nil)
+			}
+			var cfg testConfig
+			err := l.Load(&cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	var out strings.Builder
+	l := &Loader{Args: []string{"-h"}, LookupEnv: env(nil), Output: &out}
+	if err := l.Load(&testConfig{}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("Load() = %v, want flag.ErrHelp", err)
+	}
+	for _, s := range []string{"-addr", "listen address (env ADDR)", "-config"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("usage lacks %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestLoadNotStruct(t *testing.T) {
+	var s string
+	l := &Loader{Args: []string{}, LookupEnv: env(nil), Output: io.Discard}
+	if err := l.Load(&s); err == nil {
+		t.Error("Load(*string) succeeded")
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("api_key: k\norigins: [https://a.example]\n")
+	live, err := NewLive[testConfig](&Loader{File: file, Args: []string{}, LookupEnv: env(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := live.Get()
+
+	write("api_key: k\naddr: \":9999\"\norigins: [https://b.example]\nlimits: {rate: 1}\n")
+	applied, ignored, err := live.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"origins", "limits.rate"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied = %v, want %v", applied, want)
+	}
+	if want := []string{"addr"}; !reflect.DeepEqual(ignored, want) {
+		t.Errorf("ignored = %v, want %v", ignored, want)
+	}
+	cur := live.Get()
+	if cur.Addr != ":8080" || cur.Origins[0] != "https://b.example" || cur.Limits.Rate != 1 {
+		t.Errorf("after reload: %+v", cur)
+	}
+	if first.Origins[0] != "https://a.example" {
+		t.Error("reload modified the previous configuration")
+	}
+
+	// A broken file keeps the running configuration
+	write("api_key: \"\"\n")
+	if _, _, err := live.Reload(); err == nil {
+		t.Error("reload of an invalid file succeeded")
+	}
+	if live.Get() != cur {
+		t.Error("failed reload replaced the configuration")
+	}
+}
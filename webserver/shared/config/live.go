@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Live holds a configuration that can be reloaded while the program runs.
+// Only fields tagged reload:"true" take new values; a change to any other
+// field is reported and waits for a restart, so things like the listen
+// address stay fixed.
+type Live[T any] struct {
+	loader *Loader
+	cur    atomic.Pointer[T]
+	mu     sync.Mutex // serialises reloads
+}
+
+// NewLive loads the first configuration with l
+func NewLive[T any](l *Loader) (*Live[T], error) {
+	var cfg T
+	if err := l.Load(&cfg); err != nil {
+		return nil, err
+	}
+	v := &Live[T]{loader: l}
+	v.cur.Store(&cfg)
+	return v, nil
+}
+
+// Get returns the current configuration, which callers must not modify
+func (v *Live[T]) Get() *T {
+	return v.cur.Load()
+}
+
+// Reload loads the configuration again and applies its reloadable fields.
+// It returns the paths of the fields that changed and of those whose
+// changes were ignored. If loading fails the current configuration is kept.
+func (v *Live[T]) Reload() (applied, ignored []string, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var next T
+	if err := v.loader.Load(&next); err != nil {
+		return nil, nil, err
+	}
+	merged := *v.cur.Load()
+	oldFields := fields(reflect.ValueOf(&merged).Elem(), "", false)
+	newFields := fields(reflect.ValueOf(&next).Elem(), "", false)
+	for i, f := range oldFields {
+		nv := newFields[i].value
+		if reflect.DeepEqual(f.value.Interface(), nv.Interface()) {
+			continue
+		}
+		if !f.reload {
+			ignored = append(ignored, f.path)
+			continue
+		}
+		f.value.Set(nv)
+		applied = append(applied, f.path)
+	}
+	if len(applied) > 0 {
+		v.cur.Store(&merged)
+	}
+	return applied, ignored, nil
+}
+
+// WatchSIGHUP reloads on every SIGHUP until ctx is done, passing each
+// result to report
+func (v *Live[T]) WatchSIGHUP(ctx context.Context, report func(applied, ignored []string, err error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				report(v.Reload())
+			}
+		}
+	}()
+}
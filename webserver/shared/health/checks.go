@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Heartbeat returns a check that fails when last() is older than maxAge, or zero
+func Heartbeat(last func() time.Time, maxAge time.Duration) Check {
+	return CheckFunc(func(ctx context.Context) error {
+		seen := last()
+		if seen.IsZero() {
+			return fmt.Errorf("no heartbeat received yet")
+		}
+		if age := time.Since(seen); age > maxAge {
+			return fmt.Errorf("last heartbeat %v ago, limit %v", age.Round(time.Millisecond), maxAge)
+		}
+		return nil
+	})
+}
+
+// Suspicion is implemented by accrual failure detectors such as phi.Detector
+type Suspicion interface {
+	Phi(now time.Time) float64
+	Threshold() float64
+}
+
+// Detector returns a check that fails while the detector's suspicion level is
+// at or above its threshold
+func Detector(d Suspicion) Check {
+	return CheckFunc(func(ctx context.Context) error {
+		if phi := d.Phi(time.Now()); phi >= d.Threshold() {
+			return fmt.Errorf("phi %.2f at or above threshold %.2f", phi, d.Threshold())
+		}
+		return nil
+	})
+}
+
+// Ping returns a check that GETs url and fails on transport errors or non-2xx responses
+func Ping(client *http.Client, url string) Check {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CheckFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,268 @@
+// Package health serves /healthz and /readyz endpoints that aggregate
+// registered checks: heartbeat freshness, failure-detector suspicion,
+// dependency pings and custom functions. Each check has its own timeout and
+// can cache its result so probes stay cheap.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check reports an unhealthy dependency by returning an error
+type Check interface {
+	Check(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to the Check interface
+type CheckFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Option configures a registered check
+type Option func(*registered)
+
+// WithTimeout bounds how long the check may run (default 2s)
+func WithTimeout(d time.Duration) Option {
+	return func(r *registered) { r.timeout = d }
+}
+
+// WithCache reuses the last result for d before running the check again
+func WithCache(d time.Duration) Option {
+	return func(r *registered) { r.cacheFor = d }
+}
+
+// ReadinessOnly leaves the check out of /healthz. Use it for dependencies whose
+// failure should take the instance out of rotation without restarting it.
+func ReadinessOnly() Option {
+	return func(r *registered) { r.readinessOnly = true }
+}
+
+type registered struct {
+	name          string
+	check         Check
+	timeout       time.Duration
+	cacheFor      time.Duration
+	readinessOnly bool
+
+	mu     sync.Mutex
+	last   Result
+	cached bool
+	flight *flight // the run in progress, shared by concurrent callers
+}
+
+// flight is one run of a check that concurrent callers wait on together
+type flight struct {
+	done    chan struct{} // closed once res is set
+	res     Result
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string        `json:"status"` // "ok" or "fail"
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// Report is the JSON body of /healthz and /readyz
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Registry holds the checks behind the health endpoints. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*registered
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a check under name, replacing any check with the same name
+func (r *Registry) Register(name string, check Check, opts ...Option) {
+	reg := &registered{name: name, check: check, timeout: 2 * time.Second}
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.checks {
+		if existing.name == name {
+			r.checks[i] = reg
+			return
+		}
+	}
+	r.checks = append(r.checks, reg)
+	sort.Slice(r.checks, func(i, j int) bool { return r.checks[i].name < r.checks[j].name })
+}
+
+// Liveness runs the checks that count towards /healthz
+func (r *Registry) Liveness(ctx context.Context) Report {
+	return r.run(ctx, false)
+}
+
+// Readiness runs every check
+func (r *Registry) Readiness(ctx context.Context) Report {
+	return r.run(ctx, true)
+}
+
+// run executes the selected checks concurrently
+func (r *Registry) run(ctx context.Context, includeReadinessOnly bool) Report {
+	r.mu.RLock()
+	var selected []*registered
+	for _, c := range r.checks {
+		if includeReadinessOnly || !c.readinessOnly {
+			selected = append(selected, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(selected))
+	var wg sync.WaitGroup
+	for i, c := range selected {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(selected))}
+	for i, c := range selected {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "fail"
+		}
+	}
+	return report
+}
+
+// run returns the cached result while it is fresh, and otherwise joins the
+// run in progress or starts one. mu is only held to read and update that
+// state, so a slow check never queues probes behind each other.
+func (c *registered) run(ctx context.Context) Result {
+	c.mu.Lock()
+	if c.cached && time.Since(c.last.CheckedAt) < c.cacheFor {
+		defer c.mu.Unlock()
+		return c.last
+	}
+	f := c.flight
+	if f == nil {
+		// The run is shared by every caller waiting on it, and a cached result
+		// by every caller in the cache window, so it must not depend on the
+		// caller that happened to start it going away
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		c.flight = f
+		go c.execute(runCtx, f)
+	}
+	f.waiters++
+	c.mu.Unlock()
+
+	if c.cacheFor > 0 {
+		// The result outlives this caller, so see the run through; it is
+		// bounded by the timeout
+		<-f.done
+		return f.res
+	}
+	select {
+	case <-f.done:
+		return f.res
+	case <-ctx.Done():
+		c.mu.Lock()
+		if f.waiters--; f.waiters == 0 && c.flight == f {
+			// Nobody wants the result any more; later callers start afresh
+			c.flight = nil
+			f.cancel()
+		}
+		c.mu.Unlock()
+		return Result{Status: "fail", Error: context.Cause(ctx).Error(), CheckedAt: time.Now()}
+	}
+}
+
+// execute runs the check once for flight f and publishes the result
+func (c *registered) execute(ctx context.Context, f *flight) {
+	defer f.cancel()
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- c.check.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		// Don't let a check that ignores its context hold the probe hostage
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New("timed out after " + c.timeout.String())
+		}
+	}
+
+	res := Result{Status: "ok", Duration: time.Since(start), CheckedAt: start}
+	if err != nil {
+		res.Status = "fail"
+		res.Error = err.Error()
+	}
+	c.mu.Lock()
+	f.res = res
+	// An abandoned run was cancelled by its callers leaving, which says
+	// nothing about the dependency, so only a completed run is remembered
+	if c.flight == f {
+		c.flight = nil
+		c.last, c.cached = res, true
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
+
+// LivenessHandler serves the liveness report. See ServeReport for the response format.
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ServeReport(w, req, r.Liveness(req.Context()))
+	})
+}
+
+// ReadinessHandler serves the readiness report. See ServeReport for the response format.
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ServeReport(w, req, r.Readiness(req.Context()))
+	})
+}
+
+// Mount registers GET /healthz and GET /readyz on mux
+func (r *Registry) Mount(mux *http.ServeMux) {
+	mux.Handle("GET /healthz", r.LivenessHandler())
+	mux.Handle("GET /readyz", r.ReadinessHandler())
+}
+
+// ServeReport writes report as JSON with 200 when healthy and 503 otherwise.
+// Per-check details are only included when the request has a "verbose" query parameter.
+func ServeReport(w http.ResponseWriter, r *http.Request, report Report) {
+	if !r.URL.Query().Has("verbose") {
+		report.Checks = nil
+	}
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,219 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, h http.Handler, target string) (*httptest.ResponseRecorder, Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding %s: %v", target, err)
+	}
+	return w, report
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	r := NewRegistry()
+	r.Register("ok", CheckFunc(func(ctx context.Context) error { return nil }))
+	r.Register("db", CheckFunc(func(ctx context.Context) error { return errors.New("connection refused") }), ReadinessOnly())
+
+	mux := http.NewServeMux()
+	r.Mount(mux)
+
+	w, report := get(t, mux, "/healthz")
+	if w.Code != http.StatusOK || report.Status != "ok" {
+		t.Errorf("/healthz = %d %q, want 200 ok", w.Code, report.Status)
+	}
+	if report.Checks != nil {
+		t.Errorf("/healthz included details without verbose: %v", report.Checks)
+	}
+
+	w, report = get(t, mux, "/readyz?verbose")
+	if w.Code != http.StatusServiceUnavailable || report.Status != "fail" {
+		t.Errorf("/readyz = %d %q, want 503 fail", w.Code, report.Status)
+	}
+	if got := report.Checks["db"]; got.Status != "fail" || got.Error != "connection refused" {
+		t.Errorf("db check = %+v, want the failure detail", got)
+	}
+	if got := report.Checks["ok"]; got.Status != "ok" {
+		t.Errorf("ok check = %+v", got)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slow", CheckFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second) // ignores ctx on purpose
+		return nil
+	}), WithTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	report := r.Readiness(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Readiness took %v, want the check timeout to cut it short", elapsed)
+	}
+	if report.Status != "fail" {
+		t.Errorf("status = %q, want fail", report.Status)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	var calls atomic.Int64
+	r := NewRegistry()
+	r.Register("counted", CheckFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}), WithCache(time.Minute))
+
+	for i := 0; i < 5; i++ {
+		r.Liveness(context.Background())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("check ran %d times, want 1 with caching", n)
+	}
+}
+
+func TestCachedCheckIgnoresCallerCancellation(t *testing.T) {
+	var calls atomic.Int64
+	r := NewRegistry()
+	r.Register("slow", CheckFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		}
+	}), WithCache(time.Minute))
+
+	// The first caller gives up while the check runs
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Millisecond, cancel)
+	if report := r.Readiness(ctx); report.Status != "ok" {
+		t.Errorf("status with a cancelled caller = %q, want ok from the detached check", report.Status)
+	}
+	// Later callers get the cached success
+	if report := r.Readiness(context.Background()); report.Status != "ok" {
+		t.Errorf("status = %q, want the cached ok", report.Status)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("check ran %d times, want 1", n)
+	}
+}
+
+func TestUncachedCheckFollowsCaller(t *testing.T) {
+	r := NewRegistry()
+	r.Register("blocking", CheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := r.Readiness(ctx); report.Status != "fail" {
+		t.Errorf("status = %q, want fail when the caller has gone", report.Status)
+	}
+}
+
+func TestConcurrentProbesShareOneRun(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	r := NewRegistry()
+	r.Register("slow", CheckFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	}))
+	c := r.checks[0]
+
+	const probes = 5
+	reports := make(chan Report, probes)
+	for i := 0; i < probes; i++ {
+		go func() { reports <- r.Readiness(context.Background()) }()
+	}
+	// Wait until every probe has joined the run, then let it finish
+	for {
+		c.mu.Lock()
+		joined := c.flight != nil && c.flight.waiters == probes
+		c.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for i := 0; i < probes; i++ {
+		if report := <-reports; report.Status != "ok" {
+			t.Errorf("probe %d status = %q", i, report.Status)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("check ran %d times for %d concurrent probes, want 1", n, probes)
+	}
+}
+
+func TestAbandonedRunIsCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	r := NewRegistry()
+	r.Register("blocking", CheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}), WithTimeout(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r.Readiness(ctx)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the check kept running after its only caller left")
+	}
+}
+
+type fakeDetector struct{ phi float64 }
+
+func (d fakeDetector) Phi(time.Time) float64 { return d.phi }
+func (d fakeDetector) Threshold() float64    { return 8 }
+
+func TestBuiltinChecks(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	tests := []struct {
+		name        string
+		check       Check
+		expectError bool
+	}{
+		{"Fresh heartbeat", Heartbeat(time.Now, time.Second), false},
+		{"Stale heartbeat", Heartbeat(func() time.Time { return time.Now().Add(-time.Minute) }, time.Second), true},
+		{"No heartbeat yet", Heartbeat(func() time.Time { return time.Time{} }, time.Second), true},
+		{"Detector below threshold", Detector(fakeDetector{phi: 1}), false},
+		{"Detector above threshold", Detector(fakeDetector{phi: 9}), true},
+		{"Ping up", Ping(nil, up.URL), false},
+		{"Ping down", Ping(nil, down.URL), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check.Check(context.Background())
+			if (err != nil) != tt.expectError {
+				t.Errorf("Check() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package metrics_test
+
+import (
+	"os"
+	"shared/metrics"
+)
+
+// Pipeline stages share one histogram, split by a stage label, and can be
+// rendered without a server once a batch run finishes
+func Example_pipeline() {
+	reg := metrics.NewRegistry()
+	items := reg.NewCounter("pipeline_items_total", "Items leaving each stage.", "stage")
+	seconds := reg.NewHistogram("pipeline_item_seconds", "Processing time per item.", []float64{0.01, 0.1}, "stage")
+
+	for _, d := range []float64{0.004, 0.02, 0.3} {
+		items.Inc("square")
+		seconds.Observe(d, "square")
+	}
+
+	reg.WriteTo(os.Stdout)
+	// Output:
+	// # HELP pipeline_item_seconds Processing time per item.
+	// # TYPE pipeline_item_seconds histogram
+	// pipeline_item_seconds_bucket{stage="square",le="0.01"} 1
+	// pipeline_item_seconds_bucket{stage="square",le="0.1"} 2
+	// pipeline_item_seconds_bucket{stage="square",le="+Inf"} 3
+	// pipeline_item_seconds_sum{stage="square"} 0.324
+	// pipeline_item_seconds_count{stage="square"} 3
+	// # HELP pipeline_items_total Items leaving each stage.
+	// # TYPE pipeline_items_total counter
+	// pipeline_items_total{stage="square"} 3
+}
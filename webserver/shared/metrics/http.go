@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP holds the standard request metrics of a server:
+//
+//	http_requests_total{method,route,status}
+//	http_request_duration_seconds{method,route}
+//	http_requests_in_flight
+//
+// Routes are templates such as "/users/{id}", never raw paths, so the
+// number of series stays bounded.
+type HTTP struct {
+	requests *Counter
+	duration *Histogram
+	inFlight *Gauge
+}
+
+// NewHTTP registers the request metrics in r
+func NewHTTP(r *Registry) *HTTP {
+	return &HTTP{
+		requests: r.NewCounter("http_requests_total", "HTTP requests by method, route template and status code.", "method", "route", "status"),
+		duration: r.NewHistogram("http_request_duration_seconds", "HTTP request latency by method and route template.", nil, "method", "route"),
+		inFlight: r.NewGauge("http_requests_in_flight", "HTTP requests currently being served."),
+	}
+}
+
+// Begin counts a request as in flight and returns the function that records
+// its outcome. Framework adapters call it around their handler chain.
+func (m *HTTP) Begin() (done func(method, route string, status int)) {
+	start := time.Now()
+	m.inFlight.Inc()
+	return func(method, route string, status int) {
+		m.inFlight.Dec()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.Inc(method, route, strconv.Itoa(status))
+		m.duration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
+
+// Middleware records every request passing through next. route returns the
+// template the request matched once next has served it; nil means the
+// http.ServeMux pattern, without its method.
+func (m *HTTP) Middleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	if route == nil {
+		route = muxPattern
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done := m.Begin()
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				done(r.Method, route(r), sw.status())
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// muxPattern returns the path part of the pattern http.ServeMux matched, which
+// it stores on the request it was given
+func muxPattern(r *http.Request) string {
+	pattern := r.Pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = pattern[i+1:]
+	}
+	return pattern
+}
+
+// statusWriter remembers the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through for handlers that stream
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := NewRegistry()
+	m := NewHTTP(r)
+
+	mux := http.NewServeMux()
+	var inFlight string
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, req *http.Request) {
+		inFlight = render(t, r)
+		w.Write([]byte("user"))
+	})
+	mux.HandleFunc("POST /users", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	h := m.Middleware(nil)(mux)
+
+	for _, target := range []string{"/users/1", "/users/2"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere/3", nil))
+
+	if !strings.Contains(inFlight, "http_requests_in_flight 1\n") {
+		t.Errorf("in-flight gauge during a request:\n%s", inFlight)
+	}
+	out := render(t, r)
+	for _, line := range []string{
+		// Both user IDs share the route template's series
+		`http_requests_total{method="GET",route="/users/{id}",status="200"} 2`,
+		`http_requests_total{method="POST",route="/users",status="201"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/users/{id}"} 2`,
+		`http_request_duration_seconds_bucket{method="POST",route="/users",le="+Inf"} 1`,
+		"http_requests_in_flight 0",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "/users/1") {
+		t.Error("raw path used as a label")
+	}
+}
+
+func TestMiddlewareRouteFunc(t *testing.T) {
+	r := NewRegistry()
+	m := NewHTTP(r)
+	h := m.Middleware(func(*http.Request) string { return "/custom" })(http.NotFoundHandler())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/x", nil))
+
+	if out := render(t, r); !strings.Contains(out, `http_requests_total{method="DELETE",route="/custom",status="404"} 1`) {
+		t.Errorf("custom route not used:\n%s", out)
+	}
+}
@@ -0,0 +1,331 @@
+// Package metrics is a small metrics registry rendered in the Prometheus text
+// exposition format. It has counters, gauges and histograms, each optionally
+// split by labels, plus gauges computed at scrape time for state that already
+// lives elsewhere, such as a cache's size or a pipeline stage's queue.
+//
+// Label values are passed to every call in the order the labels were
+// declared, so an update is a single call:
+//
+//	requests := reg.NewCounter("cache_requests_total", "Cache lookups.", "result")
+//	requests.Inc("hit")
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are histogram upper bounds suited to request latencies in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	namePattern  = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Registry holds metric families. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is one named metric with every labelled series it has seen
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64      // histograms only
+	fn               func() float64 // gauge funcs only
+
+	mu     sync.RWMutex
+	series map[string]*series // keyed by joined label values
+}
+
+// series is one set of label values. Counters and gauges use value;
+// histograms use counts, sum and count.
+type series struct {
+	values []string
+	value  atomicFloat
+	counts []atomic.Uint64 // per bucket, not cumulative
+	sum    atomicFloat
+	count  atomic.Uint64
+}
+
+// atomicFloat is a float64 updated with compare-and-swap
+type atomicFloat struct{ bits atomic.Uint64 }
+
+func (f *atomicFloat) load() float64   { return math.Float64frombits(f.bits.Load()) }
+func (f *atomicFloat) store(v float64) { f.bits.Store(math.Float64bits(v)) }
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// register adds a family, panicking on invalid or duplicate names: those are
+// programming errors that should fail at start-up, not at scrape time
+func (r *Registry) register(f *family) *family {
+	if !namePattern.MatchString(f.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", f.name))
+	}
+	for _, l := range f.labels {
+		if !labelPattern.MatchString(l) || strings.HasPrefix(l, "__") || (f.kind == "histogram" && l == "le") {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", l, f.name))
+		}
+	}
+	f.series = make(map[string]*series)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[f.name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.families[f.name] = f
+	return f
+}
+
+// with returns the series for values, creating it on first use
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+	s = &series{values: append([]string(nil), values...)}
+	if f.kind == "histogram" {
+		s.counts = make([]atomic.Uint64, len(f.buckets))
+	}
+	f.series[key] = s
+	return s
+}
+
+// Counter is a value that only goes up, such as a number of requests
+type Counter struct{ f *family }
+
+// NewCounter registers a counter. By convention its name ends in _total.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, kind: "counter", labels: labels})}
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.f.with(values).value.add(1)
+}
+
+// Add adds v, which must not be negative, to the series with the given label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.f.with(values).value.add(v)
+}
+
+// Gauge is a value that goes up and down, such as requests in flight
+type Gauge struct{ f *family }
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, kind: "gauge", labels: labels})}
+}
+
+// Set sets the series with the given label values to v
+func (g *Gauge) Set(v float64, values ...string) { g.f.with(values).value.store(v) }
+
+// Add adds v, which may be negative, to the series with the given label values
+func (g *Gauge) Add(v float64, values ...string) { g.f.with(values).value.add(v) }
+
+// Inc adds one to the series with the given label values
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+
+// Dec subtracts one from the series with the given label values
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+// NewGaugeFunc registers an unlabelled gauge whose value fn computes at every
+// scrape. fn must be safe to call concurrently.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// Histogram counts observations, such as latencies, into buckets
+type Histogram struct{ f *family }
+
+// NewHistogram registers a histogram with the given bucket upper bounds, or
+// DefaultBuckets when buckets is nil. A +Inf bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if n := len(buckets); n > 0 && math.IsInf(buckets[n-1], 1) {
+		buckets = buckets[:n-1]
+	}
+	return &Histogram{r.register(&family{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets})}
+}
+
+// Observe records v in the series with the given label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.f.with(values)
+	if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+		s.counts[i].Add(1)
+	}
+	s.sum.add(v)
+	s.count.Add(1)
+}
+
+// Handler serves the registry in the text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// WriteTo writes every metric to w in the text exposition format, families
+// sorted by name and series by label values
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	err := cw.w.Flush()
+	if cw.err != nil {
+		err = cw.err
+	}
+	return cw.n, err
+}
+
+func (f *family) write(w *countingWriter) {
+	if f.help != "" {
+		w.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	}
+	w.printf("# TYPE %s %s\n", f.name, f.kind)
+	if f.fn != nil {
+		w.printf("%s %s\n", f.name, formatFloat(f.fn()))
+		return
+	}
+
+	f.mu.RLock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+
+	for _, s := range all {
+		if f.kind != "histogram" {
+			w.printf("%s%s %s\n", f.name, labelString(f.labels, s.values, ""), formatFloat(s.value.load()))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i].Load()
+			w.printf("%s_bucket%s %d\n", f.name, labelString(f.labels, s.values, formatFloat(bound)), cumulative)
+		}
+		count := s.count.Load()
+		w.printf("%s_bucket%s %d\n", f.name, labelString(f.labels, s.values, "+Inf"), count)
+		w.printf("%s_sum%s %s\n", f.name, labelString(f.labels, s.values, ""), formatFloat(s.sum.load()))
+		w.printf("%s_count%s %d\n", f.name, labelString(f.labels, s.values, ""), count)
+	}
+}
+
+// labelString renders {name="value",...}, with an le label last when le is set
+func labelString(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, escapeLabel(values[i]))
+	}
+	if le != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `le="%s"`, le)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts bytes and keeps the first error so family.write
+// doesn't have to check every line
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...any) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
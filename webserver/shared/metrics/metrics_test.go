@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	hits := r.NewCounter("cache_requests_total", "Cache lookups by result.", "result")
+	size := r.NewGauge("cache_entries", "Entries in the cache.")
+	latency := r.NewHistogram("stage_seconds", "Time per item.", []float64{0.1, 1}, "stage")
+	r.NewGaugeFunc("queue_length", "Items waiting.\nSecond line with a \\.", func() float64 { return 3 })
+	labels := r.NewCounter("odd_labels_total", "", "value")
+
+	hits.Inc("hit")
+	hits.Inc("hit")
+	hits.Add(0.5, "miss")
+	size.Set(10)
+	size.Dec()
+	latency.Observe(0.05, "square")
+	latency.Observe(0.1, "square") // on a bound, so in that bucket
+	latency.Observe(5, "square")
+	labels.Inc("quote \" backslash \\ newline \n")
+
+	want := `# HELP cache_entries Entries in the cache.
+# TYPE cache_entries gauge
+cache_entries 9
+# HELP cache_requests_total Cache lookups by result.
+# TYPE cache_requests_total counter
+cache_requests_total{result="hit"} 2
+cache_requests_total{result="miss"} 0.5
+# TYPE odd_labels_total counter
+odd_labels_total{value="quote \" backslash \\ newline \n"} 1
+# HELP queue_length Items waiting.\nSecond line with a \\.
+# TYPE queue_length gauge
+queue_length 3
+# HELP stage_seconds Time per item.
+# TYPE stage_seconds histogram
+stage_seconds_bucket{stage="square",le="0.1"} 2
+stage_seconds_bucket{stage="square",le="1"} 2
+stage_seconds_bucket{stage="square",le="+Inf"} 3
+stage_seconds_sum{stage="square"} 5.15
+stage_seconds_count{stage="square"} 3
+`
+	if got := render(t, r); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || w.Body.String() != want {
+		t.Errorf("handler = %q, %q", w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("taken_total", "")
+	c := r.NewCounter("labelled_total", "", "a", "b")
+
+	tests := map[string]func(){
+		"Duplicate name":     func() { r.NewGauge("taken_total", "") },
+		"Invalid name":       func() { r.NewGauge("no-dashes", "") },
+		"Invalid label":      func() { r.NewGauge("g", "", "bad label") },
+		"Reserved label":     func() { r.NewGauge("g2", "", "__name") },
+		"Histogram le label": func() { r.NewHistogram("h", "", nil, "le") },
+		"Wrong label count":  func() { c.Inc("only one") },
+		"Negative counter":   func() { c.Add(-1, "a", "b") },
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c_total", "", "worker")
+	g := r.NewGauge("g", "")
+	h := r.NewHistogram("h", "", []float64{1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc("shared")
+				g.Add(0.5)
+				h.Observe(0.5)
+				if j%100 == 0 {
+					render(t, r)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := render(t, r)
+	for _, line := range []string{`c_total{worker="shared"} 8000`, "g 4000", "h_count 8000", "h_sum 4000"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for v, want := range map[float64]string{1: "1", 0.25: "0.25", 1e21: "1e+21", math.Inf(1): "+Inf", math.Inf(-1): "-Inf"} {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %q, want %q", v, got, want)
+		}
+	}
+	if got := formatFloat(math.NaN()); got != "NaN" {
+		t.Errorf("formatFloat(NaN) = %q", got)
+	}
+}
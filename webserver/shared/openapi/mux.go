@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Mux is an http.ServeMux that remembers the patterns registered through
+// its Handle and HandleFunc methods, since ServeMux can't list them.
+// Patterns without a method match every method and aren't recorded.
+type Mux struct {
+	*http.ServeMux
+
+	mu     sync.Mutex
+	routes []Route
+}
+
+// NewMux returns an empty Mux
+func NewMux() *Mux {
+	return &Mux{ServeMux: http.NewServeMux()}
+}
+
+// Handle registers handler for pattern, like http.ServeMux.Handle
+func (m *Mux) Handle(pattern string, handler http.Handler) {
+	m.ServeMux.Handle(pattern, handler)
+	m.record(pattern)
+}
+
+// HandleFunc registers handler for pattern, like http.ServeMux.HandleFunc
+func (m *Mux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.ServeMux.HandleFunc(pattern, handler)
+	m.record(pattern)
+}
+
+// record adds a "[METHOD ][HOST]/PATH" pattern
+func (m *Mux) record(pattern string) {
+	method, rest, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	if i := strings.Index(rest, "/"); i > 0 {
+		rest = rest[i:] // drop the host
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, Route{Method: method, Path: rest})
+}
+
+// Routes returns the recorded routes in registration order
+func (m *Mux) Routes() []Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Route(nil), m.routes...)
+}
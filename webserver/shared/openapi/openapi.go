@@ -0,0 +1,302 @@
+// Package openapi generates OpenAPI 3.1 documents from the routes a server
+// registers.
+//
+// Routes come from the router: gin.Engine.Routes() for Gin, or Mux for
+// net/http. A Spec adds what the router can't know, each operation's
+// parameters, bodies and responses, described with Go values whose types
+// are reflected into JSON Schema. Struct fields are named as encoding/json
+// names them, and Gin binding and shared/validate tags become constraints:
+// required, min/max/len (lengths for strings, item counts for slices),
+// gt/gte/lt/lte, oneof and email.
+//
+// A route without a description still appears, with its path parameters,
+// and Undocumented lists them, so a test can catch routes added without
+// documentation.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Route is a registered method and path. Paths may use Gin's :name and
+// *name or ServeMux's {name} and {name...} wildcards.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Operation describes a route
+type Operation struct {
+	ID          string // operationId, for generated clients
+	Summary     string
+	Description string
+	Tags        []string
+	Parameters  []Parameter
+	// Body is a value of the request body's type, such as catalog.Product{}, or a Schema
+	Body any
+	// BodyType is the request's media type; it defaults to application/json
+	BodyType  string
+	Responses map[int]Response
+	// Security names the schemes, any one of which authenticates the
+	// request, and Scopes what the credentials must grant
+	Security []string
+	Scopes   []string
+}
+
+// Parameter is a path, query or header parameter. Path parameters are added
+// as strings when not listed.
+type Parameter struct {
+	Name        string
+	In          string // "path", "query" or "header"
+	Description string
+	Required    bool
+	Schema      any // a value of the parameter's type, or a Schema; nil means string
+}
+
+// Response describes one status code's response
+type Response struct {
+	Description string // defaults to the status text
+	// Body is a value of the body's type, or a Schema. With no Body but a
+	// ContentType the body is described as any content of that type.
+	Body        any
+	ContentType string            // defaults to application/json when Body is set
+	Headers     map[string]string // header name -> description
+}
+
+// Spec holds operation descriptions and the document's metadata
+type Spec struct {
+	Title       string
+	Version     string
+	Description string
+	// SecuritySchemes are the components operations refer to by name in Security
+	SecuritySchemes map[string]Schema
+
+	mu  sync.Mutex
+	ops map[Route]Operation
+}
+
+// New returns an empty Spec
+func New(title, version string) *Spec {
+	return &Spec{Title: title, Version: version, ops: make(map[Route]Operation)}
+}
+
+// Describe documents the route method path, replacing any earlier description
+func (s *Spec) Describe(method, path string, op Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops[normalize(Route{method, path})] = op
+}
+
+// wildcard matches Gin's :name and *name and ServeMux's {name} and {name...}
+var wildcard = regexp.MustCompile(`[:*]([^/]+)|\{([^}.]+)(?:\.\.\.)?\}`)
+
+// normalize converts r's path to OpenAPI's {name} syntax
+func normalize(r Route) Route {
+	r.Method = strings.ToUpper(r.Method)
+	r.Path = strings.ReplaceAll(r.Path, "{$}", "")
+	r.Path = wildcard.ReplaceAllStringFunc(r.Path, func(m string) string {
+		sub := wildcard.FindStringSubmatch(m)
+		return "{" + sub[1] + sub[2] + "}"
+	})
+	return r
+}
+
+// pathParams lists the {name} parameters of an OpenAPI path in order
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range wildcard.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1]+m[2])
+	}
+	return names
+}
+
+// Undocumented returns the routes, as "METHOD /path", that have no description
+func (s *Spec) Undocumented(routes []Route) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missing []string
+	for _, r := range routes {
+		r = normalize(r)
+		if _, ok := s.ops[r]; !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+	return missing
+}
+
+// Document builds the OpenAPI document for routes. Its maps marshal to JSON
+// with sorted keys, so the output is stable.
+func (s *Spec) Document(routes []Route) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reflected := newSchemas()
+	paths := make(map[string]map[string]any)
+	for _, r := range routes {
+		r = normalize(r)
+		if paths[r.Path] == nil {
+			paths[r.Path] = make(map[string]any)
+		}
+		paths[r.Path][strings.ToLower(r.Method)] = s.operation(r, reflected)
+	}
+
+	info := map[string]any{"title": s.Title, "version": s.Version}
+	if s.Description != "" {
+		info["description"] = s.Description
+	}
+	components := map[string]any{}
+	if len(reflected.components) > 0 {
+		components["schemas"] = reflected.components
+	}
+	if len(s.SecuritySchemes) > 0 {
+		components["securitySchemes"] = s.SecuritySchemes
+	}
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info":    info,
+		"paths":   paths,
+	}
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+	return doc
+}
+
+func (s *Spec) operation(r Route, reflected *schemas) map[string]any {
+	op := s.ops[r]
+	out := make(map[string]any)
+	set := func(key string, v any, ok bool) {
+		if ok {
+			out[key] = v
+		}
+	}
+	set("operationId", op.ID, op.ID != "")
+	set("summary", op.Summary, op.Summary != "")
+	set("description", op.Description, op.Description != "")
+	set("tags", op.Tags, len(op.Tags) > 0)
+
+	// Path parameters first, in path order, then the rest as listed
+	var params []map[string]any
+	listed := make(map[string]Parameter)
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			listed[p.Name] = p
+		}
+	}
+	for _, name := range pathParams(r.Path) {
+		p, ok := listed[name]
+		if !ok {
+			p = Parameter{Name: name, In: "path"}
+		}
+		p.Required = true
+		params = append(params, parameter(p, reflected))
+	}
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			params = append(params, parameter(p, reflected))
+		}
+	}
+	set("parameters", params, len(params) > 0)
+
+	if op.Body != nil {
+		bodyType := op.BodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{bodyType: map[string]any{"schema": reflected.of(op.Body)}},
+		}
+	}
+
+	responses := make(map[string]any)
+	codes := make([]int, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		responses[strconv.Itoa(code)] = response(code, op.Responses[code], reflected)
+	}
+	if len(responses) == 0 {
+		responses["default"] = map[string]any{"description": "Undocumented"}
+	}
+	out["responses"] = responses
+
+	if len(op.Security) > 0 {
+		scopes := op.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		var reqs []map[string][]string
+		for _, name := range op.Security {
+			reqs = append(reqs, map[string][]string{name: scopes})
+		}
+		out["security"] = reqs
+	}
+	return out
+}
+
+func parameter(p Parameter, reflected *schemas) map[string]any {
+	schema := Schema{"type": "string"}
+	if p.Schema != nil {
+		schema = reflected.of(p.Schema)
+	}
+	out := map[string]any{"name": p.Name, "in": p.In, "schema": schema}
+	if p.Description != "" {
+		out["description"] = p.Description
+	}
+	if p.Required {
+		out["required"] = true
+	}
+	return out
+}
+
+func response(code int, r Response, reflected *schemas) map[string]any {
+	desc := r.Description
+	if desc == "" {
+		desc = http.StatusText(code)
+	}
+	out := map[string]any{"description": desc}
+	contentType := r.ContentType
+	if contentType == "" && r.Body != nil {
+		contentType = "application/json"
+	}
+	if contentType != "" {
+		schema := reflected.of(r.Body)
+		if schema == nil {
+			schema = Schema{}
+		}
+		out["content"] = map[string]any{contentType: map[string]any{"schema": schema}}
+	}
+	if len(r.Headers) > 0 {
+		headers := make(map[string]any)
+		for name, desc := range r.Headers {
+			headers[name] = map[string]any{"description": desc, "schema": Schema{"type": "string"}}
+		}
+		out["headers"] = headers
+	}
+	return out
+}
+
+// Handler serves the document for the routes returns as JSON. routes is
+// called on the first request, once every route has been registered.
+func (s *Spec) Handler(routes func() []Route) http.Handler {
+	var (
+		once sync.Once
+		body []byte
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			body, _ = json.MarshalIndent(s.Document(routes()), "", "  ")
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"/api/v1/products/:id": "/api/v1/products/{id}",
+		"/static/*filepath":    "/static/{filepath}",
+		"/users/{id}":          "/users/{id}",
+		"/files/{path...}":     "/files/{path}",
+		"/{$}":                 "/",
+		"/a/:x/b/{y}":          "/a/{x}/b/{y}",
+	} {
+		if got := normalize(Route{"get", in}); got != (Route{"GET", want}) {
+			t.Errorf("normalize(%q) = %v, want %s", in, got, want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name" validate:"required"`
+	}
+	spec := New("Users", "1.0.0")
+	spec.SecuritySchemes = map[string]Schema{"apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"}}
+	spec.Describe(http.MethodGet, "/users/:id", Operation{
+		ID:         "getUser",
+		Parameters: []Parameter{{Name: "id", In: "path", Schema: 0}, {Name: "verbose", In: "query", Schema: false}},
+		Responses: map[int]Response{
+			http.StatusOK:       {Body: user{}, Headers: map[string]string{"ETag": "Version of the user"}},
+			http.StatusNotFound: {Description: "No such user"},
+		},
+		Security: []string{"apiKey"},
+		Scopes:   []string{"users:read"},
+	})
+	spec.Describe(http.MethodPost, "/users", Operation{Body: user{}, Responses: map[int]Response{http.StatusCreated: {Body: user{}}}})
+
+	routes := []Route{{"GET", "/users/{id}"}, {"POST", "/users"}, {"DELETE", "/users/{id}"}}
+	doc := spec.Document(routes)
+	got := jsonOf(t, doc)
+	var parsed struct {
+		OpenAPI string
+		Paths   map[string]map[string]map[string]any
+	}
+	json.Unmarshal([]byte(got), &parsed)
+	if parsed.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", parsed.OpenAPI)
+	}
+
+	get := parsed.Paths["/users/{id}"]["get"]
+	for key, want := range map[string]string{
+		"operationId": `"getUser"`,
+		"parameters":  `[{"in":"path","name":"id","required":true,"schema":{"type":"integer"}},{"in":"query","name":"verbose","schema":{"type":"boolean"}}]`,
+		"responses": `{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/User"}}},"description":"OK",` +
+			`"headers":{"ETag":{"description":"Version of the user","schema":{"type":"string"}}}},"404":{"description":"No such user"}}`,
+		"security": `[{"apiKey":["users:read"]}]`,
+	} {
+		if got := jsonOf(t, get[key]); got != want {
+			t.Errorf("GET %s = %s, want %s", key, got, want)
+		}
+	}
+	if got := jsonOf(t, parsed.Paths["/users"]["post"]["requestBody"]); got != `{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/User"}}},"required":true}` {
+		t.Errorf("POST requestBody = %s", got)
+	}
+	// Undocumented routes still appear with their path parameters
+	if got := jsonOf(t, parsed.Paths["/users/{id}"]["delete"]); got != `{"parameters":[{"in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"default":{"description":"Undocumented"}}}` {
+		t.Errorf("undocumented DELETE = %s", got)
+	}
+	if missing := spec.Undocumented(routes); !reflect.DeepEqual(missing, []string{"DELETE /users/{id}"}) {
+		t.Errorf("Undocumented = %v", missing)
+	}
+}
+
+func TestMuxAndHandler(t *testing.T) {
+	mux := NewMux()
+	ok := func(http.ResponseWriter, *http.Request) {}
+	mux.HandleFunc("/", ok)
+	mux.HandleFunc("GET /users/{id}", ok)
+	mux.Handle("POST example.com/users", http.HandlerFunc(ok))
+	if got, want := mux.Routes(), []Route{{"GET", "/users/{id}"}, {"POST", "/users"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() = %v, want %v", got, want)
+	}
+
+	spec := New("Users", "1.0.0")
+	mux.Handle("GET /openapi.json", spec.Handler(mux.Routes))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct{ Paths map[string]any }
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("served %q: %v", w.Body, err)
+	}
+	// The handler lists the routes registered by the time it first runs, itself included
+	if len(doc.Paths) != 3 {
+		t.Errorf("paths = %v", doc.Paths)
+	}
+}
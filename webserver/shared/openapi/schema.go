@@ -0,0 +1,299 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema object. Passing a Schema where a Go value is
+// expected, such as Operation.Body, uses it as written instead of reflecting.
+type Schema map[string]any
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawType       = reflect.TypeOf(json.RawMessage(nil))
+	schemaType    = reflect.TypeOf(Schema(nil))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemas reflects Go types into JSON Schema, collecting named structs as
+// reusable components
+type schemas struct {
+	components map[string]Schema
+	names      map[reflect.Type]string
+	taken      map[string]reflect.Type
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]Schema),
+		names:      make(map[reflect.Type]string),
+		taken:      make(map[string]reflect.Type),
+	}
+}
+
+// of returns the schema of v's type. A Schema value is returned as is and
+// nil means no schema.
+func (s *schemas) of(v any) Schema {
+	if v == nil {
+		return nil
+	}
+	if schema, ok := v.(Schema); ok {
+		return schema
+	}
+	return s.forType(reflect.TypeOf(v))
+}
+
+func (s *schemas) forType(t reflect.Type) Schema {
+	if t.Kind() == reflect.Pointer {
+		return nullable(s.forType(t.Elem()))
+	}
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawType, t == schemaType:
+		return Schema{}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Custom JSON can't be reflected
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": s.forType(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.forType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	// Interfaces and anything JSON can't encode accept any value
+	return Schema{}
+}
+
+// ref registers the named struct t as a component and returns a reference to it
+func (s *schemas) ref(t reflect.Type) Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = componentName(t)
+		if _, clash := s.taken[name]; clash {
+			// Qualify with the package when two packages use the same name
+			name = componentName(t, t.PkgPath())
+		}
+		s.names[t], s.taken[name] = name, t
+		// Register before reflecting the fields so recursive types terminate
+		s.components[name] = nil
+		s.components[name] = s.object(t)
+	}
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName builds a component name from t's name and, optionally, the
+// last element of its package path, dropping characters that aren't
+// allowed, such as the brackets of generic types
+func componentName(t reflect.Type, pkg ...string) string {
+	var b strings.Builder
+	upper := true
+	for _, part := range append(pkg, t.Name()) {
+		for _, r := range part[strings.LastIndex(part, "/")+1:] {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+		upper = true
+	}
+	return b.String()
+}
+
+// object reflects a struct the way encoding/json encodes it
+func (s *schemas) object(t reflect.Type) Schema {
+	props := Schema{}
+	var required []string
+	s.fields(t, props, &required)
+	schema := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, props Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				// Promoted fields, as encoding/json flattens them
+				s.fields(ft, props, required)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		rules := parseRules(sf.Tag)
+		schema := s.forType(ft)
+		if ft.Kind() == reflect.Pointer {
+			applyRules(nonNull(schema), ft.Elem(), rules)
+		} else {
+			applyRules(schema, ft, rules)
+		}
+		if strings.Contains(opts, "string") {
+			schema = Schema{"type": "string"}
+		}
+		props[name] = schema
+		if _, ok := rules["required"]; ok {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable lets schema also be null
+func nullable(schema Schema) Schema {
+	if typ, ok := schema["type"].(string); ok {
+		schema["type"] = []string{typ, "null"}
+		return schema
+	}
+	if len(schema) == 0 {
+		return schema
+	}
+	return Schema{"anyOf": []Schema{schema, {"type": "null"}}}
+}
+
+// nonNull returns the part of a nullable schema that describes the value
+func nonNull(schema Schema) Schema {
+	if anyOf, ok := schema["anyOf"].([]Schema); ok {
+		return anyOf[0]
+	}
+	return schema
+}
+
+// parseRules merges Gin's binding and shared/validate's validate tags into
+// rule name -> parameter. Rules after dive apply to elements and are skipped.
+func parseRules(tag reflect.StructTag) map[string]string {
+	rules := make(map[string]string)
+	for _, key := range []string{"binding", "validate"} {
+		for _, part := range strings.Split(tag.Get(key), ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "dive" {
+				break
+			}
+			if name != "" {
+				rules[name] = param
+			}
+		}
+	}
+	return rules
+}
+
+// applyRules adds the constraints rules express to schema, a schema of type t.
+// Bounds measure length for strings and element counts for slices and maps,
+// as the validators do.
+func applyRules(schema Schema, t reflect.Type, rules map[string]string) {
+	if _, ok := schema["$ref"]; ok {
+		return
+	}
+	var minKey, maxKey string
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+	for name, param := range rules {
+		n, err := strconv.ParseFloat(param, 64)
+		numeric := err == nil
+		switch {
+		case name == "email":
+			schema["format"] = "email"
+		case name == "url":
+			schema["format"] = "uri"
+		case name == "uuid":
+			schema["format"] = "uuid"
+		case name == "oneof":
+			var enum []any
+			for _, option := range strings.Fields(param) {
+				enum = append(enum, enumValue(t, option))
+			}
+			schema["enum"] = enum
+		case !numeric:
+		case minKey != "":
+			// Length bounds
+			switch name {
+			case "min", "gte":
+				schema[minKey] = int(n)
+			case "gt":
+				schema[minKey] = int(n) + 1
+			case "max", "lte":
+				schema[maxKey] = int(n)
+			case "lt":
+				schema[maxKey] = int(n) - 1
+			case "len":
+				schema[minKey], schema[maxKey] = int(n), int(n)
+			}
+		default:
+			switch name {
+			case "min", "gte":
+				schema["minimum"] = n
+			case "gt":
+				schema["exclusiveMinimum"] = n
+			case "max", "lte":
+				schema["maximum"] = n
+			case "lt":
+				schema["exclusiveMaximum"] = n
+			}
+		}
+	}
+}
+
+// enumValue converts a oneof option to the JSON type of t
+func enumValue(t reflect.Type, option string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(option, 64); err == nil {
+			return n
+		}
+	}
+	return option
+}
@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type product struct {
+	base
+	Name      string            `json:"name" binding:"required,min=3"`
+	Price     float64           `json:"price" binding:"required,gt=0"`
+	Tags      []string          `json:"tags,omitempty" validate:"max=5"`
+	Email     string            `json:"email" validate:"email"`
+	Status    string            `json:"status" binding:"oneof=draft live"`
+	Steps     int               `json:"steps" binding:"omitempty,min=1,max=60"`
+	DeletedAt *time.Time        `json:"deleted_at,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Parent    *product          `json:"parent,omitempty"`
+	Extra     any               `json:"extra"`
+	Count     int64             `json:"count,string"`
+	hidden    int
+	Skipped   int `json:"-"`
+}
+
+// jsonOf renders v as compact JSON with sorted keys
+func jsonOf(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSchemaReflection(t *testing.T) {
+	s := newSchemas()
+	if got := jsonOf(t, s.of([]product{})); got != `{"items":{"$ref":"#/components/schemas/Product"},"type":"array"}` {
+		t.Errorf("slice schema = %s", got)
+	}
+
+	props := s.components["Product"]["properties"].(Schema)
+	for field, want := range map[string]string{
+		"id":         `{"type":"string"}`,
+		"created_at": `{"format":"date-time","type":"string"}`,
+		"name":       `{"minLength":3,"type":"string"}`,
+		"price":      `{"exclusiveMinimum":0,"type":"number"}`,
+		"tags":       `{"items":{"type":"string"},"maxItems":5,"type":"array"}`,
+		"email":      `{"format":"email","type":"string"}`,
+		"status":     `{"enum":["draft","live"],"type":"string"}`,
+		"steps":      `{"maximum":60,"minimum":1,"type":"integer"}`,
+		"deleted_at": `{"format":"date-time","type":["string","null"]}`,
+		"labels":     `{"additionalProperties":{"type":"string"},"type":"object"}`,
+		"parent":     `{"anyOf":[{"$ref":"#/components/schemas/Product"},{"type":"null"}]}`,
+		"extra":      `{}`,
+		"count":      `{"type":"string"}`,
+	} {
+		if got := jsonOf(t, props[field]); got != want {
+			t.Errorf("%s = %s, want %s", field, got, want)
+		}
+	}
+	for _, field := range []string{"hidden", "Skipped", "base"} {
+		if _, ok := props[field]; ok {
+			t.Errorf("unexpected property %s", field)
+		}
+	}
+	if got := jsonOf(t, s.components["Product"]["required"]); got != `["name","price"]` {
+		t.Errorf("required = %s", got)
+	}
+}
+
+func TestSchemaPassThrough(t *testing.T) {
+	s := newSchemas()
+	want := Schema{"type": "string", "contentMediaType": "image/png"}
+	if got := s.of(want); jsonOf(t, got) != jsonOf(t, want) {
+		t.Errorf("Schema value = %v", got)
+	}
+	if s.of(nil) != nil {
+		t.Error("nil value produced a schema")
+	}
+}
+
+func TestComponentNameClash(t *testing.T) {
+	type Product struct {
+		SKU string `json:"sku"`
+	}
+	s := newSchemas()
+	s.of(product{})
+	s.of(Product{})
+	if len(s.components) != 2 {
+		t.Fatalf("components = %v", s.components)
+	}
+	if _, ok := s.components["OpenapiProduct"]; !ok {
+		t.Errorf("clashing name not qualified: %v", s.components)
+	}
+}
@@ -0,0 +1,120 @@
+// Package server runs an http.Handler with sensible timeouts and a graceful
+// shutdown: on SIGINT, SIGTERM or a cancelled context it stops accepting
+// connections and lets in-flight requests finish until a drain deadline.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Config holds the listen address and every timeout of a server.
+// Zero durations are replaced by the defaults from DefaultConfig.
+type Config struct {
+	Addr string
+
+	ReadHeaderTimeout time.Duration // time to read the request headers
+	ReadTimeout       time.Duration // time to read the whole request, body included
+	WriteTimeout      time.Duration // time from the end of the headers to the end of the response
+	IdleTimeout       time.Duration // how long a keep-alive connection waits for the next request
+
+	// HandlerTimeout bounds each request. When it passes the request context is
+	// cancelled and the client gets 503. Negative disables it.
+	HandlerTimeout time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish after a shutdown starts
+	ShutdownTimeout time.Duration
+}
+
+// DefaultConfig returns a Config for addr with conservative timeouts
+func DefaultConfig(addr string) Config {
+	return Config{
+		Addr:              addr,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		HandlerTimeout:    25 * time.Second,
+		ShutdownTimeout:   20 * time.Second,
+	}
+}
+
+// withDefaults fills zero fields from DefaultConfig
+func (c Config) withDefaults() Config {
+	d := DefaultConfig(c.Addr)
+	for _, f := range []struct{ v, def *time.Duration }{
+		{&c.ReadHeaderTimeout, &d.ReadHeaderTimeout},
+		{&c.ReadTimeout, &d.ReadTimeout},
+		{&c.WriteTimeout, &d.WriteTimeout},
+		{&c.IdleTimeout, &d.IdleTimeout},
+		{&c.HandlerTimeout, &d.HandlerTimeout},
+		{&c.ShutdownTimeout, &d.ShutdownTimeout},
+	} {
+		if *f.v == 0 {
+			*f.v = *f.def
+		}
+	}
+	return c
+}
+
+// ListenAndServe serves handler on cfg.Addr until SIGINT or SIGTERM, then
+// drains in-flight requests. It returns nil after a clean shutdown.
+func ListenAndServe(cfg Config, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Run(ctx, cfg, handler)
+}
+
+// Run listens on cfg.Addr and serves handler until ctx is done, then drains
+func Run(ctx context.Context, cfg Config, handler http.Handler) error {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, ln, cfg, handler)
+}
+
+// Serve serves handler on ln until ctx is done. It then stops accepting
+// connections and waits up to cfg.ShutdownTimeout for in-flight requests;
+// requests still running after that are cut off and Serve returns an error
+// wrapping context.DeadlineExceeded.
+func Serve(ctx context.Context, ln net.Listener, cfg Config, handler http.Handler) error {
+	cfg = cfg.withDefaults()
+	if cfg.HandlerTimeout > 0 {
+		handler = http.TimeoutHandler(handler, cfg.HandlerTimeout, "request timed out")
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		// Cut off whatever is still running so handlers see their context cancelled
+		srv.Close()
+		return fmt.Errorf("server: drain did not finish within %v: %w", cfg.ShutdownTimeout, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
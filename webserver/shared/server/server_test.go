@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// start serves handler on a random port and returns its URL, the function that
+// triggers shutdown and the channel Serve's result arrives on
+func start(t *testing.T, cfg Config, handler http.Handler) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, ln, cfg, handler) }()
+	t.Cleanup(cancel)
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestSlowRequestFinishesDuringShutdown(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-time.After(300 * time.Millisecond):
+			io.WriteString(w, "finished")
+		case <-r.Context().Done():
+			t.Error("in-flight request was cancelled by the shutdown")
+		}
+	})
+	url, shutdown, done := start(t, Config{ShutdownTimeout: 5 * time.Second}, handler)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		resc <- result{string(body), err}
+	}()
+
+	<-started
+	shutdown()
+
+	// New connections are refused while the slow request drains
+	time.Sleep(50 * time.Millisecond)
+	if _, err := net.DialTimeout("tcp", url[len("http://"):], 100*time.Millisecond); err == nil {
+		t.Error("server still accepting connections during shutdown")
+	}
+
+	res := <-resc
+	if res.err != nil || res.body != "finished" {
+		t.Errorf("slow request = %q, %v, want finished", res.body, res.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve() = %v, want nil after a clean drain", err)
+	}
+}
+
+func TestCancelledClientAbortsHandler(t *testing.T) {
+	started := make(chan struct{})
+	aborted := make(chan time.Duration, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		begin := time.Now()
+		close(started)
+		select {
+		case <-time.After(10 * time.Second):
+			io.WriteString(w, "finished")
+		case <-r.Context().Done():
+			aborted <- time.Since(begin)
+		}
+	})
+	url, _, _ := start(t, Config{}, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("client error = %v, want context.Canceled", err)
+	}
+
+	select {
+	case d := <-aborted:
+		if d > time.Second {
+			t.Errorf("handler took %v to notice the client went away", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept working after the client cancelled")
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done() // only ends when the connection is cut
+	})
+	url, shutdown, done := start(t, Config{ShutdownTimeout: 50 * time.Millisecond}, handler)
+
+	go func() {
+		// The request outlives the drain deadline, so it fails
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	shutdown()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Serve() = %v, want a drain deadline error", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not give up after the drain deadline")
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	url, _, _ := start(t, Config{HandlerTimeout: 50 * time.Millisecond}, handler)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := Config{Addr: ":0", WriteTimeout: time.Minute, HandlerTimeout: -1}.withDefaults()
+	def := DefaultConfig(":0")
+	if cfg.WriteTimeout != time.Minute || cfg.HandlerTimeout != -1 {
+		t.Errorf("explicit values overwritten: %+v", cfg)
+	}
+	if cfg.ReadHeaderTimeout != def.ReadHeaderTimeout || cfg.ShutdownTimeout != def.ShutdownTimeout {
+		t.Errorf("zero values not defaulted: %+v", cfg)
+	}
+}
@@ -0,0 +1,71 @@
+package users
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etag is the strong entity tag of a user. Versions only ever go up and IDs
+// are never reused, so the version alone identifies a representation.
+func etag(u User) string {
+	return `"` + strconv.Itoa(u.Version) + `"`
+}
+
+// setValidators adds the ETag and Last-Modified headers for u
+func setValidators(w http.ResponseWriter, u User) {
+	w.Header().Set("ETag", etag(u))
+	w.Header().Set("Last-Modified", u.UpdatedAt.UTC().Format(http.TimeFormat))
+}
+
+// matchETag reports whether tag is listed in an If-Match or If-None-Match
+// header value. "*" matches anything. With weak set, W/ prefixes are ignored
+// (the weak comparison If-None-Match uses); otherwise weak tags never match.
+func matchETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether a GET for u can be answered with 304.
+// If-None-Match takes precedence over If-Modified-Since, as RFC 9110 requires.
+func notModified(r *http.Request, u User) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, etag(u), true)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		// Last-Modified only has second precision
+		return err == nil && !u.UpdatedAt.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// ifVersion resolves the request's If-Match header against the current user.
+// It returns the version a write must still find (0 when there is no
+// If-Match), or false after answering 412 when the header doesn't match.
+func ifVersion(w http.ResponseWriter, r *http.Request, current User) (int, bool) {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		return 0, true
+	}
+	if !matchETag(im, etag(current), false) {
+		writeError(w, http.StatusPreconditionFailed, "user has been modified; fetch it again")
+		return 0, false
+	}
+	return current.Version, true
+}
@@ -0,0 +1,300 @@
+package users
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultCompactEvery is how many log records File appends before it writes a
+// snapshot and truncates the log
+const DefaultCompactEvery = 1000
+
+// record is one line of the log
+type record struct {
+	Op   string `json:"op"` // "put" or "delete"
+	ID   int    `json:"id"`
+	User *User  `json:"user,omitempty"`
+}
+
+// snapshot is the content of the snapshot file
+type snapshot struct {
+	NextID int    `json:"next_id"`
+	Users  []User `json:"users"`
+}
+
+// File is a Repository persisted to disk. Every change is appended to a
+// JSON-lines log at path and synced before it takes effect. Once the log
+// reaches CompactEvery records the full state, including the ID counter, is
+// written to path+".snapshot" and the log starts over.
+type File struct {
+	// CompactEvery overrides DefaultCompactEvery. Set it before first use.
+	CompactEvery int
+
+	path     string
+	mem      *Memory // guarded by mem.mu, which also serialises log writes
+	log      *os.File
+	size     int64 // length of the log
+	appended int
+	// failed is set when a failed write could not be undone; every later
+	// change fails with it
+	failed error
+}
+
+// OpenFile loads the snapshot and log at path, creating them if needed
+func OpenFile(path string) (*File, error) {
+	f := &File{path: path, mem: NewMemory()}
+	valid, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop a torn tail so new records don't get glued onto it
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return nil, err
+	}
+	f.log, f.size = log, valid
+	return f, nil
+}
+
+func (f *File) snapshotPath() string {
+	return f.path + ".snapshot"
+}
+
+// load restores the snapshot and replays the log on top of it. Records are
+// idempotent, so replaying a log that the snapshot already covers (after a
+// crash between writing the snapshot and truncating the log) is harmless. A
+// torn final line is ignored; a corrupt line anywhere else is an error. load
+// returns the length of the log up to the last complete line.
+func (f *File) load() (int64, error) {
+	data, err := os.ReadFile(f.snapshotPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return 0, err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return 0, fmt.Errorf("users: %s: %w", f.snapshotPath(), err)
+		}
+		for _, u := range snap.Users {
+			f.mem.put(u)
+		}
+		if snap.NextID > f.mem.nextID {
+			f.mem.nextID = snap.NextID
+		}
+	}
+
+	log, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer log.Close()
+
+	var valid int64
+	r := bufio.NewReader(log)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var rec record
+		if err := json.Unmarshal(bytes.TrimSpace(data), &rec); err != nil {
+			return 0, fmt.Errorf("users: %s line %d: %w", f.path, line, err)
+		}
+		f.apply(rec)
+		f.appended++
+		valid += int64(len(data))
+	}
+}
+
+// apply updates the in-memory state. Callers must hold mem.mu.
+func (f *File) apply(rec record) {
+	switch rec.Op {
+	case "put":
+		if rec.User != nil {
+			f.mem.put(*rec.User)
+		}
+	case "delete":
+		delete(f.mem.users, rec.ID)
+		if rec.ID >= f.mem.nextID {
+			f.mem.nextID = rec.ID + 1
+		}
+	}
+}
+
+// append durably writes rec, applies it and compacts when the log is long
+// enough. Callers must hold mem.mu.
+func (f *File) append(rec record) error {
+	if f.log == nil {
+		return os.ErrClosed
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := f.write(append(data, '\n')); err != nil {
+		return err
+	}
+	f.apply(rec)
+	f.appended++
+
+	limit := f.CompactEvery
+	if limit <= 0 {
+		limit = DefaultCompactEvery
+	}
+	if f.appended >= limit {
+		return f.compact()
+	}
+	return nil
+}
+
+// write appends line to the log and syncs it. If either fails the log is cut
+// back to its previous length, so a change reported as failed is not
+// replayed on the next start. If that fails too the File refuses every later
+// change. Callers must hold mem.mu.
+func (f *File) write(line []byte) error {
+	if f.failed != nil {
+		return f.failed
+	}
+	_, err := f.log.Write(line)
+	if err == nil {
+		err = f.log.Sync()
+	}
+	if err == nil {
+		f.size += int64(len(line))
+		return nil
+	}
+	if undo := f.truncate(f.size); undo != nil {
+		f.failed = fmt.Errorf("users: %s may hold a failed write: %w", f.path, errors.Join(err, undo))
+	}
+	return err
+}
+
+// truncate cuts the log to size bytes and syncs it
+func (f *File) truncate(size int64) error {
+	if err := f.log.Truncate(size); err != nil {
+		return err
+	}
+	return f.log.Sync()
+}
+
+// compact writes a snapshot of the current state and truncates the log.
+// Callers must hold mem.mu.
+func (f *File) compact() error {
+	data, err := json.Marshal(snapshot{NextID: f.mem.nextID, Users: f.mem.sorted()})
+	if err != nil {
+		return err
+	}
+
+	tmp := f.snapshotPath() + ".tmp"
+	snap, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := snap.Write(data); err != nil {
+		snap.Close()
+		return err
+	}
+	if err := snap.Sync(); err != nil {
+		snap.Close()
+		return err
+	}
+	if err := snap.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.snapshotPath()); err != nil {
+		return err
+	}
+
+	if err := f.truncate(0); err != nil {
+		return err
+	}
+	f.size, f.appended = 0, 0
+	return nil
+}
+
+// Create implements Repository
+func (f *File) Create(u User) (User, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	u = f.mem.created(u)
+	if err := f.append(record{Op: "put", ID: u.ID, User: &u}); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// Get implements Repository
+func (f *File) Get(id int) (User, error) {
+	return f.mem.Get(id)
+}
+
+// Update implements Repository
+func (f *File) Update(u User, ifVersion int) (User, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	u, err := f.mem.updated(u, ifVersion)
+	if err != nil {
+		return User{}, err
+	}
+	if err := f.append(record{Op: "put", ID: u.ID, User: &u}); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// Delete implements Repository
+func (f *File) Delete(id int, ifVersion int) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if _, err := f.mem.current(id, ifVersion); err != nil {
+		return err
+	}
+	return f.append(record{Op: "delete", ID: id})
+}
+
+// List implements Repository
+func (f *File) List(q Query) (Page, error) {
+	return f.mem.List(q)
+}
+
+// Compact writes a snapshot and truncates the log straight away
+func (f *File) Compact() error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if f.log == nil {
+		return os.ErrClosed
+	}
+	return f.compact()
+}
+
+// Close compacts the log and closes the file. The File must not be used afterwards.
+func (f *File) Close() error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if f.log == nil {
+		return os.ErrClosed
+	}
+	err := f.compact()
+	if cerr := f.log.Close(); err == nil {
+		err = cerr
+	}
+	f.log = nil
+	return err
+}
@@ -0,0 +1,297 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"shared/validate"
+	"strconv"
+	"strings"
+)
+
+// maxBodyBytes caps the size of user request bodies
+const maxBodyBytes = 1 << 20
+
+// Endpoint is one route of the users API. Adapters register Serve with
+// their router under Method and Path, passing the {id} path segment, so
+// every server answers with the same statuses and bodies.
+type Endpoint struct {
+	Method string
+	Path   string // "/users" or "/users/{id}", relative to where the API is mounted
+	// Serve handles the request; id is the {id} segment, empty for /users
+	Serve func(w http.ResponseWriter, r *http.Request, id string)
+}
+
+// Endpoints returns the users API served from repo
+func Endpoints(repo Repository) []Endpoint {
+	h := &handlers{users: repo}
+	return []Endpoint{
+		{http.MethodGet, "/users", h.listUsers},
+		{http.MethodPost, "/users", h.createUser},
+		{http.MethodGet, "/users/{id}", h.getUser},
+		{http.MethodPut, "/users/{id}", h.replaceUser},
+		{http.MethodPatch, "/users/{id}", h.patchUser},
+		{http.MethodDelete, "/users/{id}", h.deleteUser},
+	}
+}
+
+// handlers serves the endpoints from the repository it was given
+type handlers struct {
+	users Repository
+}
+
+// listUsers returns a page of users. Query parameters:
+// user (name contains, ignoring case), sort (id, user, -id, -user), limit and
+// cursor (the next_cursor of the previous page).
+func (h *handlers) listUsers(w http.ResponseWriter, r *http.Request, _ string) {
+	params := r.URL.Query()
+	q := Query{
+		User:   params.Get("user"),
+		Sort:   params.Get("sort"),
+		Cursor: params.Get("cursor"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "limit must be an integer")
+			return
+		}
+		q.Limit = n
+	}
+
+	page, err := h.users.List(q)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// createUser creates a new user and answers with it and its Location
+func (h *handlers) createUser(w http.ResponseWriter, r *http.Request, _ string) {
+	user, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+
+	// The repository allocates the ID, so it is never reused after a delete
+	user, err := h.users.Create(user)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	// Relative to the request, so the API can be mounted under a prefix
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.Itoa(user.ID)))
+	setValidators(w, user)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// getUser retrieves a user by ID from the repository. It answers 304
+// when If-None-Match or If-Modified-Since show the client's copy is current.
+func (h *handlers) getUser(w http.ResponseWriter, r *http.Request, rawID string) {
+	id, ok := parseID(w, rawID)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	setValidators(w, user)
+	if notModified(r, user) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// replaceUser replaces every field of an existing user. With If-Match
+// the write only happens if the user still has that ETag.
+func (h *handlers) replaceUser(w http.ResponseWriter, r *http.Request, rawID string) {
+	id, ok := parseID(w, rawID)
+	if !ok {
+		return
+	}
+	user, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID != 0 && user.ID != id {
+		writeError(w, http.StatusBadRequest, "id in the body does not match the URL")
+		return
+	}
+	user.ID = id
+
+	current, err := h.users.Get(id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	version, ok := ifVersion(w, r, current)
+	if !ok {
+		return
+	}
+	// The repository re-checks the version atomically with the write
+	user, err = h.users.Update(user, version)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	setValidators(w, user)
+	writeJSON(w, http.StatusOK, user)
+}
+
+// patchUser applies a JSON Merge Patch (application/merge-patch+json)
+// to a user. The patch is always applied to the version it was computed from:
+// with If-Match a concurrent change answers 412, without it the patch is retried.
+func (h *handlers) patchUser(w http.ResponseWriter, r *http.Request, rawID string) {
+	id, ok := parseID(w, rawID)
+	if !ok {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/merge-patch+json" {
+		writeError(w, http.StatusUnsupportedMediaType, "PATCH requires Content-Type application/merge-patch+json")
+		return
+	}
+
+	var patch any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&patch); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, err := h.users.Get(id)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		version, ok := ifVersion(w, r, current)
+		if !ok {
+			return
+		}
+
+		user, err := applyMergePatch(current, patch)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := validate.Struct(user); err != nil {
+			validate.Respond(w, err)
+			return
+		}
+
+		user, err = h.users.Update(user, current.Version)
+		if errors.Is(err, ErrVersionConflict) && version == 0 && attempt < maxPatchAttempts {
+			continue
+		}
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		setValidators(w, user)
+		writeJSON(w, http.StatusOK, user)
+		return
+	}
+}
+
+// maxPatchAttempts bounds how often an unconditional PATCH is retried after losing a race
+const maxPatchAttempts = 3
+
+// applyMergePatch returns current with patch applied, after checking that the
+// result is still a valid user with the same ID
+func applyMergePatch(current User, patch any) (User, error) {
+	// Round-trip through a generic document so the patch sees the JSON field names
+	var doc any
+	data, _ := json.Marshal(current)
+	json.Unmarshal(data, &doc)
+	data, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&user); err != nil {
+		return User{}, fmt.Errorf("patched user is invalid: %w", err)
+	}
+	if user.ID != current.ID {
+		return User{}, errors.New("id cannot be changed")
+	}
+	return user, nil
+}
+
+// deleteUser removes a user from the repository by ID, honouring If-Match
+func (h *handlers) deleteUser(w http.ResponseWriter, r *http.Request, rawID string) {
+	id, ok := parseID(w, rawID)
+	if !ok {
+		return
+	}
+
+	current, err := h.users.Get(id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	version, ok := ifVersion(w, r, current)
+	if !ok {
+		return
+	}
+	if err := h.users.Delete(id, version); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseID parses the {id} path segment, answering 400 when it is not an integer
+func parseID(w http.ResponseWriter, rawID string) (int, bool) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "user id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeUser reads a User from the request body, rejecting unknown fields,
+// and answers 422 when it fails validation
+func decodeUser(w http.ResponseWriter, r *http.Request) (User, bool) {
+	var user User
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&user); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return User{}, false
+	}
+	if err := validate.Struct(user); err != nil {
+		validate.Respond(w, err)
+		return User{}, false
+	}
+	return user, true
+}
+
+// writeStoreError maps repository errors to status codes. Unexpected errors
+// are logged rather than sent, since they can carry file paths and other
+// server details.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, ErrVersionConflict):
+		writeError(w, http.StatusPreconditionFailed, "user has been modified; fetch it again")
+	case errors.Is(err, ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, strings.TrimPrefix(err.Error(), "users: "))
+	default:
+		slog.ErrorContext(r.Context(), "users: repository error", "method", r.Method, "path", r.URL.Path, "err", err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -0,0 +1,66 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// brokenRepo fails every Create with an error naming a server path
+type brokenRepo struct {
+	*Memory
+}
+
+func (brokenRepo) Create(User) (User, error) {
+	return User{}, errors.New("write /var/lib/users.log: no space left on device")
+}
+
+func TestStoreErrorIsNotSent(t *testing.T) {
+	var create Endpoint
+	for _, e := range Endpoints(brokenRepo{NewMemory()}) {
+		if e.Method == http.MethodPost {
+			create = e
+		}
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"user":"a"}`))
+	r.Header.Set("Content-Type", "application/json")
+	create.Serve(w, r, "")
+
+	var body ErrorBody
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusInternalServerError || body.Error != "internal server error" {
+		t.Errorf("POST /users = %d %s, want 500 with a generic message", w.Code, w.Body)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 appendix A
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		var target, patch any
+		json.Unmarshal([]byte(tt.target), &target)
+		json.Unmarshal([]byte(tt.patch), &patch)
+		got, _ := json.Marshal(mergePatch(target, patch))
+		if string(got) != tt.want {
+			t.Errorf("mergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
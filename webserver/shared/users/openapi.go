@@ -0,0 +1,116 @@
+package users
+
+import (
+	"net/http"
+	"shared/openapi"
+	"shared/validate"
+)
+
+// Describe documents the users API mounted at prefix in spec. wrap, if not
+// nil, adjusts each operation before it's added, such as to mark the
+// routes the server protects.
+func Describe(spec *openapi.Spec, prefix string, wrap func(method string, op openapi.Operation) openapi.Operation) {
+	describe := func(method, path string, op openapi.Operation) {
+		if wrap != nil {
+			op = wrap(method, op)
+		}
+		spec.Describe(method, prefix+path, op)
+	}
+
+	id := openapi.Parameter{Name: "id", In: "path", Description: "User ID", Schema: 0}
+	ifMatch := openapi.Parameter{Name: "If-Match", In: "header", Description: "Only write if the user still has this ETag"}
+	validators := map[string]string{"ETag": "Version of the user", "Last-Modified": "When the user last changed"}
+	errorResponse := func(desc string) openapi.Response {
+		return openapi.Response{Description: desc, Body: ErrorBody{}}
+	}
+	invalid := openapi.Response{Description: "The user fails validation", Body: validate.Response{}}
+	notFound := errorResponse("No such user")
+	conflict := errorResponse("If-Match doesn't match the user's ETag")
+
+	describe(http.MethodGet, "/users", openapi.Operation{
+		ID:      "listUsers",
+		Summary: "List users a page at a time",
+		Tags:    []string{"users"},
+		Parameters: []openapi.Parameter{
+			{Name: "user", In: "query", Description: "Keep users whose name contains this, ignoring case"},
+			{Name: "sort", In: "query", Description: "id (the default) or user, prefixed with - for descending",
+				Schema: openapi.Schema{"type": "string", "enum": []string{"id", "-id", "user", "-user"}}},
+			{Name: "limit", In: "query", Description: "Page size",
+				Schema: openapi.Schema{"type": "integer", "minimum": 1, "maximum": MaxLimit, "default": DefaultLimit}},
+			{Name: "cursor", In: "query", Description: "next_cursor of the previous page"},
+		},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:         {Body: Page{}},
+			http.StatusBadRequest: errorResponse("Invalid query"),
+		},
+	})
+	describe(http.MethodPost, "/users", openapi.Operation{
+		ID:      "createUser",
+		Summary: "Create a user",
+		Tags:    []string{"users"},
+		Body:    User{},
+		Responses: map[int]openapi.Response{
+			http.StatusCreated: {Body: User{}, Headers: map[string]string{
+				"Location": "URL of the new user", "ETag": validators["ETag"], "Last-Modified": validators["Last-Modified"],
+			}},
+			http.StatusBadRequest:          errorResponse("Malformed JSON or unknown fields"),
+			http.StatusUnprocessableEntity: invalid,
+		},
+	})
+	describe(http.MethodGet, "/users/{id}", openapi.Operation{
+		ID:      "getUser",
+		Summary: "Get a user",
+		Tags:    []string{"users"},
+		Parameters: []openapi.Parameter{id,
+			{Name: "If-None-Match", In: "header", Description: "Answer 304 if the user still has one of these ETags"},
+			{Name: "If-Modified-Since", In: "header", Description: "Answer 304 if the user hasn't changed since"},
+		},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:          {Body: User{}, Headers: validators},
+			http.StatusNotModified: {Description: "The client's copy is current", Headers: validators},
+			http.StatusNotFound:    notFound,
+		},
+	})
+	describe(http.MethodPut, "/users/{id}", openapi.Operation{
+		ID:         "replaceUser",
+		Summary:    "Replace a user",
+		Tags:       []string{"users"},
+		Parameters: []openapi.Parameter{id, ifMatch},
+		Body:       User{},
+		Responses: map[int]openapi.Response{
+			http.StatusOK:                  {Body: User{}, Headers: validators},
+			http.StatusBadRequest:          errorResponse("Malformed JSON, or an id that doesn't match the URL"),
+			http.StatusNotFound:            notFound,
+			http.StatusPreconditionFailed:  conflict,
+			http.StatusUnprocessableEntity: invalid,
+		},
+	})
+	describe(http.MethodPatch, "/users/{id}", openapi.Operation{
+		ID:          "patchUser",
+		Summary:     "Update a user with a JSON Merge Patch",
+		Description: "Without If-Match the patch is retried against concurrent changes.",
+		Tags:        []string{"users"},
+		Parameters:  []openapi.Parameter{id, ifMatch},
+		Body:        openapi.Schema{"type": "object"},
+		BodyType:    "application/merge-patch+json",
+		Responses: map[int]openapi.Response{
+			http.StatusOK:                   {Body: User{}, Headers: validators},
+			http.StatusBadRequest:           errorResponse("Malformed JSON, or a patch that changes the id"),
+			http.StatusNotFound:             notFound,
+			http.StatusPreconditionFailed:   conflict,
+			http.StatusUnsupportedMediaType: errorResponse("The body isn't application/merge-patch+json"),
+			http.StatusUnprocessableEntity:  invalid,
+		},
+	})
+	describe(http.MethodDelete, "/users/{id}", openapi.Operation{
+		ID:         "deleteUser",
+		Summary:    "Delete a user",
+		Tags:       []string{"users"},
+		Parameters: []openapi.Parameter{id, ifMatch},
+		Responses: map[int]openapi.Response{
+			http.StatusNoContent:          {Description: "Deleted"},
+			http.StatusNotFound:           notFound,
+			http.StatusPreconditionFailed: conflict,
+		},
+	})
+}
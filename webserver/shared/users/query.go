@@ -0,0 +1,135 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Page size limits for List
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidQuery is returned by List for an unknown sort order or a bad cursor
+var ErrInvalidQuery = errors.New("users: invalid query")
+
+// Query selects, orders and pages the users returned by List
+type Query struct {
+	User   string // keep users whose name contains this, ignoring case
+	Sort   string // "id" (the default), "user", or either prefixed with "-" for descending
+	Limit  int    // page size; 0 means DefaultLimit and anything above MaxLimit is capped
+	Cursor string // NextCursor of the previous page, empty for the first page
+}
+
+// Page is one page of List results
+type Page struct {
+	Users []User `json:"users"`
+	// NextCursor fetches the following page; it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursor is the position after the last user of a page. It carries the sort
+// key as well as the ID, so paging stays stable while users are added or removed.
+type cursor struct {
+	Sort string `json:"s"`
+	User string `json:"u,omitempty"`
+	ID   int    `json:"i"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return c, nil
+}
+
+// lessFunc returns the ordering for sortBy, with ID breaking ties so the order is total
+func lessFunc(sortBy string) (func(a, b User) bool, error) {
+	desc := strings.HasPrefix(sortBy, "-")
+	var less func(a, b User) bool
+	switch strings.TrimPrefix(sortBy, "-") {
+	case "id":
+		less = func(a, b User) bool { return a.ID < b.ID }
+	case "user":
+		less = func(a, b User) bool {
+			if a.User != b.User {
+				return a.User < b.User
+			}
+			return a.ID < b.ID
+		}
+	default:
+		return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, sortBy)
+	}
+	if desc {
+		return func(a, b User) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+// list applies q to users, which the caller owns
+func list(users []User, q Query) (Page, error) {
+	if q.Sort == "" {
+		q.Sort = "id"
+	}
+	less, err := lessFunc(q.Sort)
+	if err != nil {
+		return Page{}, err
+	}
+	switch {
+	case q.Limit < 0:
+		return Page{}, fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit == 0:
+		q.Limit = DefaultLimit
+	case q.Limit > MaxLimit:
+		q.Limit = MaxLimit
+	}
+
+	matched := users[:0]
+	needle := strings.ToLower(q.User)
+	for _, u := range users {
+		if strings.Contains(strings.ToLower(u.User), needle) {
+			matched = append(matched, u)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if c.Sort != q.Sort {
+			return Page{}, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidQuery, c.Sort)
+		}
+		after := User{ID: c.ID, User: c.User}
+		start := sort.Search(len(matched), func(i int) bool { return less(after, matched[i]) })
+		matched = matched[start:]
+	}
+
+	page := Page{Users: matched}
+	if len(matched) > q.Limit {
+		page.Users = matched[:q.Limit]
+		last := page.Users[len(page.Users)-1]
+		c := cursor{Sort: q.Sort, ID: last.ID}
+		if strings.TrimPrefix(q.Sort, "-") == "user" {
+			c.User = last.User
+		}
+		page.NextCursor = c.encode()
+	}
+	return page, nil
+}
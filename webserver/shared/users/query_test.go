@@ -0,0 +1,106 @@
+package users
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func names(users []User) []string {
+	out := make([]string, len(users))
+	for i, u := range users {
+		out[i] = u.User
+	}
+	return out
+}
+
+// collect follows NextCursor until the last page and returns the names of every page
+func collect(t *testing.T, repo Repository, q Query) [][]string {
+	t.Helper()
+	var pages [][]string
+	for {
+		page, err := repo.List(q)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", q, err)
+		}
+		pages = append(pages, names(page.Users))
+		if page.NextCursor == "" {
+			return pages
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestList(t *testing.T) {
+	repo := NewMemory()
+	for _, name := range []string{"dave", "alice", "Carol", "bob", "alice", "carl"} {
+		repo.Create(User{User: name})
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want [][]string
+	}{
+		{"Default order", Query{}, [][]string{{"dave", "alice", "Carol", "bob", "alice", "carl"}}},
+		{"Pages by ID", Query{Limit: 4}, [][]string{{"dave", "alice", "Carol", "bob"}, {"alice", "carl"}}},
+		{"Exact final page", Query{Limit: 3}, [][]string{{"dave", "alice", "Carol"}, {"bob", "alice", "carl"}}},
+		{"Descending ID", Query{Sort: "-id", Limit: 5}, [][]string{{"carl", "alice", "bob", "Carol", "alice"}, {"dave"}}},
+		{"By name with duplicate keys", Query{Sort: "user", Limit: 1}, [][]string{{"Carol"}, {"alice"}, {"alice"}, {"bob"}, {"carl"}, {"dave"}}},
+		{"By name descending", Query{Sort: "-user", Limit: 2}, [][]string{{"dave", "carl"}, {"bob", "alice"}, {"alice", "Carol"}}},
+		{"Filter ignores case", Query{User: "CAR", Sort: "user"}, [][]string{{"Carol", "carl"}}},
+		{"Filter with no match", Query{User: "zed"}, [][]string{{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := collect(t, repo, tt.q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCursorSurvivesChanges(t *testing.T) {
+	repo := NewMemory()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		repo.Create(User{User: name})
+	}
+	first, _ := repo.List(Query{Limit: 2})
+
+	// Removing the last user of the page must not make the next page skip or repeat anyone
+	repo.Delete(2, 0)
+	repo.Create(User{User: "e"})
+	next, err := repo.List(Query{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(next.Users); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("next page = %q, want [c d]", got)
+	}
+}
+
+func TestListRejectsBadQueries(t *testing.T) {
+	repo := NewMemory()
+	for _, name := range []string{"a", "b"} {
+		repo.Create(User{User: name})
+	}
+	page, _ := repo.List(Query{Limit: 1})
+
+	tests := []struct {
+		name string
+		q    Query
+	}{
+		{"Unknown sort", Query{Sort: "email"}},
+		{"Negative limit", Query{Limit: -1}},
+		{"Garbage cursor", Query{Cursor: "not a cursor"}},
+		{"Cursor from another sort", Query{Sort: "user", Cursor: page.NextCursor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.List(tt.q); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("List(%+v) error = %v, want ErrInvalidQuery", tt.q, err)
+			}
+		})
+	}
+}
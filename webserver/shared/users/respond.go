@@ -0,0 +1,45 @@
+package users
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorBody is the JSON body of every error response
+type ErrorBody struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// writeJSON sends v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a JSON error body in place of http.Error's plain text
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorBody{Error: message, Status: status})
+}
+
+// mergePatch applies a JSON Merge Patch (RFC 7396) to target. Objects merge
+// key by key, null removes a key, and any other value replaces the target outright.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
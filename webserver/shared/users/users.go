@@ -0,0 +1,163 @@
+// Package users is the users API shared by the web servers: its domain
+// types, storage, validation and errors, and its HTTP endpoints in a
+// router-neutral form that each server binds with a thin adapter. The
+// userstest package holds the contract every adapter must pass.
+//
+// Repository is the storage abstraction the endpoints depend on. Memory keeps
+// users in a map and File adds durability with a JSON-lines log and periodic
+// snapshots. Both hand out IDs from a counter that never goes backwards, so a
+// deleted user's ID is never given to somebody else.
+//
+// Every write bumps the user's Version and UpdatedAt. Update and Delete take
+// the version the caller last saw and fail with ErrVersionConflict if the user
+// has changed since, which is what conditional HTTP requests are built on.
+package users
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when no user has the requested ID
+var ErrNotFound = errors.New("users: user not found")
+
+// ErrVersionConflict is returned when a write expects a version the user no longer has
+var ErrVersionConflict = errors.New("users: user was modified concurrently")
+
+// User is a stored user. ID, Version and UpdatedAt are managed by the
+// repository; values passed in by callers are ignored.
+type User struct {
+	ID        int       `json:"id"`
+	User      string    `json:"user" validate:"required,max=100"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Repository stores users. Implementations are safe for concurrent use.
+type Repository interface {
+	// Create stores u under a newly allocated ID and returns the stored user
+	Create(u User) (User, error)
+	// Get returns the user with id, or ErrNotFound
+	Get(id int) (User, error)
+	// Update replaces the user with u.ID and returns the stored user. A
+	// non-zero ifVersion must equal the current version.
+	Update(u User, ifVersion int) (User, error)
+	// Delete removes the user with id. A non-zero ifVersion must equal the current version.
+	Delete(id int, ifVersion int) error
+	// List returns one page of the users matching q
+	List(q Query) (Page, error)
+}
+
+// Memory is an in-memory Repository. The zero value is not usable; call NewMemory.
+type Memory struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[int]User
+}
+
+// NewMemory creates an empty in-memory repository whose first ID is 1
+func NewMemory() *Memory {
+	return &Memory{nextID: 1, users: make(map[int]User)}
+}
+
+// Create implements Repository
+func (m *Memory) Create(u User) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u = m.created(u)
+	m.put(u)
+	return u, nil
+}
+
+// Get implements Repository
+func (m *Memory) Get(id int) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// Update implements Repository
+func (m *Memory) Update(u User, ifVersion int) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.updated(u, ifVersion)
+	if err != nil {
+		return User{}, err
+	}
+	m.put(u)
+	return u, nil
+}
+
+// Delete implements Repository
+func (m *Memory) Delete(id int, ifVersion int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.current(id, ifVersion); err != nil {
+		return err
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// List implements Repository
+func (m *Memory) List(q Query) (Page, error) {
+	m.mu.RLock()
+	users := m.sorted()
+	m.mu.RUnlock()
+	return list(users, q)
+}
+
+// current returns the user with id, checking ifVersion. Callers must hold mu.
+func (m *Memory) current(id int, ifVersion int) (User, error) {
+	cur, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	if ifVersion != 0 && cur.Version != ifVersion {
+		return User{}, ErrVersionConflict
+	}
+	return cur, nil
+}
+
+// created stamps u as the first version of a new user. Callers must hold mu.
+func (m *Memory) created(u User) User {
+	u.ID = m.nextID
+	u.Version = 1
+	u.UpdatedAt = time.Now().UTC()
+	return u
+}
+
+// updated stamps u as the next version of an existing user. Callers must hold mu.
+func (m *Memory) updated(u User, ifVersion int) (User, error) {
+	cur, err := m.current(u.ID, ifVersion)
+	if err != nil {
+		return User{}, err
+	}
+	u.Version = cur.Version + 1
+	u.UpdatedAt = time.Now().UTC()
+	return u, nil
+}
+
+// put stores u and moves the ID counter past it. Callers must hold mu.
+func (m *Memory) put(u User) {
+	m.users[u.ID] = u
+	if u.ID >= m.nextID {
+		m.nextID = u.ID + 1
+	}
+}
+
+// sorted returns every user in ID order. Callers must hold mu.
+func (m *Memory) sorted() []User {
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
@@ -0,0 +1,229 @@
+package users
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testRepository runs the behaviour every Repository must share
+func testRepository(t *testing.T, repo Repository) {
+	t.Helper()
+
+	alice, err := repo.Create(User{User: "alice"})
+	if err != nil {
+		t.Fatalf("Create(alice): %v", err)
+	}
+	bob, err := repo.Create(User{User: "bob"})
+	if err != nil {
+		t.Fatalf("Create(bob): %v", err)
+	}
+	if alice.ID != 1 || bob.ID != 2 {
+		t.Fatalf("IDs = %d, %d, want 1, 2", alice.ID, bob.ID)
+	}
+	if bob.Version != 1 || bob.UpdatedAt.IsZero() {
+		t.Errorf("new user = %+v, want version 1 and a timestamp", bob)
+	}
+
+	if got, err := repo.Get(bob.ID); err != nil || got != bob {
+		t.Errorf("Get(%d) = %+v, %v, want %+v", bob.ID, got, err, bob)
+	}
+	if err := repo.Delete(alice.ID, 0); err != nil {
+		t.Fatalf("Delete(%d): %v", alice.ID, err)
+	}
+	if _, err := repo.Get(alice.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(alice.ID, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+
+	// The old len(userCache)+1 scheme would hand out 2 again here
+	carol, err := repo.Create(User{User: "carol"})
+	if err != nil {
+		t.Fatalf("Create(carol): %v", err)
+	}
+	if carol.ID != 3 {
+		t.Errorf("ID after a delete = %d, want 3", carol.ID)
+	}
+	if got, _ := repo.Get(bob.ID); got != bob {
+		t.Errorf("bob was overwritten: %+v", got)
+	}
+
+	renamed := bob
+	renamed.User = "robert"
+	renamed, err = repo.Update(renamed, bob.Version)
+	if err != nil || renamed.User != "robert" {
+		t.Fatalf("Update = %+v, %v", renamed, err)
+	}
+	if renamed.Version != bob.Version+1 || renamed.UpdatedAt.Before(bob.UpdatedAt) {
+		t.Errorf("Update did not advance the version: %+v after %+v", renamed, bob)
+	}
+	if got, _ := repo.Get(bob.ID); got != renamed {
+		t.Errorf("Get after Update = %+v, want %+v", got, renamed)
+	}
+	bob = renamed
+
+	// Writes based on a stale version must fail and leave the user alone
+	if _, err := repo.Update(User{ID: bob.ID, User: "bobby"}, bob.Version-1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale Update error = %v, want ErrVersionConflict", err)
+	}
+	if err := repo.Delete(bob.ID, bob.Version+1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale Delete error = %v, want ErrVersionConflict", err)
+	}
+	if got, _ := repo.Get(bob.ID); got != bob {
+		t.Errorf("stale writes changed the user to %+v", got)
+	}
+	if _, err := repo.Update(User{ID: alice.ID, User: "ghost"}, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of a deleted user error = %v, want ErrNotFound", err)
+	}
+
+	page, err := repo.List(Query{Sort: "-id"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []User{carol, bob}; !reflect.DeepEqual(page.Users, want) {
+		t.Errorf("List = %+v, want %+v", page.Users, want)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testRepository(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	f, err := OpenFile(filepath.Join(t.TempDir(), "users.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	testRepository(t, f)
+}
+
+func TestFileRecovers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+
+	tests := []struct {
+		name         string
+		compactEvery int
+	}{
+		{"Log only", 1000},
+		{"Snapshot and log", 2},
+		{"Snapshot only", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(path)
+			os.Remove(path + ".snapshot")
+
+			f, err := OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.CompactEvery = tt.compactEvery
+			a, _ := f.Create(User{User: "a"})
+			b, _ := f.Create(User{User: "b"})
+			c, _ := f.Create(User{User: "c"})
+			if err := f.Delete(c.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			// Simulate a crash: drop the file without the compaction Close does
+			f.log.Close()
+
+			f, err = OpenFile(path)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer f.Close()
+
+			for _, want := range []User{a, b} {
+				if got, err := f.Get(want.ID); err != nil || got != want {
+					t.Errorf("Get(%d) = %+v, %v, want %+v", want.ID, got, err, want)
+				}
+			}
+			if _, err := f.Get(c.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleted user came back: %v", err)
+			}
+			// The deleted user's ID stays retired across restarts
+			if d, _ := f.Create(User{User: "d"}); d.ID != 4 {
+				t.Errorf("ID after reopen = %d, want 4", d.ID)
+			}
+		})
+	}
+}
+
+func TestFileRefusesWritesAfterAFailedUndo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	a, _ := f.Create(User{User: "a"})
+
+	// A read-only handle fails the write and the truncate that would undo it
+	writable := f.log
+	if f.log, err = os.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Create(User{User: "b"}); err == nil {
+		t.Fatal("Create succeeded on a read-only log")
+	}
+	f.log.Close()
+	f.log = writable
+	if _, err := f.Create(User{User: "c"}); err == nil {
+		t.Error("Create succeeded after a write that could not be undone")
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, _ := reopened.List(Query{}); len(got.Users) != 1 || got.Users[0] != a {
+		t.Errorf("users after reopen = %+v, want only %+v", got.Users, a)
+	}
+}
+
+func TestFileIgnoresTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := f.Create(User{User: "a"})
+	f.log.Close()
+
+	log, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"op":"put","id":2,"us`)
+	log.Close()
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("reopen with torn tail: %v", err)
+	}
+	if got, err := f.Get(a.ID); err != nil || got != a {
+		t.Errorf("Get(%d) = %+v, %v, want %+v", a.ID, got, err, a)
+	}
+	if _, err := f.Get(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("torn record was applied: %v", err)
+	}
+
+	// Records written after the torn tail must survive the next replay
+	b, _ := f.Create(User{User: "b"})
+	f.log.Close()
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("reopen after append: %v", err)
+	}
+	defer f.Close()
+	if got, err := f.Get(b.ID); err != nil || got != b {
+		t.Errorf("Get(%d) = %+v, %v, want %+v", b.ID, got, err, b)
+	}
+}
@@ -0,0 +1,326 @@
+// Package userstest is the contract every adapter of the users API must
+// pass. Each server's tests call Run with a handler serving users.Endpoints
+// at the root, which proves they all answer with the same statuses, headers
+// and bodies.
+package userstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/users"
+	"shared/validate"
+	"strings"
+	"testing"
+)
+
+// Run runs the contract against the handlers newHandler returns, one per
+// test, each backed by an empty repository
+func Run(t *testing.T, newHandler func(repo users.Repository) http.Handler) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, c *client)
+	}{
+		{"CreateReturnsLocation", testCreateReturnsLocation},
+		{"DeleteThenCreateKeepsExistingUsers", testDeleteThenCreate},
+		{"ListPagination", testListPagination},
+		{"UpdateAndPatch", testUpdateAndPatch},
+		{"ErrorsAreJSON", testErrorsAreJSON},
+		{"Validation", testValidation},
+		{"ConditionalGet", testConditionalGet},
+		{"ConditionalWrites", testConditionalWrites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, &client{t: t, handler: newHandler(users.NewMemory())})
+		})
+	}
+}
+
+// client sends requests straight to a handler
+type client struct {
+	t       *testing.T
+	handler http.Handler
+}
+
+func (c *client) do(method, target, contentType, body string, headers ...string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals the response body into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+func testCreateReturnsLocation(t *testing.T, c *client) {
+	w := c.do(http.MethodPost, "/users", "application/json", `{"user":"alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /users = %d, want 201", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/users/1" {
+		t.Errorf("Location = %q, want /users/1", loc)
+	}
+	var created users.User
+	decode(t, w, &created)
+	if created.ID != 1 || created.User != "alice" || created.Version != 1 {
+		t.Errorf("created = %+v", created)
+	}
+	if tag := w.Header().Get("ETag"); tag != `"1"` {
+		t.Errorf("ETag = %q, want \"1\"", tag)
+	}
+}
+
+func testDeleteThenCreate(t *testing.T, c *client) {
+	c.do(http.MethodPost, "/users", "", `{"user":"alice"}`)
+	c.do(http.MethodPost, "/users", "", `{"user":"bob"}`)
+	if w := c.do(http.MethodDelete, "/users/1", "", ""); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("DELETE /users/1 = %d %q, want 204 with no body", w.Code, w.Body)
+	}
+	c.do(http.MethodPost, "/users", "", `{"user":"carol"}`)
+
+	tests := []struct {
+		target   string
+		wantCode int
+		wantUser string
+	}{
+		{"/users/1", http.StatusNotFound, ""},
+		{"/users/2", http.StatusOK, "bob"},
+		{"/users/3", http.StatusOK, "carol"},
+	}
+	for _, tt := range tests {
+		w := c.do(http.MethodGet, tt.target, "", "")
+		if w.Code != tt.wantCode {
+			t.Errorf("GET %s = %d, want %d", tt.target, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var got users.User
+		decode(t, w, &got)
+		if got.User != tt.wantUser {
+			t.Errorf("GET %s = %+v, want user %q", tt.target, got, tt.wantUser)
+		}
+	}
+}
+
+func testListPagination(t *testing.T, c *client) {
+	for _, name := range []string{"carol", "alice", "bob"} {
+		c.do(http.MethodPost, "/users", "", `{"user":"`+name+`"}`)
+	}
+
+	var got []string
+	target := "/users?sort=user&limit=2"
+	for pages := 0; target != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		w := c.do(http.MethodGet, target, "", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", target, w.Code)
+		}
+		var page users.Page
+		decode(t, w, &page)
+		for _, u := range page.Users {
+			got = append(got, u.User)
+		}
+		target = ""
+		if page.NextCursor != "" {
+			target = "/users?sort=user&limit=2&cursor=" + page.NextCursor
+		}
+	}
+	if strings.Join(got, ",") != "alice,bob,carol" {
+		t.Errorf("listed %v, want alice,bob,carol", got)
+	}
+}
+
+func testUpdateAndPatch(t *testing.T, c *client) {
+	c.do(http.MethodPost, "/users", "", `{"user":"alice"}`)
+
+	w := c.do(http.MethodPut, "/users/1", "application/json", `{"user":"alicia"}`)
+	var user users.User
+	decode(t, w, &user)
+	if w.Code != http.StatusOK || user.User != "alicia" {
+		t.Errorf("PUT = %d %+v, want 200 alicia", w.Code, user)
+	}
+
+	w = c.do(http.MethodPatch, "/users/1", "application/merge-patch+json", `{"user":"ally"}`)
+	decode(t, w, &user)
+	if w.Code != http.StatusOK || user.ID != 1 || user.User != "ally" {
+		t.Errorf("PATCH = %d %+v, want 200 ally", w.Code, user)
+	}
+}
+
+func testErrorsAreJSON(t *testing.T, c *client) {
+	c.do(http.MethodPost, "/users", "", `{"user":"alice"}`)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantCode    int
+		wantError   string // a prefix, since some messages quote the decoder's error
+	}{
+		{"Unknown user", http.MethodGet, "/users/42", "", "", http.StatusNotFound, "user not found"},
+		{"Non-numeric ID", http.MethodGet, "/users/abc", "", "", http.StatusBadRequest, "user id must be an integer"},
+		{"Malformed body", http.MethodPost, "/users", "application/json", `{"user":`, http.StatusBadRequest, "invalid JSON: "},
+		{"Unknown field", http.MethodPost, "/users", "application/json", `{"name":"bob"}`, http.StatusBadRequest, "invalid JSON: "},
+		{"PUT of a missing user", http.MethodPut, "/users/42", "application/json", `{"user":"x"}`, http.StatusNotFound, "user not found"},
+		{"PUT with another ID", http.MethodPut, "/users/1", "application/json", `{"id":2,"user":"x"}`, http.StatusBadRequest, "id in the body does not match the URL"},
+		{"PATCH as plain JSON", http.MethodPatch, "/users/1", "application/json", `{"user":"x"}`, http.StatusUnsupportedMediaType, "PATCH requires Content-Type application/merge-patch+json"},
+		{"PATCH changing the ID", http.MethodPatch, "/users/1", "application/merge-patch+json", `{"id":5}`, http.StatusBadRequest, "id cannot be changed"},
+		{"Unknown sort", http.MethodGet, "/users?sort=email", "", "", http.StatusBadRequest, `invalid query: cannot sort by "email"`},
+		{"Bad limit", http.MethodGet, "/users?limit=ten", "", "", http.StatusBadRequest, "limit must be an integer"},
+		{"Bad cursor", http.MethodGet, "/users?cursor=zzz", "", "", http.StatusBadRequest, "invalid query: malformed cursor"},
+		{"DELETE of a missing user", http.MethodDelete, "/users/42", "", "", http.StatusNotFound, "user not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := c.do(tt.method, tt.target, tt.contentType, tt.body)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var body users.ErrorBody
+			decode(t, w, &body)
+			if body.Status != tt.wantCode || !strings.HasPrefix(body.Error, tt.wantError) {
+				t.Errorf("body = %+v, want status %d and error %q", body, tt.wantCode, tt.wantError)
+			}
+		})
+	}
+}
+
+func testValidation(t *testing.T, c *client) {
+	c.do(http.MethodPost, "/users", "", `{"user":"alice"}`)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantRule    string
+	}{
+		{"POST without a name", http.MethodPost, "/users", "application/json", `{}`, "required"},
+		{"POST with a long name", http.MethodPost, "/users", "application/json", `{"user":"` + strings.Repeat("a", 101) + `"}`, "max"},
+		{"PUT with an empty name", http.MethodPut, "/users/1", "application/json", `{"user":""}`, "required"},
+		{"PATCH removing the name", http.MethodPatch, "/users/1", "application/merge-patch+json", `{"user":null}`, "required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := c.do(tt.method, tt.target, tt.contentType, tt.body)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want 422 (%s)", w.Code, w.Body)
+			}
+			var body validate.Response
+			decode(t, w, &body)
+			if len(body.Fields) != 1 || body.Fields[0].Field != "user" || body.Fields[0].Rule != tt.wantRule {
+				t.Errorf("fields = %+v, want user failing %s", body.Fields, tt.wantRule)
+			}
+		})
+	}
+
+	// Nothing invalid was stored
+	var user users.User
+	decode(t, c.do(http.MethodGet, "/users/1", "", ""), &user)
+	if user.User != "alice" || user.Version != 1 {
+		t.Errorf("user = %+v, want alice unchanged", user)
+	}
+}
+
+func testConditionalGet(t *testing.T, c *client) {
+	created := c.do(http.MethodPost, "/users", "", `{"user":"alice"}`)
+	tag := created.Header().Get("ETag")
+	lastModified := created.Header().Get("Last-Modified")
+	if tag == "" || lastModified == "" {
+		t.Fatalf("validators missing: ETag %q, Last-Modified %q", tag, lastModified)
+	}
+
+	tests := []struct {
+		name     string
+		headers  []string
+		wantCode int
+	}{
+		{"Unconditional", nil, http.StatusOK},
+		{"Matching ETag", []string{"If-None-Match", tag}, http.StatusNotModified},
+		{"Weak form of the ETag", []string{"If-None-Match", "W/" + tag}, http.StatusNotModified},
+		{"One of several ETags", []string{"If-None-Match", `"7", ` + tag}, http.StatusNotModified},
+		{"Other ETag", []string{"If-None-Match", `"7"`}, http.StatusOK},
+		{"Not modified since", []string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{"Modified since", []string{"If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK},
+		{"ETag wins over date", []string{"If-None-Match", `"7"`, "If-Modified-Since", lastModified}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := c.do(http.MethodGet, "/users/1", "", "", tt.headers...)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := w.Header().Get("ETag"); got != tag {
+				t.Errorf("ETag = %q, want %q", got, tag)
+			}
+			if tt.wantCode == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 had a body: %q", w.Body)
+			}
+		})
+	}
+}
+
+func testConditionalWrites(t *testing.T, c *client) {
+	v1 := c.do(http.MethodPost, "/users", "", `{"user":"alice"}`).Header().Get("ETag")
+
+	// Two clients read v1; the first write wins and the second gets 412
+	w := c.do(http.MethodPut, "/users/1", "application/json", `{"user":"first"}`, "If-Match", v1)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first PUT = %d, want 200", w.Code)
+	}
+	v2 := w.Header().Get("ETag")
+	if v2 == v1 {
+		t.Errorf("ETag did not change after an update")
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		ifMatch     string
+		wantCode    int
+	}{
+		{"Stale PUT", http.MethodPut, "application/json", `{"user":"second"}`, v1, http.StatusPreconditionFailed},
+		{"Stale PATCH", http.MethodPatch, "application/merge-patch+json", `{"user":"second"}`, v1, http.StatusPreconditionFailed},
+		{"Stale DELETE", http.MethodDelete, "", "", v1, http.StatusPreconditionFailed},
+		{"Weak ETag never matches", http.MethodPut, "application/json", `{"user":"second"}`, "W/" + v2, http.StatusPreconditionFailed},
+		{"Current PATCH", http.MethodPatch, "application/merge-patch+json", `{"user":"patched"}`, v2, http.StatusOK},
+		{"Any version", http.MethodDelete, "", "", "*", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := c.do(tt.method, "/users/1", tt.contentType, tt.body, "If-Match", tt.ifMatch)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
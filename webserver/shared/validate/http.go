@@ -0,0 +1,30 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Response is the JSON body Respond writes
+type Response struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+	Fields Errors `json:"fields,omitempty"`
+}
+
+// Respond writes the error returned by Struct. Validation failures become
+// 422 Unprocessable Entity with one entry per field; anything else, such as a
+// malformed tag, is a 500.
+func Respond(w http.ResponseWriter, err error) {
+	body := Response{Error: "validation failed", Status: http.StatusUnprocessableEntity}
+	var fields Errors
+	if errors.As(err, &fields) {
+		body.Fields = fields
+	} else {
+		body.Error, body.Status = err.Error(), http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(body.Status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,258 @@
+// Package validate checks structs against their `validate` struct tags, the
+// net/http counterpart of Gin's `binding` tags.
+//
+// Supported rules, separated by commas:
+//
+//	required   the value is not its zero value (non-empty string, slice or map, non-nil pointer)
+//	email      the string is a bare e-mail address
+//	min=N      strings have at least N characters, numbers are at least N, slices and maps have at least N elements
+//	max=N      the same upper bound
+//	oneof=a b  the value is one of the space-separated options
+//
+// Nested structs, pointers to structs and slices or arrays of structs are
+// validated recursively. Each field reports the first rule it fails, named by
+// its JSON path, such as "address.city" or "contacts[2].email".
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is one failed rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a validated value
+type Errors []FieldError
+
+// Error joins the messages of every field
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Struct validates v, a struct or a pointer to one. It returns nil, Errors
+// listing every invalid field, or a plain error when v or a tag is malformed.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return fmt.Errorf("validate: nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a struct", v)
+	}
+
+	var errs Errors
+	if err := walkStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// rule is one parsed entry of a validate tag
+type rule struct {
+	name  string
+	param string
+}
+
+// field is the validation plan for one struct field
+type field struct {
+	index int
+	name  string // JSON name used in error paths
+	rules []rule
+}
+
+// plans caches the parsed fields of each struct type
+var plans sync.Map // reflect.Type -> []field
+
+func planFor(t reflect.Type) ([]field, error) {
+	if cached, ok := plans.Load(t); ok {
+		return cached.([]field), nil
+	}
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			name = ""
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		var rules []rule
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, part := range strings.Split(tag, ",") {
+				r := rule{}
+				r.name, r.param, _ = strings.Cut(strings.TrimSpace(part), "=")
+				if err := checkRule(sf, r); err != nil {
+					return nil, err
+				}
+				rules = append(rules, r)
+			}
+		}
+		fields = append(fields, field{index: i, name: name, rules: rules})
+	}
+
+	plans.Store(t, fields)
+	return fields, nil
+}
+
+// checkRule rejects unknown rules and missing parameters when a type is first seen
+func checkRule(sf reflect.StructField, r rule) error {
+	switch r.name {
+	case "required", "email":
+		return nil
+	case "min", "max":
+		if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+			return fmt.Errorf("validate: field %s: %s needs a number, got %q", sf.Name, r.name, r.param)
+		}
+		return nil
+	case "oneof":
+		if strings.TrimSpace(r.param) == "" {
+			return fmt.Errorf("validate: field %s: oneof needs options", sf.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("validate: field %s: unknown rule %q", sf.Name, r.name)
+}
+
+func walkStruct(v reflect.Value, prefix string, errs *Errors) error {
+	fields, err := planFor(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		path := f.name
+		if prefix != "" {
+			path = prefix + "." + f.name
+		}
+		fv := v.Field(f.index)
+		for _, r := range f.rules {
+			if fe, failed := apply(r, fv); failed {
+				fe.Field = path
+				*errs = append(*errs, fe)
+				break // one error per field, the first rule that failed
+			}
+		}
+		if err := walkValue(fv, path, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkValue descends into nested structs, pointers and slices
+func walkValue(v reflect.Value, path string, errs *Errors) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walkValue(v.Elem(), path, errs)
+	case reflect.Struct:
+		return walkStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply runs rule r against v and reports a FieldError if it fails
+func apply(r rule, v reflect.Value) (FieldError, bool) {
+	fail := func(msg string) (FieldError, bool) {
+		return FieldError{Rule: r.name, Param: r.param, Message: msg}, true
+	}
+
+	if r.name == "required" {
+		if v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0) {
+			return fail("is required")
+		}
+		return FieldError{}, false
+	}
+
+	// Every other rule checks the value behind a pointer and skips nil
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return FieldError{}, false
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "email":
+		if v.Kind() != reflect.String || v.Len() == 0 {
+			return FieldError{}, false
+		}
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return fail("must be a valid email address")
+		}
+	case "min", "max":
+		limit, _ := strconv.ParseFloat(r.param, 64)
+		size, unit, ok := measure(v)
+		if !ok {
+			return FieldError{}, false
+		}
+		if r.name == "min" && size < limit {
+			return fail(fmt.Sprintf("must be at least %s%s", r.param, unit))
+		}
+		if r.name == "max" && size > limit {
+			return fail(fmt.Sprintf("must be at most %s%s", r.param, unit))
+		}
+	case "oneof":
+		options := strings.Fields(r.param)
+		got := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if got == option {
+				return FieldError{}, false
+			}
+		}
+		return fail("must be one of " + strings.Join(options, ", "))
+	}
+	return FieldError{}, false
+}
+
+// measure returns what min and max compare for v, with the unit for messages
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
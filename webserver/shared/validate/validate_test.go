@@ -0,0 +1,163 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type Address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"oneof=NL DE FR"`
+}
+
+type Signup struct {
+	Name      string    `json:"name" validate:"required,min=3,max=10"`
+	Email     string    `json:"email" validate:"required,email"`
+	Age       int       `json:"age" validate:"min=18,max=130"`
+	Role      string    `json:"role" validate:"oneof=admin member"`
+	Tags      []string  `json:"tags" validate:"max=2"`
+	Address   Address   `json:"address"`
+	Billing   *Address  `json:"billing"`
+	Contacts  []Address `json:"contacts" validate:"required"`
+	Nickname  *string   `json:"nickname" validate:"min=2"`
+	internal  string    `validate:"required"`
+	NoJSONTag string    `validate:"max=3"`
+}
+
+func valid() Signup {
+	return Signup{
+		Name:     "Alice",
+		Email:    "alice@example.com",
+		Age:      30,
+		Role:     "member",
+		Address:  Address{City: "Utrecht", Country: "NL"},
+		Contacts: []Address{{City: "Berlin", Country: "DE"}},
+	}
+}
+
+func fieldRules(err error) map[string]string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	got := map[string]string{}
+	for _, fe := range errs {
+		got[fe.Field] = fe.Rule
+	}
+	return got
+}
+
+func TestStruct(t *testing.T) {
+	short := "x"
+
+	tests := []struct {
+		name   string
+		modify func(*Signup)
+		want   map[string]string // field path -> failed rule
+	}{
+		{"Valid", func(s *Signup) {}, nil},
+		{"Missing name", func(s *Signup) { s.Name = "" }, map[string]string{"name": "required"}},
+		{"Name too long in runes", func(s *Signup) { s.Name = "ÅÅÅÅÅÅÅÅÅÅÅ" }, map[string]string{"name": "max"}},
+		{"Multibyte name within limit", func(s *Signup) { s.Name = "ÅÅÅÅÅÅÅÅÅÅ" }, nil},
+		{"Bad email", func(s *Signup) { s.Email = "alice" }, map[string]string{"email": "email"}},
+		{"Email with display name", func(s *Signup) { s.Email = "Alice <alice@example.com>" }, map[string]string{"email": "email"}},
+		{"Too young", func(s *Signup) { s.Age = 17 }, map[string]string{"age": "min"}},
+		{"Unknown role", func(s *Signup) { s.Role = "owner" }, map[string]string{"role": "oneof"}},
+		{"Too many tags", func(s *Signup) { s.Tags = []string{"a", "b", "c"} }, map[string]string{"tags": "max"}},
+		{"Nested struct", func(s *Signup) { s.Address.City = "" }, map[string]string{"address.city": "required"}},
+		{"Nil pointer is skipped", func(s *Signup) { s.Billing = nil }, nil},
+		{"Pointer to struct", func(s *Signup) { s.Billing = &Address{City: "Paris", Country: "US"} }, map[string]string{"billing.country": "oneof"}},
+		{"Empty required slice", func(s *Signup) { s.Contacts = []Address{} }, map[string]string{"contacts": "required"}},
+		{"Slice element", func(s *Signup) { s.Contacts = append(s.Contacts, Address{Country: "FR"}) }, map[string]string{"contacts[1].city": "required"}},
+		{"Pointer to short string", func(s *Signup) { s.Nickname = &short }, map[string]string{"nickname": "min"}},
+		{"Field without JSON tag", func(s *Signup) { s.NoJSONTag = "long" }, map[string]string{"NoJSONTag": "max"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			err := Struct(&s)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+			got := fieldRules(err)
+			for field, rule := range tt.want {
+				if got[field] != rule {
+					t.Errorf("Struct() = %v, want %s to fail %s", err, field, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestStructCollectsEveryError(t *testing.T) {
+	err := Struct(Signup{})
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Struct() = %v, want Errors", err)
+	}
+	var fields []string
+	for _, fe := range errs {
+		fields = append(fields, fe.Field+":"+fe.Rule)
+	}
+	want := []string{
+		"name:required", "email:required", "age:min", "role:oneof",
+		"address.city:required", "address.country:oneof", "contacts:required",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("errors = %v\nwant %v", fields, want)
+	}
+}
+
+func TestStructRejectsBadInput(t *testing.T) {
+	type badRule struct {
+		Name string `validate:"requird"`
+	}
+	type badParam struct {
+		Name string `validate:"min=three"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"Not a struct", 42},
+		{"Nil pointer", (*Signup)(nil)},
+		{"Unknown rule", badRule{}},
+		{"Non-numeric min", badParam{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			var errs Errors
+			if err == nil || errors.As(err, &errs) {
+				t.Errorf("Struct() = %v, want a plain error", err)
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	w := httptest.NewRecorder()
+	Respond(w, Struct(Address{City: "", Country: "NL"}))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422", w.Code)
+	}
+	var body Response
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := Errors{{Field: "city", Rule: "required", Message: "is required"}}
+	if body.Status != http.StatusUnprocessableEntity || !reflect.DeepEqual(body.Fields, want) {
+		t.Errorf("body = %+v, want fields %+v", body, want)
+	}
+}